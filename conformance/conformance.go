@@ -0,0 +1,205 @@
+// Package conformance runs the scenarios of the network tests against
+// implementations of the IPNI service, indexing service and storage node, so
+// that the repositories of those services can check a branch interoperates
+// with the rest of the network before it is released.
+//
+// Each service is either started for every scenario by a constructor, or is
+// already running at a URL. Services that are not configured are started in
+// process from the versions of their modules this repository pins. For
+// example, the storage node repository can run the suites against its own
+// node with:
+//
+//	func TestConformance(t *testing.T) {
+//		conformance.Run(t, conformance.WithStorageNode(startStorageNode))
+//	}
+package conformance
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/principal"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/storacha/testthenetwork/internal/bootstrap"
+)
+
+// IPNIConfig configures an IPNI service started for a scenario.
+type IPNIConfig struct {
+	// FindURL is the URL to serve find requests on.
+	FindURL url.URL
+	// AnnounceURL is the URL to accept announcements of advertisements on.
+	AnnounceURL url.URL
+}
+
+// IndexingServiceConfig configures an indexing service started for a scenario.
+type IndexingServiceConfig struct {
+	ID principal.Signer
+	// PublicURL is the URL to serve the indexing service API on.
+	PublicURL url.URL
+	// IPNIFindURL is the URL of the IPNI service to query for providers.
+	IPNIFindURL url.URL
+	// IPNIAnnounceURL is the URL of the IPNI service to announce
+	// advertisements of published claims to.
+	IPNIAnnounceURL url.URL
+	// NoCache disables the caching of providers, claims and indexes, so that
+	// every query is answered from IPNI.
+	NoCache bool
+}
+
+// StorageNodeConfig configures a storage node started for a scenario.
+type StorageNodeConfig struct {
+	ID principal.Signer
+	// PublicURL is the URL to serve the UCAN endpoint and blobs on.
+	PublicURL url.URL
+	// IPNIAnnounceURL is the URL of the IPNI service to announce
+	// advertisements of location commitments to.
+	IPNIAnnounceURL url.URL
+	// IndexingServiceID is the DID of the indexing service to cache location
+	// commitments with.
+	IndexingServiceID ucan.Principal
+	// IndexingServiceURL is the URL of the indexing service.
+	IndexingServiceURL url.URL
+	// IndexingServiceProof is a delegation allowing the storage node to invoke
+	// claim/cache on the indexing service.
+	IndexingServiceProof delegation.Proof
+}
+
+// StartIPNI starts an IPNI service and returns a function that stops it.
+type StartIPNI func(t *testing.T, cfg IPNIConfig) func()
+
+// StartIndexingService starts an indexing service and returns a function that
+// stops it.
+type StartIndexingService func(t *testing.T, cfg IndexingServiceConfig) func()
+
+// StartStorageNode starts a storage node and returns a function that stops it.
+type StartStorageNode func(t *testing.T, cfg StorageNodeConfig) func()
+
+// InProcessIPNI starts the storetheindex IPNI service in process.
+func InProcessIPNI(t *testing.T, cfg IPNIConfig) func() {
+	return start(t, bootstrap.NewInProcessIPNI(t, bootstrap.IPNIConfig{
+		FindURL:     cfg.FindURL,
+		AnnounceURL: cfg.AnnounceURL,
+	}))
+}
+
+// InProcessIndexingService starts the indexing service in process, with
+// in-memory caches.
+func InProcessIndexingService(t *testing.T, cfg IndexingServiceConfig) func() {
+	return start(t, bootstrap.NewInProcessIndexingService(t, bootstrap.IndexingServiceConfig{
+		ID:              cfg.ID,
+		PublicURL:       cfg.PublicURL,
+		IPNIFindURL:     cfg.IPNIFindURL,
+		IPNIAnnounceURL: cfg.IPNIAnnounceURL,
+		NoCache:         cfg.NoCache,
+	}))
+}
+
+// InProcessStorageNode starts the storage node in process, with in-memory
+// blob and claim stores.
+func InProcessStorageNode(t *testing.T, cfg StorageNodeConfig) func() {
+	return start(t, bootstrap.NewInProcessStorageNode(t, bootstrap.StorageNodeConfig{
+		ID:                   cfg.ID,
+		PublicURL:            cfg.PublicURL,
+		IPNIAnnounceURL:      cfg.IPNIAnnounceURL,
+		IndexingServiceID:    cfg.IndexingServiceID,
+		IndexingServiceURL:   cfg.IndexingServiceURL,
+		IndexingServiceProof: cfg.IndexingServiceProof,
+	}))
+}
+
+// start starts the service and waits for it to be healthy, returning a
+// function that stops it.
+func start(t *testing.T, svc bootstrap.Service) func() {
+	svc.Start(t)
+	bootstrap.WaitHealthy(t, svc, 5*time.Second)
+	return svc.Stop
+}
+
+// config is the network the scenarios run against. A service with a nil start
+// function is already running at its URL.
+type config struct {
+	ipni            StartIPNI
+	ipniFindURL     *url.URL
+	ipniAnnounceURL *url.URL
+
+	indexing    StartIndexingService
+	indexingID  principal.Signer
+	indexingURL *url.URL
+
+	storage    StartStorageNode
+	storageID  principal.Signer
+	storageURL *url.URL
+}
+
+// Option configures the network the scenarios run against.
+type Option func(*config)
+
+// WithIPNI starts the IPNI service for each scenario with the function.
+func WithIPNI(start StartIPNI) Option {
+	return func(c *config) {
+		c.ipni = start
+		c.ipniFindURL, c.ipniAnnounceURL = nil, nil
+	}
+}
+
+// WithIPNIURLs runs the scenarios against an IPNI service that is already
+// running at the URLs.
+func WithIPNIURLs(findURL, announceURL url.URL) Option {
+	return func(c *config) {
+		c.ipni = nil
+		c.ipniFindURL, c.ipniAnnounceURL = &findURL, &announceURL
+	}
+}
+
+// WithIndexingService starts the indexing service for each scenario with the
+// function.
+func WithIndexingService(start StartIndexingService) Option {
+	return func(c *config) {
+		c.indexing = start
+		c.indexingID, c.indexingURL = nil, nil
+	}
+}
+
+// WithIndexingServiceURL runs the scenarios against an indexing service that
+// is already running at the URL and uses the IPNI service the scenarios are
+// configured with. Its signer is needed to delegate capabilities on the
+// service to the principals of the scenarios.
+func WithIndexingServiceURL(id principal.Signer, publicURL url.URL) Option {
+	return func(c *config) {
+		c.indexing = nil
+		c.indexingID, c.indexingURL = id, &publicURL
+	}
+}
+
+// WithStorageNode starts the storage node for each scenario with the function.
+func WithStorageNode(start StartStorageNode) Option {
+	return func(c *config) {
+		c.storage = start
+		c.storageID, c.storageURL = nil, nil
+	}
+}
+
+// WithStorageNodeURL runs the scenarios against a storage node that is already
+// running at the URL and announces to, and caches claims with, the IPNI and
+// indexing services the scenarios are configured with. Its signer is needed to
+// delegate blob/allocate and blob/accept on the node to the upload service.
+func WithStorageNodeURL(id principal.Signer, publicURL url.URL) Option {
+	return func(c *config) {
+		c.storage = nil
+		c.storageID, c.storageURL = id, &publicURL
+	}
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{
+		ipni:     InProcessIPNI,
+		indexing: InProcessIndexingService,
+		storage:  InProcessStorageNode,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
@@ -0,0 +1,46 @@
+package conformance
+
+import (
+	"flag"
+	"net/url"
+	"strings"
+	"testing"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/storacha/testthenetwork/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConformance(t *testing.T) {
+	logging.SetLogLevel("*", "warn")
+
+	t.Run("in process", func(t *testing.T) {
+		Run(t)
+	})
+
+	// services started by constructors other than the defaults are passed the
+	// configuration of the network, and running services are found at their
+	// URLs
+	t.Run("running IPNI service", func(t *testing.T) {
+		findURL, announceURL := testutil.RandomLocalURL(t), testutil.RandomLocalURL(t)
+		t.Cleanup(InProcessIPNI(t, IPNIConfig{FindURL: findURL, AnnounceURL: announceURL}))
+
+		var started []url.URL
+		Run(t, WithIPNIURLs(findURL, announceURL), WithStorageNode(func(t *testing.T, cfg StorageNodeConfig) func() {
+			started = append(started, cfg.IPNIAnnounceURL)
+			return InProcessStorageNode(t, cfg)
+		}))
+		require.Len(t, started, 3)
+		for _, u := range started {
+			require.Equal(t, announceURL, u)
+		}
+	})
+}
+
+// TestNoFlags checks the package registers no flags of its own, which would
+// panic in test binaries of repositories that define a flag of the same name.
+func TestNoFlags(t *testing.T) {
+	flag.VisitAll(func(f *flag.Flag) {
+		require.True(t, strings.HasPrefix(f.Name, "test."), "flag -%s is registered", f.Name)
+	})
+}
@@ -0,0 +1,109 @@
+package conformance
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/storacha/go-capabilities/pkg/assert"
+	"github.com/storacha/go-capabilities/pkg/blob"
+	"github.com/storacha/go-capabilities/pkg/claim"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/principal"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/storacha/indexing-service/pkg/client"
+	"github.com/storacha/testthenetwork/internal/clock"
+	"github.com/storacha/testthenetwork/internal/forensics"
+	"github.com/storacha/testthenetwork/internal/steps"
+	"github.com/storacha/testthenetwork/internal/suite"
+	"github.com/storacha/testthenetwork/internal/testutil"
+	"github.com/storacha/testthenetwork/internal/upload"
+	"github.com/stretchr/testify/require"
+)
+
+// startNetwork starts the services of the network that are not already
+// running, stopping them when the test completes.
+func startNetwork(t *testing.T, c *config, noCache bool) *suite.Network {
+	n := &suite.Network{Clock: clock.NewSystemClock()}
+
+	ipniFindURL, ipniAnnounceURL := urlOrRandom(t, c.ipniFindURL), urlOrRandom(t, c.ipniAnnounceURL)
+	n.IndexingID = signerOrRandom(t, c.indexingID)
+	indexingURL := urlOrRandom(t, c.indexingURL)
+	n.StorageID, n.StorageURL = signerOrRandom(t, c.storageID), urlOrRandom(t, c.storageURL)
+	uploadID := testutil.RandomSigner(t)
+	n.Alice, n.Bob = testutil.RandomSigner(t), testutil.RandomSigner(t)
+
+	storageIndexingProof := delegate(t, n.IndexingID, n.StorageID, claim.CacheAbility)
+	uploadStorageProof := delegate(t, n.StorageID, uploadID, blob.AllocateAbility, blob.AcceptAbility)
+	n.AliceProof = delegate(t, n.IndexingID, n.Alice, assert.EqualsAbility, assert.IndexAbility)
+	n.BobProof = delegate(t, n.IndexingID, n.Bob, assert.EqualsAbility, assert.IndexAbility)
+
+	if c.ipni != nil {
+		step := steps.Start(t, "start IPNI service", steps.A("find", ipniFindURL.String()), steps.A("announce", ipniAnnounceURL.String()))
+		t.Cleanup(c.ipni(t, IPNIConfig{FindURL: ipniFindURL, AnnounceURL: ipniAnnounceURL}))
+		step.Done()
+	}
+
+	if c.indexing != nil {
+		step := steps.Start(t, "start indexing service", steps.A("id", n.IndexingID.DID()), steps.A("url", indexingURL.String()))
+		t.Cleanup(c.indexing(t, IndexingServiceConfig{
+			ID:              n.IndexingID,
+			PublicURL:       indexingURL,
+			IPNIFindURL:     ipniFindURL,
+			IPNIAnnounceURL: ipniAnnounceURL,
+			NoCache:         noCache,
+		}))
+		step.Done()
+	}
+
+	if c.storage != nil {
+		step := steps.Start(t, "start storage node", steps.A("id", n.StorageID.DID()), steps.A("url", n.StorageURL.String()))
+		t.Cleanup(c.storage(t, StorageNodeConfig{
+			ID:                   n.StorageID,
+			PublicURL:            n.StorageURL,
+			IPNIAnnounceURL:      ipniAnnounceURL,
+			IndexingServiceID:    n.IndexingID,
+			IndexingServiceURL:   indexingURL,
+			IndexingServiceProof: storageIndexingProof,
+		}))
+		step.Done()
+	}
+
+	indexingClient, err := client.New(n.IndexingID, indexingURL)
+	require.NoError(t, err)
+	n.IndexingClient = indexingClient
+
+	n.UploadService = upload.NewService(t, upload.Config{
+		ID:             uploadID,
+		StorageNodeID:  n.StorageID,
+		StorageNodeURL: n.StorageURL,
+		StorageProof:   uploadStorageProof,
+		Clock:          n.Clock,
+	})
+
+	forensics.Capture(t)
+
+	return n
+}
+
+// delegate delegates the abilities on the issuer to the audience.
+func delegate(t *testing.T, issuer principal.Signer, audience ucan.Principal, abilities ...string) delegation.Proof {
+	var caps []ucan.Capability[ucan.NoCaveats]
+	for _, can := range abilities {
+		caps = append(caps, ucan.NewCapability(can, issuer.DID().String(), ucan.NoCaveats{}))
+	}
+	return delegation.FromDelegation(testutil.Must(delegation.Delegate(issuer, audience, caps, delegation.WithNoExpiration()))(t))
+}
+
+func urlOrRandom(t *testing.T, u *url.URL) url.URL {
+	if u != nil {
+		return *u
+	}
+	return testutil.RandomLocalURL(t)
+}
+
+func signerOrRandom(t *testing.T, s principal.Signer) principal.Signer {
+	if s != nil {
+		return s
+	}
+	return testutil.RandomSigner(t)
+}
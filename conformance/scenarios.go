@@ -0,0 +1,37 @@
+package conformance
+
+import (
+	"testing"
+
+	"github.com/storacha/testthenetwork/internal/suite"
+)
+
+// Run runs every suite as a subtest.
+func Run(t *testing.T, opts ...Option) {
+	t.Run("round trip", func(t *testing.T) { RoundTrip(t, opts...) })
+	t.Run("round trip (no cache)", func(t *testing.T) { NoCache(t, opts...) })
+	t.Run("filter by space", func(t *testing.T) { FilterBySpace(t, opts...) })
+}
+
+// RoundTrip uploads content and its index to a space, fetches the content from
+// the location the storage node commits to, publishes an index claim for it
+// and queries the indexing service for the content, asserting the index and
+// the claims needed to retrieve it are returned.
+func RoundTrip(t *testing.T, opts ...Option) {
+	suite.RoundTrip(t, startNetwork(t, newConfig(opts), false))
+}
+
+// NoCache is RoundTrip with the caches of the indexing service disabled, so
+// that the content is found through IPNI. Indexing services that are already
+// running are used as they are configured.
+func NoCache(t *testing.T, opts ...Option) {
+	suite.RoundTrip(t, startNetwork(t, newConfig(opts), true))
+}
+
+// FilterBySpace uploads the same content and index to the spaces of two
+// principals, who both publish an index claim for it, and asserts that a
+// query filtered by one space returns location commitments for that space
+// only.
+func FilterBySpace(t *testing.T, opts ...Option) {
+	suite.FilterBySpace(t, startNetwork(t, newConfig(opts), false))
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/multiformats/go-multihash"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/indexing-service/pkg/client"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/storacha/testthenetwork/internal/bootstrap"
+	"github.com/storacha/testthenetwork/internal/claimassert"
+	"github.com/storacha/testthenetwork/internal/clock"
+	"github.com/storacha/testthenetwork/internal/digestutil"
+	"github.com/storacha/testthenetwork/internal/netstep"
+	"github.com/storacha/testthenetwork/internal/printer"
+	"github.com/storacha/testthenetwork/internal/redis/fault"
+	"github.com/storacha/testthenetwork/internal/steps"
+	"github.com/storacha/testthenetwork/internal/testutil"
+	"github.com/storacha/testthenetwork/internal/tracing"
+	"github.com/stretchr/testify/require"
+)
+
+var errConnRefused = errors.New("dial tcp 127.0.0.1:6379: connect: connection refused")
+
+// addFaults adds the rules to every indexing service cache.
+func addFaults(caches *bootstrap.IndexingCaches, rules ...fault.Rule) {
+	caches.ProvidersFaults.Add(rules...)
+	caches.ClaimsFaults.Add(rules...)
+	caches.IndexesFaults.Add(rules...)
+}
+
+// requireQueryResult asserts the result contains the index, the index claim
+// and location commitments for the shard and the index.
+func requireQueryResult(t *testing.T, result types.QueryResult, content indexedContent, space did.DID) {
+	indexes := netstep.CollectIndexes(t, result)
+	require.Len(t, indexes, 1)
+	require.Equal(t, content.IndexLink, result.Indexes()[0])
+
+	claims := netstep.CollectClaims(t, result)
+	claimassert.Contains(t, claims, claimassert.Index(content.Root, content.IndexLink))
+	claimassert.Contains(t, claims, claimassert.Location(content.IndexDigest, space))
+	claimassert.Contains(t, claims, claimassert.Location(content.Digest, space))
+}
+
+func TestCacheFaults(t *testing.T) {
+	logging.SetLogLevel("*", "warn")
+
+	// setup starts the network and uploads some indexed content, returning
+	// the pieces needed to query for it.
+	setup := func(t *testing.T) (*bootstrap.IndexingCaches, *client.Client, indexedContent, did.DID) {
+		clk := clock.NewFakeClock(time.Now())
+		storageID, indexingID, uploadID, aliceID, bobID := generateIdentities(t)
+		ipniFindURL, ipniAnnounceURL, storageURL, indexingURL := generateURLs(t)
+		storageIndexingProof, uploadStorageProof, aliceIndexingProof, _ := generateProofs(t, storageID, indexingID, uploadID, aliceID, bobID)
+		uploadService, indexingClient, caches := startServices(t, ipniFindURL, ipniAnnounceURL, storageID, storageURL, storageIndexingProof, indexingID, indexingURL, false, uploadID, uploadStorageProof, clk)
+
+		space := testutil.RandomPrincipal(t).DID()
+		content := uploadIndexedContent(t, uploadService, indexingClient, clk, aliceID, aliceIndexingProof, space, 256, multihash.SHA2_256)
+
+		return caches, indexingClient, content, space
+	}
+
+	t.Run("slow cache", func(t *testing.T) {
+		caches, indexingClient, content, space := setup(t)
+		addFaults(caches, fault.Rule{Fault: fault.Fault{Latency: 50 * time.Millisecond}})
+
+		result := netstep.QueryClaims(t, indexingClient, []multihash.Multihash{content.RootDigest})
+		printer.PrintQueryResults(t, result)
+		requireQueryResult(t, result, content, space)
+	})
+
+	t.Run("evicted cache", func(t *testing.T) {
+		caches, indexingClient, content, space := setup(t)
+		requireQueryResult(t, netstep.QueryClaims(t, indexingClient, []multihash.Multihash{content.RootDigest}), content, space)
+
+		// every read now misses, so the service has to go back to IPNI
+		addFaults(caches, fault.Rule{
+			Methods: []string{"Get", "SMembers"},
+			Fault:   fault.Fault{Err: goredis.Nil},
+		})
+		caches.ResetRecorders()
+
+		result := netstep.AwaitQueryClaims(t, indexingClient, []multihash.Multihash{content.RootDigest})
+		printer.PrintQueryResults(t, result)
+		printer.PrintCacheStats(caches.Stats()...)
+		requireQueryResult(t, result, content, space)
+		require.NotZero(t, caches.ProvidersRecorder.Stats().Misses)
+	})
+
+	t.Run("cache write errors", func(t *testing.T) {
+		caches, indexingClient, content, space := setup(t)
+		addFaults(caches, fault.Rule{
+			Methods: []string{"Set", "SAdd", "Expire", "Persist"},
+			Fault:   fault.Fault{Err: errConnRefused},
+		})
+
+		result := netstep.QueryClaims(t, indexingClient, []multihash.Multihash{content.RootDigest})
+		printer.PrintQueryResults(t, result)
+		requireQueryResult(t, result, content, space)
+	})
+
+	// A cache read that fails with anything other than a miss should be
+	// treated like one, with the indexing service going back to IPNI, but the
+	// service fails the query instead: providerindex.(*ProviderIndex).Find and
+	// contentclaims.(*CachingFinder).Find return any cache error other than
+	// types.ErrKeyNotFound. The following scenarios assert that current
+	// behaviour and that the service recovers once the cache does. If the
+	// queries under fault start succeeding the service has been fixed, and
+	// they should require the result instead.
+
+	t.Run("cache read errors", func(t *testing.T) {
+		caches, indexingClient, content, space := setup(t)
+		addFaults(caches, fault.Rule{
+			Methods: []string{"Get", "SMembers"},
+			Fault:   fault.Fault{Err: errConnRefused},
+		})
+
+		_, err := tryQueryClaims(t, indexingClient, content.RootDigest)
+		require.Error(t, err)
+
+		caches.ClearFaults()
+		result := netstep.QueryClaims(t, indexingClient, []multihash.Multihash{content.RootDigest})
+		requireQueryResult(t, result, content, space)
+	})
+
+	t.Run("cache timeouts", func(t *testing.T) {
+		caches, indexingClient, content, space := setup(t)
+		addFaults(caches, fault.Rule{Fault: fault.Fault{Latency: 100 * time.Millisecond, Timeout: true}})
+
+		_, err := tryQueryClaims(t, indexingClient, content.RootDigest)
+		require.Error(t, err)
+
+		caches.ClearFaults()
+		result := netstep.QueryClaims(t, indexingClient, []multihash.Multihash{content.RootDigest})
+		requireQueryResult(t, result, content, space)
+	})
+
+	t.Run("intermittent cache errors", func(t *testing.T) {
+		caches, indexingClient, content, space := setup(t)
+		// only the first read of the providers cache fails
+		caches.ProvidersFaults.Add(fault.Rule{
+			Methods: []string{"SMembers"},
+			Script:  []*fault.Fault{{Err: errConnRefused}},
+		})
+
+		_, err := tryQueryClaims(t, indexingClient, content.RootDigest)
+		require.Error(t, err)
+
+		// the script has run out, so the next query succeeds
+		result := netstep.QueryClaims(t, indexingClient, []multihash.Multihash{content.RootDigest})
+		printer.PrintQueryResults(t, result)
+		requireQueryResult(t, result, content, space)
+	})
+}
+
+// tryQueryClaims queries for the digest, returning any error instead of
+// failing the test.
+func tryQueryClaims(t *testing.T, indexingClient *client.Client, digest multihash.Multihash) (types.QueryResult, error) {
+	step := steps.Start(t, "query claims, expecting failure", steps.A("digest", digestutil.Format(digest)))
+	defer step.Done()
+	result, err := indexingClient.QueryClaims(tracing.Context(t), types.Query{
+		Hashes: []multihash.Multihash{digest},
+	})
+	if err != nil {
+		step.Set(steps.A("error", err))
+	}
+	return result, err
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/testthenetwork/internal/clock"
+	"github.com/storacha/testthenetwork/internal/digestutil"
+	"github.com/storacha/testthenetwork/internal/netstep"
+	"github.com/storacha/testthenetwork/internal/printer"
+	"github.com/storacha/testthenetwork/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHashFunctions uploads blobs hashed with different multihash functions.
+// The storage node allocates space for a blob whatever its hash function, but
+// its blobstore only accepts SHA2-256 digests, so uploads of other blobs are
+// only rejected when the bytes are PUT, with a 500 rather than a client error.
+// Rejecting them when space is allocated would be better, but until then the
+// test pins that current behaviour: the PUT fails with "write failed" and the
+// blob is not found. If support is added, move the hash function to the
+// supported cases.
+func TestHashFunctions(t *testing.T) {
+	logging.SetLogLevel("*", "warn")
+
+	hashes := []struct {
+		code      uint64
+		supported bool
+	}{
+		{multihash.SHA2_256, true},
+		{multihash.SHA2_512, false},
+		{multihash.BLAKE3, false},
+	}
+
+	for _, h := range hashes {
+		t.Run(digestutil.HashName(h.code), func(t *testing.T) {
+			clk := clock.NewFakeClock(time.Now())
+			storageID, indexingID, uploadID, aliceID, bobID := generateIdentities(t)
+			ipniFindURL, ipniAnnounceURL, storageURL, indexingURL := generateURLs(t)
+			storageIndexingProof, uploadStorageProof, aliceIndexingProof, _ := generateProofs(t, storageID, indexingID, uploadID, aliceID, bobID)
+			uploadService, indexingClient, _ := startServices(t, ipniFindURL, ipniAnnounceURL, storageID, storageURL, storageIndexingProof, indexingID, indexingURL, false, uploadID, uploadStorageProof, clk)
+
+			space := testutil.RandomPrincipal(t).DID()
+			root, rootDigest, digest, data := generateContent(t, 256, h.code)
+
+			address := uploadService.BlobAdd(t, space, digest, uint64(len(data)))
+			require.NotNil(t, address)
+			status, body := netstep.TryPutBlob(t, address.URL, address.Headers, data)
+
+			if !h.supported {
+				require.Equal(t, http.StatusInternalServerError, status, body)
+				require.Equal(t, "write failed", body)
+
+				// the blob must not be retrievable
+				res, err := http.Get(storageURL.JoinPath("blob", digestutil.Format(digest)).String())
+				require.NoError(t, err)
+				res.Body.Close()
+				require.Equal(t, http.StatusNotFound, res.StatusCode)
+				return
+			}
+
+			require.Equal(t, http.StatusOK, status, body)
+			claim := uploadService.ConcludeHTTPPut(t, space, digest, uint64(len(data)))
+			nb := decodeLocationCommitmentCaveats(t, claim)
+
+			blobBytes, blobDigest := netstep.FetchBlob(t, nb.Location[0], h.code)
+			require.Equal(t, digest, blobDigest)
+
+			_, indexDigest, indexLink, indexData := generateIndex(t, root, blobBytes, h.code)
+			address = uploadService.BlobAdd(t, space, indexDigest, uint64(len(indexData)))
+			require.NotNil(t, address)
+			netstep.PutBlob(t, address.URL, address.Headers, indexData)
+			uploadService.ConcludeHTTPPut(t, space, indexDigest, uint64(len(indexData)))
+
+			netstep.PublishIndexClaim(t, indexingClient, clk, aliceID, aliceIndexingProof, root, indexLink)
+
+			result := netstep.AwaitQueryClaims(t, indexingClient, []multihash.Multihash{rootDigest})
+			printer.PrintQueryResults(t, result)
+			requireQueryResult(t, result, indexedContent{root, rootDigest, digest, data, indexDigest, indexLink, indexData}, space)
+		})
+	}
+}
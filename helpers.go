@@ -2,23 +2,16 @@ package main
 
 import (
 	"bytes"
-	"context"
-	"fmt"
 	"io"
-	"net/http"
 	"net/url"
-	"slices"
 	"testing"
 
-	"github.com/ipfs/go-cid"
 	"github.com/ipld/go-ipld-prime"
-	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
-	"github.com/multiformats/go-multicodec"
 	"github.com/multiformats/go-multihash"
 	"github.com/storacha/go-capabilities/pkg/assert"
 	"github.com/storacha/go-capabilities/pkg/blob"
 	"github.com/storacha/go-capabilities/pkg/claim"
-	"github.com/storacha/go-ucanto/core/dag/blockstore"
+	"github.com/storacha/go-ucanto/core/car"
 	"github.com/storacha/go-ucanto/core/delegation"
 	"github.com/storacha/go-ucanto/did"
 	"github.com/storacha/go-ucanto/principal"
@@ -27,8 +20,17 @@ import (
 	"github.com/storacha/indexing-service/pkg/client"
 	"github.com/storacha/indexing-service/pkg/types"
 	"github.com/storacha/testthenetwork/internal/bootstrap"
+	"github.com/storacha/testthenetwork/internal/clock"
 	"github.com/storacha/testthenetwork/internal/digestutil"
+	"github.com/storacha/testthenetwork/internal/forensics"
+	"github.com/storacha/testthenetwork/internal/netstep"
+	"github.com/storacha/testthenetwork/internal/printer"
+	"github.com/storacha/testthenetwork/internal/report"
+	"github.com/storacha/testthenetwork/internal/snapshot"
+	"github.com/storacha/testthenetwork/internal/steps"
+	"github.com/storacha/testthenetwork/internal/suite"
 	"github.com/storacha/testthenetwork/internal/testutil"
+	"github.com/storacha/testthenetwork/internal/tracing"
 	"github.com/storacha/testthenetwork/internal/upload"
 	"github.com/stretchr/testify/require"
 )
@@ -90,34 +92,20 @@ func generateProofs(t *testing.T, storageID, indexingID principal.Signer, upload
 			),
 		)(t),
 	)
-	// proof alice can invoke on indexing service
-	aliceIndexingProof = delegation.FromDelegation(
-		testutil.Must(
-			delegation.Delegate(
-				indexingID,
-				aliceID,
-				[]ucan.Capability[ucan.NoCaveats]{
-					ucan.NewCapability(
-						assert.EqualsAbility,
-						indexingID.DID().String(),
-						ucan.NoCaveats{},
-					),
-					ucan.NewCapability(
-						assert.IndexAbility,
-						indexingID.DID().String(),
-						ucan.NoCaveats{},
-					),
-				},
-				delegation.WithNoExpiration(),
-			),
-		)(t),
-	)
-	// proof bob can invoke on indexing service
-	bobIndexingProof = delegation.FromDelegation(
+	// proof alice and bob can invoke on indexing service
+	aliceIndexingProof = indexingProof(t, indexingID, aliceID)
+	bobIndexingProof = indexingProof(t, indexingID, bobID)
+	return
+}
+
+// indexingProof creates a proof the issuer can publish equals and index claims
+// to the indexing service with.
+func indexingProof(t *testing.T, indexingID principal.Signer, issuer ucan.Principal) delegation.Proof {
+	return delegation.FromDelegation(
 		testutil.Must(
 			delegation.Delegate(
 				indexingID,
-				bobID,
+				issuer,
 				[]ucan.Capability[ucan.NoCaveats]{
 					ucan.NewCapability(
 						assert.EqualsAbility,
@@ -134,9 +122,27 @@ func generateProofs(t *testing.T, storageID, indexingID principal.Signer, upload
 			),
 		)(t),
 	)
-	return
 }
 
+// components creates the services the scenarios run against.
+var components = bootstrap.InProcess()
+
+// network is a started network of services and the clients for it.
+type network struct {
+	IPNI           bootstrap.IPNIService
+	Indexing       bootstrap.IndexingService
+	Storage        bootstrap.StorageNode
+	IndexingClient *client.Client
+	UploadService  *upload.UploadService
+	// Caches are the indexing service caches, if it exposes them, which the
+	// in-process implementation does unless caching is disabled.
+	Caches *bootstrap.IndexingCaches
+}
+
+// startServices starts the services of the network and creates clients for
+// them. The indexing service caches are returned when the indexing service
+// exposes them, which the in-process implementation does unless caching is
+// disabled.
 func startServices(
 	t *testing.T,
 	ipniFindURL, ipniAnnounceURL url.URL,
@@ -148,173 +154,256 @@ func startServices(
 	indexingNoCache bool,
 	uploadID principal.Signer,
 	uploadStorageProof delegation.Proof,
-) (*upload.UploadService, *client.Client) {
-	fmt.Println("→ starting IPNI service")
-	closeIPNI := bootstrap.StartIPNIService(t, ipniFindURL, ipniAnnounceURL)
-	t.Cleanup(closeIPNI)
-	fmt.Printf("✔ IPNI find and announce services running at %s and %s\n", ipniFindURL.String(), ipniAnnounceURL.String())
-
-	fmt.Println("→ starting indexing service")
-	closeIndexing := bootstrap.StartIndexingService(t, indexingID, indexingURL, ipniFindURL, ipniAnnounceURL, indexingNoCache)
-	t.Cleanup(closeIndexing)
-	fmt.Printf("✔ indexing service (%s) running at %s\n", indexingID.DID(), indexingURL.String())
-
-	fmt.Println("→ starting storage node")
-	closeStorage := bootstrap.StartStorageNode(t, storageID, storageURL, ipniAnnounceURL, indexingID, indexingURL, storageIndexingProof)
-	t.Cleanup(closeStorage)
-	fmt.Printf("✔ storage node (%s) running at %s\n", storageID.DID(), storageURL.String())
-
-	fmt.Println("→ creating indexing service client")
+	clk clock.Clock,
+) (*upload.UploadService, *client.Client, *bootstrap.IndexingCaches) {
+	n := startNetwork(t, ipniFindURL, ipniAnnounceURL, storageID, storageURL, storageIndexingProof, indexingID, indexingURL, indexingNoCache, uploadID, uploadStorageProof, clk)
+	return n.UploadService, n.IndexingClient, n.Caches
+}
+
+// startNetwork is like startServices but returns the services too, so they can
+// be restarted.
+func startNetwork(
+	t *testing.T,
+	ipniFindURL, ipniAnnounceURL url.URL,
+	storageID principal.Signer,
+	storageURL url.URL,
+	storageIndexingProof delegation.Proof,
+	indexingID principal.Signer,
+	indexingURL url.URL,
+	indexingNoCache bool,
+	uploadID principal.Signer,
+	uploadStorageProof delegation.Proof,
+	clk clock.Clock,
+) *network {
+	tracing.Start(t)
+	logs := report.CaptureLogs(t)
+	forensics.Add(t, "service-logs.txt", forensics.Text(logs))
+	n := &network{}
+
+	step := steps.Start(t, "start IPNI service", steps.A("find", ipniFindURL.String()), steps.A("announce", ipniAnnounceURL.String()))
+	n.IPNI = components.IPNI(t, bootstrap.IPNIConfig{FindURL: ipniFindURL, AnnounceURL: ipniAnnounceURL})
+	bootstrap.StartService(t, n.IPNI)
+	step.Done()
+
+	step = steps.Start(t, "start indexing service", steps.A("id", indexingID.DID()), steps.A("url", indexingURL.String()))
+	n.Indexing = components.Indexing(t, bootstrap.IndexingServiceConfig{
+		ID:              indexingID,
+		PublicURL:       indexingURL,
+		IPNIFindURL:     ipniFindURL,
+		IPNIAnnounceURL: ipniAnnounceURL,
+		NoCache:         indexingNoCache,
+		Clock:           clk,
+	})
+	bootstrap.StartService(t, n.Indexing)
+	if c, ok := n.Indexing.(interface {
+		Caches() *bootstrap.IndexingCaches
+	}); ok {
+		n.Caches = c.Caches()
+	}
+	step.Done()
+
+	step = steps.Start(t, "start storage node", steps.A("id", storageID.DID()), steps.A("url", storageURL.String()))
+	n.Storage = components.Storage(t, bootstrap.StorageNodeConfig{
+		ID:                   storageID,
+		PublicURL:            storageURL,
+		IPNIAnnounceURL:      ipniAnnounceURL,
+		IndexingServiceID:    indexingID,
+		IndexingServiceURL:   indexingURL,
+		IndexingServiceProof: storageIndexingProof,
+	})
+	bootstrap.StartService(t, n.Storage)
+	step.Done()
+
+	step = steps.Start(t, "create indexing service client")
 	indexingClient, err := client.New(indexingID, indexingURL)
 	require.NoError(t, err)
-	fmt.Printf("✔ indexing service client created\n")
+	n.IndexingClient = indexingClient
+	step.Done()
 
-	fmt.Println("→ creating upload service")
-	uploadService := upload.NewService(t, upload.Config{
+	step = steps.Start(t, "create upload service", steps.A("id", uploadID.DID()))
+	n.UploadService = upload.NewService(t, upload.Config{
 		ID:             uploadID,
 		StorageNodeID:  storageID,
 		StorageNodeURL: storageURL,
 		StorageProof:   uploadStorageProof,
+		Clock:          clk,
 	})
-	fmt.Printf("✔ upload service (%s) created\n", uploadID.DID())
+	step.Done()
+
+	forensics.Capture(t)
 
-	return uploadService, indexingClient
+	return n
 }
 
-func generateContent(t *testing.T, size int) (ipld.Link, multihash.Multihash, multihash.Multihash, []byte) {
-	fmt.Println("→ generating content")
-	root, rootDigest, digest, data := testutil.RandomCAR(t, size)
-	fmt.Printf("✔ generation success\n")
-	fmt.Printf("  root: %s (%s)\n", root.String(), digestutil.Format(rootDigest))
-	fmt.Printf("  blob: %s\n", digestutil.Format(digest))
+// startSuiteNetwork starts a network of the selected components for a
+// scenario of the suite package, returning it and the started services.
+func startSuiteNetwork(t *testing.T, clk clock.Clock, indexingNoCache bool) (*suite.Network, *network) {
+	storageID, indexingID, uploadID, aliceID, bobID := generateIdentities(t)
+	ipniFindURL, ipniAnnounceURL, storageURL, indexingURL := generateURLs(t)
+	storageIndexingProof, uploadStorageProof, aliceIndexingProof, bobIndexingProof := generateProofs(t, storageID, indexingID, uploadID, aliceID, bobID)
+	n := startNetwork(t, ipniFindURL, ipniAnnounceURL, storageID, storageURL, storageIndexingProof, indexingID, indexingURL, indexingNoCache, uploadID, uploadStorageProof, clk)
+	return &suite.Network{
+		Clock:          clk,
+		StorageID:      storageID,
+		StorageURL:     storageURL,
+		IndexingID:     indexingID,
+		UploadService:  n.UploadService,
+		IndexingClient: n.IndexingClient,
+		Alice:          aliceID,
+		AliceProof:     aliceIndexingProof,
+		Bob:            bobID,
+		BobProof:       bobIndexingProof,
+	}, n
+}
+
+// generateContent generates a CAR of random content, hashing the content and
+// the CAR with the passed multihash function.
+func generateContent(t *testing.T, size int, hash uint64) (ipld.Link, multihash.Multihash, multihash.Multihash, []byte) {
+	step := steps.Start(t, "generate content", steps.A("size", size), steps.A("hash", digestutil.HashName(hash)))
+	defer step.Done()
+	root, rootDigest, digest, data := testutil.RandomCARWithHash(t, size, hash)
+	step.Set(steps.A("root", root), steps.A("root_digest", digestutil.Format(rootDigest)), steps.A("blob", digestutil.Format(digest)))
 	return root, rootDigest, digest, data
 }
 
-func putBlob(t *testing.T, location url.URL, headers http.Header, data []byte) {
-	fmt.Printf("→ performing http/put to %s\n", location.String())
-	req, err := http.NewRequest("PUT", location.String(), bytes.NewReader(data))
-	require.NoError(t, err)
-	req.Header = headers
+// indexedContent is content that has been uploaded to the storage node along
+// with its index.
+type indexedContent struct {
+	Root        ipld.Link
+	RootDigest  multihash.Multihash
+	Digest      multihash.Multihash
+	Data        []byte
+	IndexDigest multihash.Multihash
+	IndexLink   ipld.Link
+	IndexData   []byte
+}
 
-	res, err := http.DefaultClient.Do(req)
+// uploadIndexedContent generates content, uploads it and its index to the
+// storage node in the passed space and publishes an index claim for it. The
+// content, the CAR and the index are hashed with the passed multihash function.
+func uploadIndexedContent(
+	t *testing.T,
+	uploadService *upload.UploadService,
+	indexingClient *client.Client,
+	clk clock.Clock,
+	issuer principal.Signer,
+	proof delegation.Proof,
+	space did.DID,
+	size int,
+	hash uint64,
+) indexedContent {
+	root, rootDigest, _, data := generateContent(t, size, hash)
+	return uploadIndexedCAR(t, uploadService, indexingClient, clk, issuer, proof, space, root, rootDigest, data, hash)
+}
+
+// uploadIndexedCAR uploads a CAR and its index, generated from the blocks of
+// the CAR and hashed with the passed multihash function, to the space and
+// publishes an index claim for its root.
+func uploadIndexedCAR(
+	t *testing.T,
+	uploadService *upload.UploadService,
+	indexingClient *client.Client,
+	clk clock.Clock,
+	issuer principal.Signer,
+	proof delegation.Proof,
+	space did.DID,
+	root ipld.Link,
+	rootDigest multihash.Multihash,
+	data []byte,
+	hash uint64,
+) indexedContent {
+	digest, err := multihash.Sum(data, hash, -1)
 	require.NoError(t, err)
-	require.Equal(t, http.StatusOK, res.StatusCode)
-	fmt.Println("✔ index http/put success")
+
+	address := uploadService.BlobAdd(t, space, digest, uint64(len(data)))
+	require.NotNil(t, address)
+	netstep.PutBlob(t, address.URL, address.Headers, data)
+	uploadService.ConcludeHTTPPut(t, space, digest, uint64(len(data)))
+
+	_, indexDigest, indexLink, indexData := generateIndex(t, root, data, hash)
+
+	address = uploadService.BlobAdd(t, space, indexDigest, uint64(len(indexData)))
+	require.NotNil(t, address)
+	netstep.PutBlob(t, address.URL, address.Headers, indexData)
+	uploadService.ConcludeHTTPPut(t, space, indexDigest, uint64(len(indexData)))
+
+	netstep.PublishIndexClaim(t, indexingClient, clk, issuer, proof, root, indexLink)
+
+	return indexedContent{root, rootDigest, digest, data, indexDigest, indexLink, indexData}
 }
 
 func decodeLocationCommitmentCaveats(t *testing.T, claim delegation.Delegation) assert.LocationCaveats {
-	fmt.Println("→ decoding location commitment")
+	step := steps.Start(t, "decode location commitment", steps.A("claim", claim.Link()))
+	defer step.Done()
 	nb, rerr := assert.LocationCaveatsReader.Read(claim.Capabilities()[0].Nb())
 	require.NoError(t, rerr)
-	fmt.Printf("✔ decode success - %s @ %s\n", claim.Capabilities()[0].Can(), nb.Location[0].String())
+	step.Set(steps.A("can", claim.Capabilities()[0].Can()), steps.A("url", nb.Location[0].String()))
 	return nb
 }
 
-func fetchBlob(t *testing.T, location url.URL) ([]byte, multihash.Multihash) {
-	fmt.Printf("→ fetching blob from %s\n", location.String())
-	req, err := http.Get(location.String())
-	require.NoError(t, err)
-	require.Equal(t, http.StatusOK, req.StatusCode)
-	data, err := io.ReadAll(req.Body)
-	require.NoError(t, err)
-	digest, err := multihash.Sum(data, multihash.SHA2_256, -1)
-	require.NoError(t, err)
-	fmt.Println("✔ fetch success")
-	return data, digest
-}
-
-func generateIndex(t *testing.T, content ipld.Link, carBytes []byte) (blobindex.ShardedDagIndexView, multihash.Multihash, ipld.Link, []byte) {
-	fmt.Println("→ generating index")
-	index, err := blobindex.FromShardArchives(content, [][]byte{carBytes})
-	require.NoError(t, err)
+// generateIndex generates an index of the content in the CAR. The CAR and the
+// index archive are hashed with the passed multihash function.
+func generateIndex(t *testing.T, content ipld.Link, carBytes []byte, hash uint64) (blobindex.ShardedDagIndexView, multihash.Multihash, ipld.Link, []byte) {
+	step := steps.Start(t, "generate index", steps.A("content", content))
+	defer step.Done()
+	index := indexShards(t, content, hash, carBytes)
 	bytes, err := io.ReadAll(testutil.Must(index.Archive())(t))
 	require.NoError(t, err)
-	digest, err := multihash.Sum(bytes, multihash.SHA2_256, -1)
+	digest, err := multihash.Sum(bytes, hash, -1)
 	require.NoError(t, err)
-	link := cidlink.Link{Cid: cid.NewCidV1(uint64(multicodec.Car), digest)}
-	fmt.Printf("✔ index created: %s (%s)\n", link.String(), digestutil.Format(digest))
+	link := digestutil.CARLink(digest)
+	step.Set(steps.A("index", link), steps.A("digest", digestutil.Format(digest)))
 	return index, digest, link, bytes
 }
 
-func CollectIndexes(t *testing.T, result types.QueryResult) []blobindex.ShardedDagIndexView {
-	br, err := blockstore.NewBlockReader(blockstore.WithBlocksIterator(result.Blocks()))
-	require.NoError(t, err)
-
-	var indexes []blobindex.ShardedDagIndexView
-	for _, link := range result.Indexes() {
-		b, ok, err := br.Get(link)
+// indexShards indexes the blocks in the CAR shards, like
+// blobindex.FromShardArchives, but hashes the shards with the passed multihash
+// function rather than always using SHA2-256.
+func indexShards(t *testing.T, content ipld.Link, hash uint64, shards ...[]byte) blobindex.ShardedDagIndexView {
+	index := blobindex.NewShardedDagIndexView(content, len(shards))
+	for _, shard := range shards {
+		digest, err := multihash.Sum(shard, hash, -1)
 		require.NoError(t, err)
-		require.True(t, ok)
-
-		index, err := blobindex.Extract(bytes.NewReader(b.Bytes()))
+		_, blocks, err := car.Decode(bytes.NewReader(shard))
 		require.NoError(t, err)
-		indexes = append(indexes, index)
+		for blk, err := range blocks {
+			require.NoError(t, err)
+			cb := blk.(car.CarBlock)
+			index.SetSlice(digest, digestutil.ExtractDigest(blk.Link()), blobindex.Position{
+				Offset: cb.Offset(),
+				Length: cb.Length(),
+			})
+		}
 	}
-	return indexes
+	return index
 }
 
-func CollectClaims(t *testing.T, result types.QueryResult) []delegation.Delegation {
-	br, err := blockstore.NewBlockReader(blockstore.WithBlocksIterator(result.Blocks()))
-	require.NoError(t, err)
-
-	var claims []delegation.Delegation
-	for _, link := range result.Claims() {
-		claim, err := delegation.NewDelegationView(link, br)
-		require.NoError(t, err)
-		claims = append(claims, claim)
+// writeGraphArtifacts writes Mermaid and DOT graphs of the query results as
+// artifacts of the test.
+func writeGraphArtifacts(t *testing.T, result types.QueryResult) {
+	for _, format := range []printer.GraphFormat{printer.GraphMermaid, printer.GraphDOT} {
+		testutil.WriteArtifact(t, "query-results."+format.Ext(), printer.QueryResultsGraph(t, format, result))
 	}
-	return claims
 }
 
-func ContainsIndexClaim(t *testing.T, claims []delegation.Delegation, content ipld.Link, index ipld.Link) bool {
-	return slices.ContainsFunc(claims, func(claim delegation.Delegation) bool {
-		cap := claim.Capabilities()[0]
-		if cap.Can() != assert.IndexAbility {
-			return false
-		}
-		nb, err := assert.IndexCaveatsReader.Read(cap.Nb())
-		require.NoError(t, err)
-		return nb.Content == content && nb.Index == index
-	})
+// matchQueryResultsSnapshot compares the query results with the golden file of
+// the test, once normalized. Name the DIDs, digests and URLs the results are
+// expected to contain so the golden file says what they are.
+func matchQueryResultsSnapshot(t *testing.T, result types.QueryResult, clk clock.Clock, names ...snapshot.Option) {
+	n := snapshot.New(append([]snapshot.Option{snapshot.WithNow(clk.Now())}, names...)...)
+	snapshot.Match(t, "query-results", snapshot.QueryResults(t, n, result))
 }
 
-func ContainsLocationCommitment(t *testing.T, claims []delegation.Delegation, content multihash.Multihash, space did.DID) bool {
-	return slices.ContainsFunc(claims, func(claim delegation.Delegation) bool {
-		cap := claim.Capabilities()[0]
-		if cap.Can() != assert.LocationAbility {
-			return false
-		}
-		nb, err := assert.LocationCaveatsReader.Read(cap.Nb())
-		require.NoError(t, err)
-		return bytes.Equal(nb.Content.Hash(), content) && nb.Space == space
-	})
-}
-
-func publishIndexClaim(t *testing.T, indexingClient *client.Client, issuer principal.Signer, proof delegation.Proof, content ipld.Link, index ipld.Link) {
-	fmt.Printf("→ performing assert/index with %s\n", index.String())
-	err := indexingClient.PublishIndexClaim(context.Background(), issuer, assert.IndexCaveats{
-		Content: content,
-		Index:   index,
-	}, delegation.WithProof(proof))
-	require.NoError(t, err)
-	fmt.Println("✔ assert/index success")
-}
-
-func QueryClaims(t *testing.T, indexingClient *client.Client, digest multihash.Multihash, space did.DID) types.QueryResult {
-	if space == did.Undef {
-		fmt.Printf("→ performing query for %s\n", digestutil.Format(digest))
-	} else {
-		fmt.Printf("→ performing query for %s, filtered by space %s\n", digestutil.Format(digest), space.String())
-	}
-	var match types.Match
-	if space != did.Undef {
-		match.Subject = append(match.Subject, space)
+// snapshotNames names the services, space and content of a test for
+// snapshots of its query results.
+func snapshotNames(storageID, indexingID principal.Signer, storageURL url.URL, space did.DID, root ipld.Link, shard multihash.Multihash, index ipld.Link) []snapshot.Option {
+	return []snapshot.Option{
+		snapshot.WithName(storageID.DID(), "storage"),
+		snapshot.WithName(indexingID.DID(), "indexing"),
+		snapshot.WithName(storageURL, "storage"),
+		snapshot.WithName(space, "space"),
+		snapshot.WithName(root, "root"),
+		snapshot.WithName(shard, "shard"),
+		snapshot.WithName(index, "index"),
 	}
-	result, err := indexingClient.QueryClaims(context.Background(), types.Query{
-		Hashes: []multihash.Multihash{digest},
-		Match:  match,
-	})
-	require.NoError(t, err)
-	fmt.Println("✔ query success")
-	return result
 }
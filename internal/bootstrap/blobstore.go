@@ -0,0 +1,56 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/storage/pkg/store/blobstore"
+	"github.com/storacha/testthenetwork/internal/digestutil"
+)
+
+// listingBlobstore is an in-memory blobstore that keeps a listing of the blobs
+// written to it, which the map blobstore does not expose.
+type listingBlobstore struct {
+	*blobstore.MapBlobstore
+	mu    sync.Mutex
+	sizes map[string]uint64
+}
+
+var (
+	_ blobstore.Blobstore    = (*listingBlobstore)(nil)
+	_ blobstore.FileSystemer = (*listingBlobstore)(nil)
+)
+
+func newListingBlobstore() *listingBlobstore {
+	return &listingBlobstore{MapBlobstore: blobstore.NewMapBlobstore(), sizes: map[string]uint64{}}
+}
+
+func (b *listingBlobstore) Put(ctx context.Context, digest multihash.Multihash, size uint64, body io.Reader) error {
+	if err := b.MapBlobstore.Put(ctx, digest, size, body); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sizes[digestutil.Format(digest)] = size
+	return nil
+}
+
+// WriteListing writes the digest and size of each blob in the store.
+func (b *listingBlobstore) WriteListing(w io.Writer) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var digests []string
+	for d := range b.sizes {
+		digests = append(digests, d)
+	}
+	sort.Strings(digests)
+	fmt.Fprintf(w, "# Blobs (%d)\n\n", len(digests))
+	for _, d := range digests {
+		fmt.Fprintf(w, "%s (%d bytes)\n", d, b.sizes[d])
+	}
+	return nil
+}
@@ -1,200 +1,200 @@
+// Package bootstrap starts the services of the network: an IPNI service, an
+// indexing service and a storage node. Each role is an interface, so that an
+// alternative implementation, such as a lightweight fake or a service running
+// in another process, can be used in place of the in-process default without
+// changing the scenarios that run against it.
 package bootstrap
 
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"testing"
 	"time"
 
-	"github.com/alanshaw/storetheindex/config"
-	"github.com/alanshaw/storetheindex/ingest"
-	"github.com/alanshaw/storetheindex/registry"
-	httpfind "github.com/alanshaw/storetheindex/server/find"
-	httpingest "github.com/alanshaw/storetheindex/server/ingest"
-	"github.com/ipfs/go-datastore"
-	dssync "github.com/ipfs/go-datastore/sync"
-	"github.com/ipni/go-indexer-core/engine"
-	"github.com/ipni/go-indexer-core/store/memory"
-	"github.com/ipni/go-libipni/maurl"
-	"github.com/libp2p/go-libp2p"
-	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/storacha/go-ucanto/core/delegation"
 	"github.com/storacha/go-ucanto/principal"
 	"github.com/storacha/go-ucanto/ucan"
-	"github.com/storacha/indexing-service/pkg/construct"
-	idxsrv "github.com/storacha/indexing-service/pkg/server"
-	"github.com/storacha/storage/pkg/server"
-	"github.com/storacha/storage/pkg/service/storage"
-	"github.com/storacha/storage/pkg/store/blobstore"
-	"github.com/storacha/testthenetwork/internal/redis"
-	rsync "github.com/storacha/testthenetwork/internal/redis/sync"
-	"github.com/storacha/testthenetwork/internal/testutil"
+	"github.com/storacha/testthenetwork/internal/clock"
+	"github.com/storacha/testthenetwork/internal/tracing"
 	"github.com/stretchr/testify/require"
 )
 
-func StartIPNIService(
-	t *testing.T,
-	findURL url.URL,
-	announceURL url.URL,
-) func() {
-	indexerCore := engine.New(memory.New())
-
-	reg, err := registry.New(
-		context.Background(),
-		config.NewDiscovery(),
-		dssync.MutexWrap(datastore.NewMapDatastore()),
-	)
-	require.NoError(t, err)
-
-	p2pHost, err := libp2p.New()
-	require.NoError(t, err)
-
-	ingConfig := config.NewIngest()
-	ingConfig.PubSubTopic = "/storacha/indexer/ingest/testnet"
-	ing, err := ingest.NewIngester(
-		ingConfig,
-		p2pHost,
-		indexerCore,
-		reg,
-		dssync.MutexWrap(datastore.NewMapDatastore()),
-		dssync.MutexWrap(datastore.NewMapDatastore()),
-	)
-	require.NoError(t, err)
+// Service is a service of the network that can be started and stopped, and
+// restarted once stopped.
+type Service interface {
+	// Start starts the service, failing the test if it cannot be started.
+	Start(t *testing.T)
+	// Stop stops the service.
+	Stop()
+	// Health returns an error if the service is not serving requests.
+	Health(ctx context.Context) error
+}
 
-	announceAddr := fmt.Sprintf("%s:%s", announceURL.Hostname(), announceURL.Port())
-	ingSvr, err := httpingest.New(announceAddr, indexerCore, ing, reg)
-	require.NoError(t, err)
+// IPNIService is an IPNI service, which storage nodes and the indexing service
+// announce advertisements to and the indexing service finds providers with.
+type IPNIService interface {
+	Service
+	// FindURL is the URL find requests are served on.
+	FindURL() url.URL
+	// AnnounceURL is the URL announcements of advertisements are accepted on.
+	AnnounceURL() url.URL
+}
 
-	var ingStartErr error
-	go func() {
-		ingStartErr = ingSvr.Start()
-	}()
+// IndexingService is an indexing service, which caches and publishes claims
+// and answers queries for them.
+type IndexingService interface {
+	Service
+	ID() principal.Signer
+	// URL is the URL the indexing service API is served on.
+	URL() url.URL
+}
 
-	findAddr := fmt.Sprintf("%s:%s", findURL.Hostname(), findURL.Port())
-	findSvr, err := httpfind.New(findAddr, indexerCore, reg)
-	require.NoError(t, err)
+// StorageNode is a storage node, which stores blobs and issues location
+// commitments for them.
+type StorageNode interface {
+	Service
+	ID() principal.Signer
+	// URL is the URL the UCAN endpoint and blobs are served on.
+	URL() url.URL
+}
 
-	var findStartErr error
-	go func() {
-		findStartErr = findSvr.Start()
-	}()
-
-	time.Sleep(time.Millisecond * 100)
-	require.NoError(t, ingStartErr)
-	require.NoError(t, findStartErr)
-
-	return func() {
-		ingSvr.Close()
-		ing.Close()
-		findSvr.Close()
-		reg.Close()
-		indexerCore.Close()
-		p2pHost.Close()
-	}
+// IPNIConfig configures an IPNI service.
+type IPNIConfig struct {
+	FindURL     url.URL
+	AnnounceURL url.URL
 }
 
-func StartIndexingService(
-	t *testing.T,
-	id principal.Signer,
-	publicURL url.URL,
-	indexerURL url.URL,
-	directAnnounceURL url.URL,
-	noCache bool,
-) func() {
-	privKey, err := crypto.UnmarshalEd25519PrivateKey(id.Raw())
-	require.NoError(t, err)
+// IndexingServiceConfig configures an indexing service.
+type IndexingServiceConfig struct {
+	ID        principal.Signer
+	PublicURL url.URL
+	// IPNIFindURL is the URL of the IPNI service to find providers with.
+	IPNIFindURL url.URL
+	// IPNIAnnounceURL is the URL of the IPNI service to announce advertisements
+	// of published claims to.
+	IPNIAnnounceURL url.URL
+	// NoCache disables the caching of providers, claims and indexes.
+	NoCache bool
+	// Clock is used to expire cached entries. It defaults to the system clock.
+	Clock clock.Clock
+}
 
-	publisherListenURL := testutil.RandomLocalURL(t)
-	announceAddr, err := maurl.FromURL(&publisherListenURL)
-	require.NoError(t, err)
+// StorageNodeConfig configures a storage node.
+type StorageNodeConfig struct {
+	ID        principal.Signer
+	PublicURL url.URL
+	// IPNIAnnounceURL is the URL of the IPNI service to announce advertisements
+	// of location commitments to.
+	IPNIAnnounceURL url.URL
+	// IndexingServiceID is the DID of the indexing service to cache location
+	// commitments with.
+	IndexingServiceID ucan.Principal
+	// IndexingServiceURL is the URL of the indexing service.
+	IndexingServiceURL url.URL
+	// IndexingServiceProof is a delegation allowing the storage node to invoke
+	// claim/cache on the indexing service.
+	IndexingServiceProof delegation.Proof
+}
 
-	cfg := construct.ServiceConfig{
-		PrivateKey:                  privKey,
-		PublicURL:                   []string{publicURL.String()},
-		IndexerURL:                  indexerURL.String(),
-		PublisherDirectAnnounceURLs: []string{directAnnounceURL.String()},
-		PublisherListenAddr:         fmt.Sprintf("%s:%s", publisherListenURL.Hostname(), publisherListenURL.Port()),
-		PublisherAnnounceAddrs:      []string{announceAddr.String()},
-	}
+// Components creates the services of the network. The services it creates are
+// not started.
+type Components struct {
+	IPNI     func(t *testing.T, cfg IPNIConfig) IPNIService
+	Indexing func(t *testing.T, cfg IndexingServiceConfig) IndexingService
+	Storage  func(t *testing.T, cfg StorageNodeConfig) StorageNode
+}
 
-	var indexer construct.Service
-	if noCache {
-		indexer, err = construct.Construct(
-			cfg,
-			construct.WithStartIPNIServer(true),
-			construct.WithDatastore(dssync.MutexWrap(datastore.NewMapDatastore())),
-			construct.WithProvidersClient(redis.NewBlackholeRedis()),
-			construct.WithClaimsClient(redis.NewBlackholeRedis()),
-			construct.WithIndexesClient(redis.NewBlackholeRedis()),
-		)
-	} else {
-		indexer, err = construct.Construct(
-			cfg,
-			construct.WithStartIPNIServer(true),
-			construct.WithDatastore(dssync.MutexWrap(datastore.NewMapDatastore())),
-			construct.WithProvidersClient(rsync.MutexWrap(redis.NewMapRedis())),
-			construct.WithClaimsClient(rsync.MutexWrap(redis.NewMapRedis())),
-			construct.WithIndexesClient(rsync.MutexWrap(redis.NewMapRedis())),
-		)
+// InProcess returns the components that run storetheindex, the indexing
+// service and the storage node in this process.
+func InProcess() Components {
+	return Components{
+		IPNI: func(t *testing.T, cfg IPNIConfig) IPNIService {
+			return NewInProcessIPNI(t, cfg)
+		},
+		Indexing: func(t *testing.T, cfg IndexingServiceConfig) IndexingService {
+			return NewInProcessIndexingService(t, cfg)
+		},
+		Storage: func(t *testing.T, cfg StorageNodeConfig) StorageNode {
+			return NewInProcessStorageNode(t, cfg)
+		},
 	}
-	require.NoError(t, err)
-
-	err = indexer.Startup(context.Background())
-	require.NoError(t, err)
+}
 
-	go func() {
-		addr := fmt.Sprintf("%s:%s", publicURL.Hostname(), publicURL.Port())
-		err = idxsrv.ListenAndServe(addr, indexer, idxsrv.WithIdentity(id))
-	}()
+// NetworkEnv is the environment variable that selects the components the
+// network is started from. A name passed to Select takes precedence over it.
+const NetworkEnv = "TTN_NETWORK"
 
-	time.Sleep(time.Millisecond * 100)
-	require.NoError(t, err)
-
-	return func() {
-		indexer.Shutdown(context.Background())
+// Select returns the components with the name, such as the value of a
+// -network flag, or those named by the TTN_NETWORK environment variable if the
+// name is empty, and their name.
+func Select(name string) (Components, string, error) {
+	if name == "" {
+		name = os.Getenv(NetworkEnv)
+	}
+	switch name {
+	case "", "in-process":
+		return InProcess(), "in-process", nil
+	case "subprocess":
+		return Subprocess(), name, nil
 	}
+	return Components{}, "", fmt.Errorf("unknown network %q, expected in-process or subprocess", name)
 }
 
-func StartStorageNode(
-	t *testing.T,
-	id principal.Signer,
-	publicURL url.URL,
-	announceURL url.URL,
-	indexingServiceDID ucan.Principal,
-	indexingServiceURL url.URL,
-	indexingServiceProof delegation.Proof,
-) func() {
-	svc, err := storage.New(
-		storage.WithIdentity(id),
-		storage.WithBlobstore(blobstore.NewMapBlobstore()),
-		storage.WithAllocationDatastore(dssync.MutexWrap(datastore.NewMapDatastore())),
-		storage.WithClaimDatastore(dssync.MutexWrap(datastore.NewMapDatastore())),
-		storage.WithPublisherDatastore(dssync.MutexWrap(datastore.NewMapDatastore())),
-		storage.WithPublicURL(publicURL),
-		storage.WithPublisherDirectAnnounce(announceURL),
-		storage.WithPublisherIndexingServiceConfig(indexingServiceDID, *indexingServiceURL.JoinPath("claims")),
-		storage.WithPublisherIndexingServiceProof(indexingServiceProof),
-	)
-	require.NoError(t, err)
-
-	srvMux, err := server.NewServer(svc)
-	require.NoError(t, err)
+// StartService starts the service and waits for it to be healthy, stopping it
+// when the test completes.
+func StartService(t *testing.T, svc Service) {
+	svc.Start(t)
+	t.Cleanup(svc.Stop)
+	WaitHealthy(t, svc, 5*time.Second)
+}
 
-	httpServer := &http.Server{
-		Addr:    fmt.Sprintf("%s:%s", publicURL.Hostname(), publicURL.Port()),
-		Handler: srvMux,
+// WaitHealthy waits for the service to be healthy, failing the test if it is
+// not within the timeout.
+func WaitHealthy(t *testing.T, svc Service, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for {
+		err := svc.Health(ctx)
+		if err == nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			require.NoError(t, err, "service not healthy after %s", timeout)
+			return
+		case <-time.After(50 * time.Millisecond):
+		}
 	}
+}
 
-	go func() {
-		err = httpServer.ListenAndServe()
-	}()
+// CheckHTTP returns an error if a GET request to the URL fails or is answered
+// with a server error.
+func CheckHTTP(ctx context.Context, u url.URL) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("GET %s: %s", u.String(), res.Status)
+	}
+	return nil
+}
 
-	time.Sleep(time.Millisecond * 100)
+// serve serves the handler on the host and port of the URL until the returned
+// server is closed, tracing requests as the operation.
+func serve(t *testing.T, u url.URL, h http.Handler, operation string) *http.Server {
+	listener, err := net.Listen("tcp", u.Host)
 	require.NoError(t, err)
-
-	return func() {
-		httpServer.Close()
-	}
+	srv := &http.Server{Handler: tracing.Handler(h, operation)}
+	go srv.Serve(listener)
+	return srv
 }
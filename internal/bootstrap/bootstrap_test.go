@@ -0,0 +1,35 @@
+package bootstrap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/storacha/testthenetwork/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestart(t *testing.T) {
+	ctx := context.Background()
+	indexingID := testutil.RandomSigner(t)
+	node := NewInProcessStorageNode(t, StorageNodeConfig{
+		ID:                   testutil.RandomSigner(t),
+		PublicURL:            testutil.RandomLocalURL(t),
+		IPNIAnnounceURL:      testutil.RandomLocalURL(t),
+		IndexingServiceID:    indexingID,
+		IndexingServiceURL:   testutil.RandomLocalURL(t),
+		IndexingServiceProof: delegation.FromDelegation(testutil.Must(delegation.Delegate(indexingID, indexingID, []ucan.Capability[ucan.NoCaveats]{}))(t)),
+	})
+	require.Error(t, node.Health(ctx))
+
+	StartService(t, node)
+	require.NoError(t, node.Health(ctx))
+
+	node.Stop()
+	require.Error(t, node.Health(ctx))
+
+	node.Start(t)
+	WaitHealthy(t, node, time.Second)
+}
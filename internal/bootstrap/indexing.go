@@ -0,0 +1,187 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipni/go-libipni/maurl"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/storacha/go-ucanto/principal"
+	"github.com/storacha/indexing-service/pkg/construct"
+	idxsrv "github.com/storacha/indexing-service/pkg/server"
+	"github.com/storacha/testthenetwork/internal/clock"
+	"github.com/storacha/testthenetwork/internal/forensics"
+	"github.com/storacha/testthenetwork/internal/redis"
+	"github.com/storacha/testthenetwork/internal/redis/fault"
+	"github.com/storacha/testthenetwork/internal/redis/record"
+	"github.com/storacha/testthenetwork/internal/redis/span"
+	rsync "github.com/storacha/testthenetwork/internal/redis/sync"
+	"github.com/storacha/testthenetwork/internal/testutil"
+	"github.com/storacha/testthenetwork/internal/tracing"
+	"github.com/stretchr/testify/require"
+)
+
+// IndexingCaches provides access to the contents of the indexing service
+// caches and the commands the service sent to them, and allows faults to be
+// injected into calls to them.
+type IndexingCaches struct {
+	Providers redis.ProvidersCache
+	Claims    redis.ClaimsCache
+	Indexes   redis.IndexesCache
+
+	ProvidersRecorder *record.RecordingRedis
+	ClaimsRecorder    *record.RecordingRedis
+	IndexesRecorder   *record.RecordingRedis
+
+	ProvidersFaults *fault.FaultyRedis
+	ClaimsFaults    *fault.FaultyRedis
+	IndexesFaults   *fault.FaultyRedis
+}
+
+// Stats returns the command stats for each cache.
+func (c *IndexingCaches) Stats() []record.Stats {
+	return []record.Stats{
+		c.ProvidersRecorder.Stats(),
+		c.ClaimsRecorder.Stats(),
+		c.IndexesRecorder.Stats(),
+	}
+}
+
+// ClearFaults removes all fault rules from each cache.
+func (c *IndexingCaches) ClearFaults() {
+	c.ProvidersFaults.Clear()
+	c.ClaimsFaults.Clear()
+	c.IndexesFaults.Clear()
+}
+
+// ResetRecorders discards the commands recorded so far for each cache.
+func (c *IndexingCaches) ResetRecorders() {
+	c.ProvidersRecorder.Reset()
+	c.ClaimsRecorder.Reset()
+	c.IndexesRecorder.Reset()
+}
+
+// newIndexingCaches creates in-memory caches for an indexing service, with
+// recorders and fault injection, and adds their contents to the forensics of
+// the test.
+func newIndexingCaches(t *testing.T, clk clock.Clock) *IndexingCaches {
+	providers := rsync.MutexWrap(redis.NewMapRedis(redis.WithClock(clk)))
+	claims := rsync.MutexWrap(redis.NewMapRedis(redis.WithClock(clk)))
+	indexes := rsync.MutexWrap(redis.NewMapRedis(redis.WithClock(clk)))
+	providersFaults := fault.Wrap(providers, fault.WithRand(testutil.Rand(t)))
+	claimsFaults := fault.Wrap(claims, fault.WithRand(testutil.Rand(t)))
+	indexesFaults := fault.Wrap(indexes, fault.WithRand(testutil.Rand(t)))
+	forensics.Add(t, "indexing-providers-cache.txt", forensics.Redis(providers, forensics.DecodeProviderResult))
+	forensics.Add(t, "indexing-claims-cache.txt", forensics.Redis(claims, forensics.DecodeClaim))
+	forensics.Add(t, "indexing-indexes-cache.txt", forensics.Redis(indexes, forensics.DecodeIndex))
+	return &IndexingCaches{
+		Providers:         redis.ProvidersCache{Inspector: providers},
+		Claims:            redis.ClaimsCache{Inspector: claims},
+		Indexes:           redis.IndexesCache{Inspector: indexes},
+		ProvidersRecorder: record.Wrap("providers", providersFaults),
+		ClaimsRecorder:    record.Wrap("claims", claimsFaults),
+		IndexesRecorder:   record.Wrap("indexes", indexesFaults),
+		ProvidersFaults:   providersFaults,
+		ClaimsFaults:      claimsFaults,
+		IndexesFaults:     indexesFaults,
+	}
+}
+
+// InProcessIndexingService runs the indexing service in this process, with
+// in-memory caches and datastore that survive a restart.
+type InProcessIndexingService struct {
+	cfg        IndexingServiceConfig
+	serviceCfg construct.ServiceConfig
+	datastore  datastore.Batching
+	caches     *IndexingCaches
+
+	indexer construct.Service
+	server  *http.Server
+}
+
+var _ IndexingService = (*InProcessIndexingService)(nil)
+
+// NewInProcessIndexingService creates an indexing service that runs in this
+// process.
+func NewInProcessIndexingService(t *testing.T, cfg IndexingServiceConfig) *InProcessIndexingService {
+	if cfg.Clock == nil {
+		cfg.Clock = clock.NewSystemClock()
+	}
+	privKey, err := crypto.UnmarshalEd25519PrivateKey(cfg.ID.Raw())
+	require.NoError(t, err)
+
+	publisherListenURL := testutil.RandomLocalURL(t)
+	announceAddr, err := maurl.FromURL(&publisherListenURL)
+	require.NoError(t, err)
+
+	s := &InProcessIndexingService{
+		cfg: cfg,
+		serviceCfg: construct.ServiceConfig{
+			PrivateKey:                  privKey,
+			PublicURL:                   []string{cfg.PublicURL.String()},
+			IndexerURL:                  cfg.IPNIFindURL.String(),
+			PublisherDirectAnnounceURLs: []string{cfg.IPNIAnnounceURL.String()},
+			PublisherListenAddr:         hostPort(publisherListenURL),
+			PublisherAnnounceAddrs:      []string{announceAddr.String()},
+		},
+		datastore: dssync.MutexWrap(datastore.NewMapDatastore()),
+	}
+
+	if !cfg.NoCache {
+		s.caches = newIndexingCaches(t, cfg.Clock)
+	}
+	return s
+}
+
+func (s *InProcessIndexingService) Start(t *testing.T) {
+	opts := []construct.Option{
+		construct.WithStartIPNIServer(true),
+		construct.WithDatastore(s.datastore),
+		construct.WithHTTPClient(tracing.HTTPClient(30 * time.Second)),
+	}
+	if s.caches == nil {
+		opts = append(opts,
+			construct.WithProvidersClient(redis.NewBlackholeRedis()),
+			construct.WithClaimsClient(redis.NewBlackholeRedis()),
+			construct.WithIndexesClient(redis.NewBlackholeRedis()),
+		)
+	} else {
+		opts = append(opts,
+			construct.WithProvidersClient(span.Wrap("providers", s.caches.ProvidersRecorder)),
+			construct.WithClaimsClient(span.Wrap("claims", s.caches.ClaimsRecorder)),
+			construct.WithIndexesClient(span.Wrap("indexes", s.caches.IndexesRecorder)),
+		)
+	}
+	var err error
+	s.indexer, err = construct.Construct(s.serviceCfg, opts...)
+	require.NoError(t, err)
+	require.NoError(t, s.indexer.Startup(context.Background()))
+
+	s.server = serve(t, s.cfg.PublicURL, idxsrv.NewServer(s.indexer, idxsrv.WithIdentity(s.cfg.ID)), "indexing-service")
+}
+
+func (s *InProcessIndexingService) Stop() {
+	s.server.Close()
+	s.indexer.Shutdown(context.Background())
+}
+
+func (s *InProcessIndexingService) Health(ctx context.Context) error {
+	if s.server == nil {
+		return fmt.Errorf("indexing service not started")
+	}
+	return CheckHTTP(ctx, s.cfg.PublicURL)
+}
+
+func (s *InProcessIndexingService) ID() principal.Signer { return s.cfg.ID }
+func (s *InProcessIndexingService) URL() url.URL         { return s.cfg.PublicURL }
+
+// Caches returns the caches of the service, or nil if caching is disabled.
+func (s *InProcessIndexingService) Caches() *IndexingCaches {
+	return s.caches
+}
@@ -0,0 +1,118 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/alanshaw/storetheindex/config"
+	"github.com/alanshaw/storetheindex/ingest"
+	"github.com/alanshaw/storetheindex/registry"
+	httpfind "github.com/alanshaw/storetheindex/server/find"
+	httpingest "github.com/alanshaw/storetheindex/server/ingest"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipni/go-indexer-core"
+	"github.com/ipni/go-indexer-core/engine"
+	"github.com/ipni/go-indexer-core/store/memory"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/storacha/testthenetwork/internal/forensics"
+	"github.com/stretchr/testify/require"
+)
+
+// InProcessIPNI runs storetheindex in this process. Ingested multihashes and
+// registered providers are kept in memory for the lifetime of the test, so they
+// survive a restart.
+type InProcessIPNI struct {
+	cfg         IPNIConfig
+	indexerCore indexer.Interface
+	registryDS  datastore.Batching
+	ingestDS    datastore.Batching
+	ingestTmpDS datastore.Batching
+
+	reg      *registry.Registry
+	p2pHost  host.Host
+	ing      *ingest.Ingester
+	ingSvr   *httpingest.Server
+	findSvr  *httpfind.Server
+	serveErr chan error
+}
+
+var _ IPNIService = (*InProcessIPNI)(nil)
+
+// NewInProcessIPNI creates an IPNI service that runs in this process.
+func NewInProcessIPNI(t *testing.T, cfg IPNIConfig) *InProcessIPNI {
+	i := &InProcessIPNI{
+		cfg:         cfg,
+		indexerCore: engine.New(memory.New()),
+		registryDS:  dssync.MutexWrap(datastore.NewMapDatastore()),
+		ingestDS:    dssync.MutexWrap(datastore.NewMapDatastore()),
+		ingestTmpDS: dssync.MutexWrap(datastore.NewMapDatastore()),
+	}
+	t.Cleanup(func() { i.indexerCore.Close() })
+
+	forensics.Add(t, "ipni-providers.json", forensics.JSON(func() any {
+		if i.reg == nil {
+			return nil
+		}
+		return i.reg.AllProviderInfo()
+	}))
+	forensics.Add(t, "ipni-multihashes.txt", forensics.IndexerValues(i.indexerCore))
+	return i
+}
+
+func (i *InProcessIPNI) Start(t *testing.T) {
+	var err error
+	i.reg, err = registry.New(context.Background(), config.NewDiscovery(), i.registryDS)
+	require.NoError(t, err)
+
+	i.p2pHost, err = libp2p.New()
+	require.NoError(t, err)
+
+	ingConfig := config.NewIngest()
+	ingConfig.PubSubTopic = "/storacha/indexer/ingest/testnet"
+	i.ing, err = ingest.NewIngester(ingConfig, i.p2pHost, i.indexerCore, i.reg, i.ingestDS, i.ingestTmpDS)
+	require.NoError(t, err)
+
+	i.ingSvr, err = httpingest.New(hostPort(i.cfg.AnnounceURL), i.indexerCore, i.ing, i.reg)
+	require.NoError(t, err)
+	i.findSvr, err = httpfind.New(hostPort(i.cfg.FindURL), i.indexerCore, i.reg)
+	require.NoError(t, err)
+
+	// the servers listen when they are started, so failures to listen are
+	// reported by Health
+	i.serveErr = make(chan error, 2)
+	go func() { i.serveErr <- i.ingSvr.Start() }()
+	go func() { i.serveErr <- i.findSvr.Start() }()
+}
+
+func (i *InProcessIPNI) Stop() {
+	i.ingSvr.Close()
+	i.ing.Close()
+	i.findSvr.Close()
+	i.reg.Close()
+	i.p2pHost.Close()
+}
+
+// Health checks the find server answers requests, and that neither server
+// failed to start.
+func (i *InProcessIPNI) Health(ctx context.Context) error {
+	select {
+	case err := <-i.serveErr:
+		if err != nil {
+			i.serveErr <- err // keep reporting it
+			return fmt.Errorf("starting IPNI server: %w", err)
+		}
+	default:
+	}
+	return CheckHTTP(ctx, *i.cfg.FindURL.JoinPath("health"))
+}
+
+func (i *InProcessIPNI) FindURL() url.URL     { return i.cfg.FindURL }
+func (i *InProcessIPNI) AnnounceURL() url.URL { return i.cfg.AnnounceURL }
+
+func hostPort(u url.URL) string {
+	return fmt.Sprintf("%s:%s", u.Hostname(), u.Port())
+}
@@ -0,0 +1,165 @@
+package bootstrap
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/storacha/testthenetwork/internal/forensics"
+	"github.com/storacha/testthenetwork/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// BinDirEnv is the environment variable that sets the directory commands are
+// built into. A temporary directory, removed by RemoveBuiltCommands, is used
+// by default.
+const BinDirEnv = "TTN_BIN_DIR"
+
+var (
+	buildMutex sync.Mutex
+	built      = map[string]string{}
+	binDir     string
+	tempBinDir bool
+)
+
+// buildCommand builds the command in the package, at the version of its module
+// this module requires, and returns the path to the binary. Each command is
+// built once per process.
+func buildCommand(t *testing.T, pkg, name string) string {
+	buildMutex.Lock()
+	defer buildMutex.Unlock()
+	if bin, ok := built[pkg]; ok {
+		return bin
+	}
+
+	if binDir == "" {
+		binDir = os.Getenv(BinDirEnv)
+		if binDir == "" {
+			dir, err := os.MkdirTemp("", "testthenetwork-bin-")
+			require.NoError(t, err)
+			binDir, tempBinDir = dir, true
+		}
+	}
+	bin := filepath.Join(binDir, name)
+
+	start := time.Now()
+	cmd := exec.Command("go", "build", "-o", bin, pkg)
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, "building %s:\n%s", pkg, out)
+	t.Logf("built %s in %s", pkg, time.Since(start).Round(time.Millisecond))
+
+	built[pkg] = bin
+	return bin
+}
+
+// RemoveBuiltCommands removes the temporary directory commands were built
+// into, if any. Call it once the tests that run commands have finished, such
+// as from TestMain. A directory set with BinDirEnv is left in place.
+func RemoveBuiltCommands() error {
+	buildMutex.Lock()
+	defer buildMutex.Unlock()
+	if !tempBinDir {
+		return nil
+	}
+	err := os.RemoveAll(binDir)
+	binDir, tempBinDir = "", false
+	clear(built)
+	return err
+}
+
+// syncBuffer is a buffer that can be written to by several goroutines.
+type syncBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
+// process is a running command whose stdout and stderr are captured.
+type process struct {
+	name string
+	cmd  *exec.Cmd
+	done chan struct{}
+	err  error
+}
+
+// output is the combined stdout and stderr of every run of a command over the
+// lifetime of a test, such as before and after a restart.
+type output struct {
+	name string
+	buf  syncBuffer
+}
+
+// newOutput creates the output of the named command, which is written as an
+// artifact and added to the forensics of the test.
+func newOutput(t *testing.T, name string) *output {
+	o := &output{name: name}
+	forensics.Add(t, name+"-output.txt", forensics.Text(o.buf.String))
+	t.Cleanup(func() {
+		testutil.WriteArtifact(t, name+".log", []byte(o.buf.String()))
+	})
+	return o
+}
+
+// startProcess starts the binary with the arguments and environment, in
+// addition to the environment of this process.
+func startProcess(t *testing.T, out *output, bin string, args []string, env ...string) *process {
+	cmd := exec.Command(bin, args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = &out.buf
+	cmd.Stderr = &out.buf
+	fmt.Fprintf(&out.buf, "$ %s %s\n", filepath.Base(bin), strings.Join(args, " "))
+	require.NoError(t, cmd.Start())
+
+	p := &process{name: out.name, cmd: cmd, done: make(chan struct{})}
+	go func() {
+		p.err = cmd.Wait()
+		close(p.done)
+	}()
+	return p
+}
+
+// exited returns an error describing how the process exited, or nil if it is
+// still running.
+func (p *process) exited() error {
+	select {
+	case <-p.done:
+		if p.err != nil {
+			return fmt.Errorf("%s exited: %w", p.name, p.err)
+		}
+		return fmt.Errorf("%s exited", p.name)
+	default:
+		return nil
+	}
+}
+
+// stop interrupts the process and waits for it to exit, killing it if it has
+// not exited after a few seconds.
+func (p *process) stop() {
+	if err := p.cmd.Process.Signal(os.Interrupt); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		p.cmd.Process.Kill()
+	}
+	select {
+	case <-p.done:
+	case <-time.After(5 * time.Second):
+		p.cmd.Process.Kill()
+		<-p.done
+	}
+}
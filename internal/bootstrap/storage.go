@@ -0,0 +1,81 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/storacha/go-ucanto/principal"
+	"github.com/storacha/storage/pkg/server"
+	"github.com/storacha/storage/pkg/service/storage"
+	"github.com/storacha/testthenetwork/internal/forensics"
+	"github.com/stretchr/testify/require"
+)
+
+// InProcessStorageNode runs the storage node in this process. Blobs,
+// allocations and claims are kept in memory for the lifetime of the test, so
+// they survive a restart.
+type InProcessStorageNode struct {
+	cfg         StorageNodeConfig
+	blobs       *listingBlobstore
+	allocations datastore.Batching
+	claims      datastore.Batching
+	publisher   datastore.Batching
+
+	server *http.Server
+}
+
+var _ StorageNode = (*InProcessStorageNode)(nil)
+
+// NewInProcessStorageNode creates a storage node that runs in this process.
+func NewInProcessStorageNode(t *testing.T, cfg StorageNodeConfig) *InProcessStorageNode {
+	n := &InProcessStorageNode{
+		cfg:         cfg,
+		blobs:       newListingBlobstore(),
+		allocations: dssync.MutexWrap(datastore.NewMapDatastore()),
+		claims:      dssync.MutexWrap(datastore.NewMapDatastore()),
+		publisher:   dssync.MutexWrap(datastore.NewMapDatastore()),
+	}
+	forensics.Add(t, "storage-blobs.txt", n.blobs.WriteListing)
+	forensics.Add(t, "storage-allocations.txt", forensics.Datastore(n.allocations, forensics.DecodeAllocation))
+	forensics.Add(t, "storage-claims.txt", forensics.Datastore(n.claims, forensics.DecodeClaim))
+	return n
+}
+
+func (n *InProcessStorageNode) Start(t *testing.T) {
+	svc, err := storage.New(
+		storage.WithIdentity(n.cfg.ID),
+		storage.WithBlobstore(n.blobs),
+		storage.WithAllocationDatastore(n.allocations),
+		storage.WithClaimDatastore(n.claims),
+		storage.WithPublisherDatastore(n.publisher),
+		storage.WithPublicURL(n.cfg.PublicURL),
+		storage.WithPublisherDirectAnnounce(n.cfg.IPNIAnnounceURL),
+		storage.WithPublisherIndexingServiceConfig(n.cfg.IndexingServiceID, *n.cfg.IndexingServiceURL.JoinPath("claims")),
+		storage.WithPublisherIndexingServiceProof(n.cfg.IndexingServiceProof),
+	)
+	require.NoError(t, err)
+
+	srvMux, err := server.NewServer(svc)
+	require.NoError(t, err)
+
+	n.server = serve(t, n.cfg.PublicURL, srvMux, "storage-node")
+}
+
+func (n *InProcessStorageNode) Stop() {
+	n.server.Close()
+}
+
+func (n *InProcessStorageNode) Health(ctx context.Context) error {
+	if n.server == nil {
+		return fmt.Errorf("storage node not started")
+	}
+	return CheckHTTP(ctx, n.cfg.PublicURL)
+}
+
+func (n *InProcessStorageNode) ID() principal.Signer { return n.cfg.ID }
+func (n *InProcessStorageNode) URL() url.URL         { return n.cfg.PublicURL }
@@ -0,0 +1,206 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipni/go-libipni/maurl"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/principal"
+	ed25519 "github.com/storacha/go-ucanto/principal/ed25519/signer"
+	idxredis "github.com/storacha/indexing-service/pkg/redis"
+	"github.com/storacha/testthenetwork/internal/clock"
+	"github.com/storacha/testthenetwork/internal/redis"
+	"github.com/storacha/testthenetwork/internal/redis/resp"
+	"github.com/storacha/testthenetwork/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// IndexingServiceCommand is the package of the indexing service command.
+	// The command of the indexing service module does not accept the public
+	// URL or the publisher addresses, so this module has its own.
+	IndexingServiceCommand = "github.com/storacha/testthenetwork/internal/cmd/indexing-service"
+	// StorageNodeCommand is the package of the storage node command.
+	StorageNodeCommand = "github.com/storacha/storage/cmd"
+)
+
+// Subprocess returns the components that run the indexing service and the
+// storage node as subprocesses, built from commands using the versions of
+// their modules this module requires. IPNI runs in this process.
+func Subprocess() Components {
+	return Components{
+		IPNI: func(t *testing.T, cfg IPNIConfig) IPNIService {
+			return NewInProcessIPNI(t, cfg)
+		},
+		Indexing: func(t *testing.T, cfg IndexingServiceConfig) IndexingService {
+			return NewSubprocessIndexingService(t, cfg)
+		},
+		Storage: func(t *testing.T, cfg StorageNodeConfig) StorageNode {
+			return NewSubprocessStorageNode(t, cfg)
+		},
+	}
+}
+
+// SubprocessIndexingService runs the indexing service command in a subprocess.
+// Its caches are served to it over RESP from this process, so they can be
+// inspected and have faults injected as those of the in-process service can.
+type SubprocessIndexingService struct {
+	cfg           IndexingServiceConfig
+	bin           string
+	out           *output
+	redisAddr     string
+	publisherURL  url.URL
+	publisherAddr string
+	caches        *IndexingCaches
+	dbs           []idxredis.Client
+
+	redis *resp.Server
+	proc  *process
+}
+
+var _ IndexingService = (*SubprocessIndexingService)(nil)
+
+// NewSubprocessIndexingService builds the indexing service command, if it has
+// not been built already, and creates a service that runs it.
+func NewSubprocessIndexingService(t *testing.T, cfg IndexingServiceConfig) *SubprocessIndexingService {
+	if cfg.Clock == nil {
+		cfg.Clock = clock.NewSystemClock()
+	}
+	redisURL := testutil.RandomLocalURL(t)
+	publisherURL := testutil.RandomLocalURL(t)
+	publisherAddr, err := maurl.FromURL(&publisherURL)
+	require.NoError(t, err)
+	s := &SubprocessIndexingService{
+		cfg:           cfg,
+		bin:           buildCommand(t, IndexingServiceCommand, "indexing-service"),
+		out:           newOutput(t, "indexing-service"),
+		redisAddr:     redisURL.Host,
+		publisherURL:  publisherURL,
+		publisherAddr: publisherAddr.String(),
+	}
+	// the command selects databases 0, 1 and 2 for providers, claims and
+	// indexes by default
+	if cfg.NoCache {
+		s.dbs = []idxredis.Client{redis.NewBlackholeRedis(), redis.NewBlackholeRedis(), redis.NewBlackholeRedis()}
+	} else {
+		s.caches = newIndexingCaches(t, cfg.Clock)
+		s.dbs = []idxredis.Client{s.caches.ProvidersRecorder, s.caches.ClaimsRecorder, s.caches.IndexesRecorder}
+	}
+	return s
+}
+
+func (s *SubprocessIndexingService) Start(t *testing.T) {
+	listener, err := net.Listen("tcp", s.redisAddr)
+	require.NoError(t, err)
+	s.redis = resp.NewServer(s.dbs...)
+	go s.redis.Serve(listener)
+
+	key, err := ed25519.Format(s.cfg.ID)
+	require.NoError(t, err)
+	s.proc = startProcess(t, s.out, s.bin, []string{
+		"--host", s.cfg.PublicURL.Hostname(),
+		"--port", s.cfg.PublicURL.Port(),
+		"--private-key", key,
+		"--public-url", s.cfg.PublicURL.String(),
+		"--redis-url", s.redisAddr,
+		"--ipni-endpoint", s.cfg.IPNIFindURL.String(),
+		"--announce-url", s.cfg.IPNIAnnounceURL.String(),
+		"--publisher-listen-addr", s.publisherURL.Host,
+		"--publisher-announce-addr", s.publisherAddr,
+	})
+}
+
+func (s *SubprocessIndexingService) Stop() {
+	s.proc.stop()
+	s.redis.Close()
+}
+
+func (s *SubprocessIndexingService) Health(ctx context.Context) error {
+	if s.proc == nil {
+		return fmt.Errorf("indexing service not started")
+	}
+	if err := s.proc.exited(); err != nil {
+		return err
+	}
+	return CheckHTTP(ctx, s.cfg.PublicURL)
+}
+
+func (s *SubprocessIndexingService) ID() principal.Signer { return s.cfg.ID }
+func (s *SubprocessIndexingService) URL() url.URL         { return s.cfg.PublicURL }
+
+// Caches returns the caches of the service, or nil if caching is disabled.
+func (s *SubprocessIndexingService) Caches() *IndexingCaches {
+	return s.caches
+}
+
+// SubprocessStorageNode runs the storage node command in a subprocess, with
+// its data in a temporary directory that survives a restart.
+type SubprocessStorageNode struct {
+	cfg     StorageNodeConfig
+	bin     string
+	out     *output
+	dataDir string
+	tmpDir  string
+
+	proc *process
+}
+
+var _ StorageNode = (*SubprocessStorageNode)(nil)
+
+// NewSubprocessStorageNode builds the storage node command, if it has not been
+// built already, and creates a node that runs it.
+func NewSubprocessStorageNode(t *testing.T, cfg StorageNodeConfig) *SubprocessStorageNode {
+	dir := t.TempDir()
+	return &SubprocessStorageNode{
+		cfg:     cfg,
+		bin:     buildCommand(t, StorageNodeCommand, "storage"),
+		out:     newOutput(t, "storage-node"),
+		dataDir: filepath.Join(dir, "data"),
+		tmpDir:  filepath.Join(dir, "tmp"),
+	}
+}
+
+func (n *SubprocessStorageNode) Start(t *testing.T) {
+	key, err := ed25519.Format(n.cfg.ID)
+	require.NoError(t, err)
+	proof, ok := n.cfg.IndexingServiceProof.Delegation()
+	require.True(t, ok, "indexing service proof must be a delegation, not a link")
+	proofStr, err := delegation.Format(proof)
+	require.NoError(t, err)
+
+	n.proc = startProcess(t, n.out, n.bin, []string{
+		"start",
+		"--private-key", key,
+		"--port", n.cfg.PublicURL.Port(),
+		"--data-dir", n.dataDir,
+		"--tmp-dir", n.tmpDir,
+		"--public-url", n.cfg.PublicURL.String(),
+		"--indexing-service-proof", proofStr,
+	},
+		"STORAGE_ANNOUNCE_URL="+n.cfg.IPNIAnnounceURL.String(),
+		"STORAGE_INDEXING_SERVICE_DID="+n.cfg.IndexingServiceID.DID().String(),
+		"STORAGE_INDEXING_SERVICE_URL="+n.cfg.IndexingServiceURL.JoinPath("claims").String(),
+	)
+}
+
+func (n *SubprocessStorageNode) Stop() {
+	n.proc.stop()
+}
+
+func (n *SubprocessStorageNode) Health(ctx context.Context) error {
+	if n.proc == nil {
+		return fmt.Errorf("storage node not started")
+	}
+	if err := n.proc.exited(); err != nil {
+		return err
+	}
+	return CheckHTTP(ctx, n.cfg.PublicURL)
+}
+
+func (n *SubprocessStorageNode) ID() principal.Signer { return n.cfg.ID }
+func (n *SubprocessStorageNode) URL() url.URL         { return n.cfg.PublicURL }
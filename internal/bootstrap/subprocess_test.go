@@ -0,0 +1,55 @@
+package bootstrap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/storacha/go-capabilities/pkg/claim"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/storacha/testthenetwork/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubprocess(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the indexing service and storage node commands")
+	}
+	t.Cleanup(func() { require.NoError(t, RemoveBuiltCommands()) })
+	ctx := context.Background()
+
+	ipniFindURL, ipniAnnounceURL := testutil.RandomLocalURL(t), testutil.RandomLocalURL(t)
+	StartService(t, NewInProcessIPNI(t, IPNIConfig{FindURL: ipniFindURL, AnnounceURL: ipniAnnounceURL}))
+
+	indexingID, storageID := testutil.RandomSigner(t), testutil.RandomSigner(t)
+	indexing := NewSubprocessIndexingService(t, IndexingServiceConfig{
+		ID:              indexingID,
+		PublicURL:       testutil.RandomLocalURL(t),
+		IPNIFindURL:     ipniFindURL,
+		IPNIAnnounceURL: ipniAnnounceURL,
+	})
+	require.Error(t, indexing.Health(ctx))
+	StartService(t, indexing)
+	require.NotNil(t, indexing.Caches())
+
+	proof := testutil.Must(delegation.Delegate(indexingID, storageID, []ucan.Capability[ucan.NoCaveats]{
+		ucan.NewCapability(claim.CacheAbility, indexingID.DID().String(), ucan.NoCaveats{}),
+	}))(t)
+	node := NewSubprocessStorageNode(t, StorageNodeConfig{
+		ID:                   storageID,
+		PublicURL:            testutil.RandomLocalURL(t),
+		IPNIAnnounceURL:      ipniAnnounceURL,
+		IndexingServiceID:    indexingID,
+		IndexingServiceURL:   indexing.URL(),
+		IndexingServiceProof: delegation.FromDelegation(proof),
+	})
+	require.Error(t, node.Health(ctx))
+	StartService(t, node)
+
+	// the node keeps its data directory across a restart
+	node.Stop()
+	require.Error(t, node.Health(ctx))
+	node.Start(t)
+	WaitHealthy(t, node, 5*time.Second)
+}
@@ -0,0 +1,368 @@
+// Package claimassert asserts that a list of content claims, such as those in
+// query results, contains (or does not contain) a claim matching a set of
+// expectations. When an assertion fails the message includes every claim in
+// the list, decoded, and the claim that came closest to matching along with
+// the expectations it did not meet.
+package claimassert
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-capabilities/pkg/assert"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/testthenetwork/internal/digestutil"
+	"github.com/storacha/testthenetwork/internal/printer"
+	"github.com/stretchr/testify/require"
+)
+
+// claim is a content claim with the caveats of its capability decoded.
+type claim struct {
+	delegation.Delegation
+	can      string
+	location *assert.LocationCaveats
+	index    *assert.IndexCaveats
+	err      error
+}
+
+func decode(d delegation.Delegation) *claim {
+	c := &claim{Delegation: d}
+	caps := d.Capabilities()
+	if len(caps) == 0 {
+		c.err = fmt.Errorf("no capabilities")
+		return c
+	}
+	c.can = caps[0].Can()
+	switch c.can {
+	case assert.LocationAbility:
+		nb, err := assert.LocationCaveatsReader.Read(caps[0].Nb())
+		if err != nil {
+			c.err = err
+		} else {
+			c.location = &nb
+		}
+	case assert.IndexAbility:
+		nb, err := assert.IndexCaveatsReader.Read(caps[0].Nb())
+		if err != nil {
+			c.err = err
+		} else {
+			c.index = &nb
+		}
+	}
+	return c
+}
+
+// check is a single expectation of a claim. It returns a description of what
+// the claim has in place of the expected value and whether it matched.
+type check struct {
+	field string
+	want  string
+	test  func(c *claim) (got string, ok bool)
+}
+
+// Matcher is a set of expectations that a claim must all meet to match. Each
+// method returns a new matcher with an added expectation, so matchers can be
+// shared and extended.
+type Matcher struct {
+	checks []check
+}
+
+// Claim returns a matcher that matches any claim.
+func Claim() *Matcher {
+	return &Matcher{}
+}
+
+// Location returns a matcher for a location commitment for the content in the
+// space.
+func Location(content multihash.Multihash, space did.DID) *Matcher {
+	return Claim().Ability(assert.LocationAbility).Content(content).Space(space)
+}
+
+// Index returns a matcher for an index claim for the content and index.
+func Index(content, index ipld.Link) *Matcher {
+	return Claim().Ability(assert.IndexAbility).Content(digestutil.ExtractDigest(content)).Index(index)
+}
+
+func (m *Matcher) with(c check) *Matcher {
+	return &Matcher{checks: append(slices.Clip(m.checks), c)}
+}
+
+// Issuer expects the claim to be issued by the DID.
+func (m *Matcher) Issuer(issuer did.DID) *Matcher {
+	return m.with(check{"issuer", issuer.String(), func(c *claim) (string, bool) {
+		got := c.Issuer().DID()
+		return got.String(), got == issuer
+	}})
+}
+
+// Audience expects the claim to be addressed to the DID.
+func (m *Matcher) Audience(audience did.DID) *Matcher {
+	return m.with(check{"audience", audience.String(), func(c *claim) (string, bool) {
+		got := c.Audience().DID()
+		return got.String(), got == audience
+	}})
+}
+
+// Ability expects the capability of the claim to have the ability, such as
+// assert/location.
+func (m *Matcher) Ability(can string) *Matcher {
+	return m.with(check{"ability", can, func(c *claim) (string, bool) {
+		return c.can, c.can == can
+	}})
+}
+
+// Space expects the claim to be a location commitment for the space.
+func (m *Matcher) Space(space did.DID) *Matcher {
+	return m.with(check{"space", space.String(), func(c *claim) (string, bool) {
+		if c.location == nil {
+			return c.notA("location commitment"), false
+		}
+		return c.location.Space.String(), c.location.Space == space
+	}})
+}
+
+// OutsideSpaces expects the claim to be a location commitment for a space
+// other than those passed. Use it with NotContains to assert that results
+// filtered by space do not leak location commitments from other spaces.
+func (m *Matcher) OutsideSpaces(spaces ...did.DID) *Matcher {
+	var ids []string
+	for _, space := range spaces {
+		ids = append(ids, space.String())
+	}
+	return m.with(check{"space", "none of " + strings.Join(ids, ", "), func(c *claim) (string, bool) {
+		if c.location == nil {
+			return c.notA("location commitment"), false
+		}
+		return c.location.Space.String(), !slices.Contains(spaces, c.location.Space)
+	}})
+}
+
+// Content expects the claim to be a location commitment or index claim for
+// the content with the digest.
+func (m *Matcher) Content(digest multihash.Multihash) *Matcher {
+	return m.with(check{"content", digestutil.Format(digest), func(c *claim) (string, bool) {
+		var got multihash.Multihash
+		switch {
+		case c.location != nil:
+			got = c.location.Content.Hash()
+		case c.index != nil:
+			got = digestutil.ExtractDigest(c.index.Content)
+		default:
+			return c.notA("location commitment or index claim"), false
+		}
+		return digestutil.Format(got), bytes.Equal(got, digest)
+	}})
+}
+
+// Index expects the claim to be an index claim for the index.
+func (m *Matcher) Index(index ipld.Link) *Matcher {
+	return m.with(check{"index", index.String(), func(c *claim) (string, bool) {
+		if c.index == nil {
+			return c.notA("index claim"), false
+		}
+		return c.index.Index.String(), c.index.Index.String() == index.String()
+	}})
+}
+
+// LocationPrefix expects the claim to be a location commitment with a location
+// URL that starts with the prefix, such as the URL of a storage node.
+func (m *Matcher) LocationPrefix(prefix string) *Matcher {
+	return m.with(check{"location", prefix + "…", func(c *claim) (string, bool) {
+		if c.location == nil {
+			return c.notA("location commitment"), false
+		}
+		var urls []string
+		for _, u := range c.location.Location {
+			if strings.HasPrefix(u.String(), prefix) {
+				return u.String(), true
+			}
+			urls = append(urls, u.String())
+		}
+		return formatURLs(urls), false
+	}})
+}
+
+// LocationURL expects the claim to be a location commitment with a location
+// URL that starts with the URL.
+func (m *Matcher) LocationURL(u url.URL) *Matcher {
+	return m.LocationPrefix(u.String())
+}
+
+// Range expects the claim to be a location commitment for the byte range.
+func (m *Matcher) Range(offset, length uint64) *Matcher {
+	return m.with(check{"range", formatRange(&assert.Range{Offset: offset, Length: &length}), func(c *claim) (string, bool) {
+		if c.location == nil {
+			return c.notA("location commitment"), false
+		}
+		r := c.location.Range
+		return formatRange(r), r != nil && r.Offset == offset && r.Length != nil && *r.Length == length
+	}})
+}
+
+// NoRange expects the claim to be a location commitment for the whole blob.
+func (m *Matcher) NoRange() *Matcher {
+	return m.with(check{"range", formatRange(nil), func(c *claim) (string, bool) {
+		if c.location == nil {
+			return c.notA("location commitment"), false
+		}
+		return formatRange(c.location.Range), c.location.Range == nil
+	}})
+}
+
+// ExpiresAfter expects the claim to expire after the time, or never.
+func (m *Matcher) ExpiresAfter(t time.Time) *Matcher {
+	return m.with(check{"expiration", "after " + formatTime(t), func(c *claim) (string, bool) {
+		exp := c.Expiration()
+		if exp == nil {
+			return "never", true
+		}
+		return formatTime(time.Unix(int64(*exp), 0)), int64(*exp) > t.Unix()
+	}})
+}
+
+// ExpiresBefore expects the claim to expire at or before the time.
+func (m *Matcher) ExpiresBefore(t time.Time) *Matcher {
+	return m.with(check{"expiration", "at or before " + formatTime(t), func(c *claim) (string, bool) {
+		exp := c.Expiration()
+		if exp == nil {
+			return "never", false
+		}
+		return formatTime(time.Unix(int64(*exp), 0)), int64(*exp) <= t.Unix()
+	}})
+}
+
+// NoExpiry expects the claim to never expire.
+func (m *Matcher) NoExpiry() *Matcher {
+	return m.with(check{"expiration", "never", func(c *claim) (string, bool) {
+		exp := c.Expiration()
+		if exp == nil {
+			return "never", true
+		}
+		return formatTime(time.Unix(int64(*exp), 0)), false
+	}})
+}
+
+// String describes the expectations of the matcher.
+func (m *Matcher) String() string {
+	if len(m.checks) == 0 {
+		return "any claim"
+	}
+	var parts []string
+	for _, c := range m.checks {
+		parts = append(parts, c.field+"="+c.want)
+	}
+	return strings.Join(parts, " ")
+}
+
+// mismatch is an expectation a claim did not meet.
+type mismatch struct {
+	check check
+	got   string
+}
+
+// match returns the expectations the claim does not meet.
+func (m *Matcher) match(c *claim) []mismatch {
+	var misses []mismatch
+	for _, chk := range m.checks {
+		if got, ok := chk.test(c); !ok {
+			misses = append(misses, mismatch{chk, got})
+		}
+	}
+	return misses
+}
+
+// Find returns the first claim that matches.
+func Find(claims []delegation.Delegation, m *Matcher) (delegation.Delegation, bool) {
+	for _, d := range claims {
+		if len(m.match(decode(d))) == 0 {
+			return d, true
+		}
+	}
+	return nil, false
+}
+
+// Contains asserts that a claim matches and returns the first that does.
+func Contains(t testing.TB, claims []delegation.Delegation, m *Matcher) delegation.Delegation {
+	t.Helper()
+	var closest *claim
+	var closestMisses []mismatch
+	for _, d := range claims {
+		c := decode(d)
+		misses := m.match(c)
+		if len(misses) == 0 {
+			return d
+		}
+		if closest == nil || len(misses) < len(closestMisses) {
+			closest, closestMisses = c, misses
+		}
+	}
+
+	var msg strings.Builder
+	if closest != nil {
+		fmt.Fprintf(&msg, "closest: %s (%d of %d expectations met)\n", closest.Link(), len(m.checks)-len(closestMisses), len(m.checks))
+		for _, miss := range closestMisses {
+			fmt.Fprintf(&msg, "\t%s: want %s, got %s\n", miss.check.field, miss.check.want, miss.got)
+		}
+		fmt.Fprintln(&msg)
+	}
+	writeClaims(&msg, claims)
+	require.Fail(t, "no claim matches "+m.String(), msg.String())
+	return nil
+}
+
+// NotContains asserts that no claim matches.
+func NotContains(t testing.TB, claims []delegation.Delegation, m *Matcher) {
+	t.Helper()
+	d, ok := Find(claims, m)
+	if !ok {
+		return
+	}
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "matched: %s\n\n", d.Link())
+	writeClaims(&msg, claims)
+	require.Fail(t, "unexpected claim matches "+m.String(), msg.String())
+}
+
+func writeClaims(w *strings.Builder, claims []delegation.Delegation) {
+	fmt.Fprintf(w, "claims (%d):\n", len(claims))
+	for _, d := range claims {
+		fmt.Fprintf(w, "%s\n", d.Link())
+		printer.WriteClaimText(w, d)
+	}
+}
+
+func (c *claim) notA(kind string) string {
+	if c.err != nil {
+		return fmt.Sprintf("%s claim with caveats that failed to decode: %s", c.can, c.err)
+	}
+	return fmt.Sprintf("%s claim, not a %s", c.can, kind)
+}
+
+func formatRange(r *assert.Range) string {
+	switch {
+	case r == nil:
+		return "whole blob"
+	case r.Length == nil:
+		return fmt.Sprintf("offset %d to end", r.Offset)
+	}
+	return fmt.Sprintf("offset %d, length %d", r.Offset, *r.Length)
+}
+
+func formatTime(t time.Time) string {
+	return fmt.Sprintf("%s (%d)", t.UTC().Format(time.RFC3339), t.Unix())
+}
+
+func formatURLs(urls []string) string {
+	if len(urls) == 0 {
+		return "no locations"
+	}
+	return strings.Join(urls, ", ")
+}
@@ -0,0 +1,113 @@
+package claimassert
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-capabilities/pkg/assert"
+	"github.com/storacha/go-capabilities/pkg/types"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/principal"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/storacha/testthenetwork/internal/digestutil"
+	"github.com/storacha/testthenetwork/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder records the failures of assertions instead of failing the test.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) FailNow() {}
+
+func locationClaim(t *testing.T, issuer principal.Signer, content multihash.Multihash, space did.DID, location url.URL, opts ...delegation.Option) delegation.Delegation {
+	length := uint64(100)
+	caps := []ucan.Capability[ucan.CaveatBuilder]{
+		ucan.NewCapability[ucan.CaveatBuilder](assert.LocationAbility, issuer.DID().String(), assert.LocationCaveats{
+			Content:  types.FromHash(content),
+			Location: []url.URL{location},
+			Range:    &assert.Range{Offset: 10, Length: &length},
+			Space:    space,
+		}),
+	}
+	claim, err := delegation.Delegate(issuer, issuer, caps, opts...)
+	require.NoError(t, err)
+	return claim
+}
+
+func indexClaim(t *testing.T, issuer principal.Signer, content, index ipld.Link) delegation.Delegation {
+	caps := []ucan.Capability[ucan.CaveatBuilder]{
+		ucan.NewCapability[ucan.CaveatBuilder](assert.IndexAbility, issuer.DID().String(), assert.IndexCaveats{
+			Content: content,
+			Index:   index,
+		}),
+	}
+	claim, err := delegation.Delegate(issuer, issuer, caps, delegation.WithNoExpiration())
+	require.NoError(t, err)
+	return claim
+}
+
+func TestMatchers(t *testing.T) {
+	storage := testutil.RandomSigner(t)
+	alice := testutil.RandomSigner(t)
+	space := testutil.RandomPrincipal(t).DID()
+	other := testutil.RandomPrincipal(t).DID()
+	shard, _ := testutil.RandomBytes(t, 32)
+	root := testutil.RandomCID(t)
+	index := testutil.RandomCID(t)
+	location := testutil.RandomLocalURL(t)
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	claims := []delegation.Delegation{
+		indexClaim(t, alice, root, index),
+		locationClaim(t, storage, shard, space, *location.JoinPath("blob"), delegation.WithExpiration(int(exp.Unix()))),
+	}
+
+	Contains(t, claims, Index(root, index).Issuer(alice.DID()).NoExpiry())
+	Contains(t, claims, Location(shard, space).Issuer(storage.DID()).Audience(storage.DID()).LocationURL(location).Range(10, 100))
+	Contains(t, claims, Claim().Ability(assert.LocationAbility).ExpiresAfter(exp.Add(-time.Second)).ExpiresBefore(exp))
+	NotContains(t, claims, Location(shard, other))
+	NotContains(t, claims, Index(root, testutil.RandomCID(t)))
+	NotContains(t, claims, Location(shard, space).NoRange())
+	NotContains(t, claims, Location(shard, space).NoExpiry())
+	NotContains(t, claims, Claim().OutsideSpaces(space, other))
+	Contains(t, claims, Claim().OutsideSpaces(other))
+
+	// matchers are not changed by extending them
+	base := Location(shard, space)
+	_ = base.Space(other)
+	Contains(t, claims, base)
+	require.Equal(t, "ability=assert/location content="+digestutil.Format(shard)+" space="+space.String(), base.String())
+
+	rec := &recorder{TB: t}
+	require.Nil(t, Contains(rec, claims, Location(shard, other)))
+	require.Len(t, rec.errors, 1)
+	msg := rec.errors[0]
+	require.Contains(t, msg, "no claim matches ability=assert/location")
+	require.Contains(t, msg, "closest: "+claims[1].Link().String()+" (2 of 3 expectations met)")
+	require.Contains(t, msg, "space: want "+other.String()+", got "+space.String())
+	require.Contains(t, msg, "claims (2):")
+	require.Contains(t, msg, "Issuer:     "+alice.DID().String())
+
+	rec = &recorder{TB: t}
+	NotContains(rec, claims, Index(root, index))
+	require.Len(t, rec.errors, 1)
+	require.Contains(t, rec.errors[0], "unexpected claim matches ability=assert/index")
+	require.Contains(t, rec.errors[0], "matched: "+claims[0].Link().String())
+
+	rec = &recorder{TB: t}
+	Contains(rec, claims, Claim().Space(space).Index(index))
+	require.Len(t, rec.errors, 1)
+	require.Contains(t, rec.errors[0], "assert/index claim, not a location commitment")
+}
@@ -0,0 +1,158 @@
+// Package claimverify checks that content claims, such as those returned by
+// the indexing service, are authentic: that they and their proofs are signed by
+// their issuers, that their proof chains authorize them and that location
+// commitments were issued by a known storage node for a URL it serves.
+package claimverify
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/storacha/go-capabilities/pkg/assert"
+	"github.com/storacha/go-ucanto/core/dag/blockstore"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/principal"
+	"github.com/storacha/go-ucanto/principal/ed25519/verifier"
+	"github.com/storacha/go-ucanto/validator"
+)
+
+// Verifier verifies claims.
+type Verifier struct {
+	authority principal.Verifier
+	nodes     map[did.DID]url.URL
+}
+
+// Option configures a Verifier.
+type Option func(*Verifier)
+
+// WithStorageNode adds a storage node that location commitments may be issued
+// by, for locations under its public URL.
+func WithStorageNode(id did.DID, publicURL url.URL) Option {
+	return func(v *Verifier) {
+		v.nodes[id] = publicURL
+	}
+}
+
+// New creates a verifier. The authority is the service the claims are
+// addressed to, which may attest to claims issued by principals that are not
+// identified by a did:key.
+func New(authority principal.Verifier, opts ...Option) *Verifier {
+	v := &Verifier{authority: authority, nodes: map[did.DID]url.URL{}}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// Verify verifies a claim, returning an error describing every problem found.
+// Location and index claims must be authorized by a valid proof chain. The
+// signatures of claims of other types and their proofs are verified.
+func (v *Verifier) Verify(claim delegation.Delegation) error {
+	caps := claim.Capabilities()
+	if len(caps) == 0 {
+		return fmt.Errorf("claim %s: no capabilities", claim.Link())
+	}
+
+	var errs []error
+	switch caps[0].Can() {
+	case assert.LocationAbility:
+		auth, err := validator.Claim(assert.Location, proofs(claim), v.context())
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+		errs = append(errs, v.verifyLocation(claim, auth.Capability().Nb())...)
+	case assert.IndexAbility:
+		if _, err := validator.Claim(assert.Index, proofs(claim), v.context()); err != nil {
+			errs = append(errs, err)
+		}
+	default:
+		errs = append(errs, v.verifySignatures(claim)...)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("claim %s: %w", claim.Link(), errors.Join(errs...))
+	}
+	return nil
+}
+
+// VerifyAll verifies each claim, returning the errors of all that fail.
+func (v *Verifier) VerifyAll(claims []delegation.Delegation) error {
+	var errs []error
+	for _, claim := range claims {
+		if err := v.Verify(claim); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// verifyLocation checks a location commitment was issued by a known storage
+// node for locations under its public URL.
+func (v *Verifier) verifyLocation(claim delegation.Delegation, nb assert.LocationCaveats) []error {
+	issuer := claim.Issuer().DID()
+	node, ok := v.nodes[issuer]
+	if !ok {
+		return []error{fmt.Errorf("location commitment issued by %s, which is not a known storage node", issuer)}
+	}
+	var errs []error
+	if len(nb.Location) == 0 {
+		errs = append(errs, errors.New("location commitment has no locations"))
+	}
+	for _, u := range nb.Location {
+		if !under(u, node) {
+			errs = append(errs, fmt.Errorf("location %s is not served by storage node %s at %s", u.String(), issuer, node.String()))
+		}
+	}
+	return errs
+}
+
+// verifySignatures verifies the signature of a delegation and of each of its
+// proofs that is included with it. Proofs that are only linked to cannot be
+// verified and are skipped.
+func (v *Verifier) verifySignatures(dlg delegation.Delegation) []error {
+	var errs []error
+	if _, err := validator.VerifyAuthorization(dlg, nil, v.context()); err != nil {
+		errs = append(errs, err)
+	}
+	br, err := blockstore.NewBlockReader(blockstore.WithBlocksIterator(dlg.Blocks()))
+	if err != nil {
+		return append(errs, fmt.Errorf("reading blocks: %w", err))
+	}
+	for _, p := range delegation.NewProofsView(dlg.Proofs(), br) {
+		if prf, ok := p.Delegation(); ok {
+			errs = append(errs, v.verifySignatures(prf)...)
+		}
+	}
+	return errs
+}
+
+func (v *Verifier) context() validator.ClaimContext {
+	return validator.NewValidationContext[any](
+		v.authority,
+		nil,
+		validator.IsSelfIssued,
+		func(validator.Authorization[any]) validator.Revoked { return nil },
+		validator.ProofUnavailable,
+		verifier.Parse,
+		validator.FailDIDKeyResolution,
+	)
+}
+
+// proofs returns the claim as the proof of its own capability, so that
+// validating it checks the claim and each delegation in its proof chain.
+func proofs(claim delegation.Delegation) []delegation.Proof {
+	return []delegation.Proof{delegation.FromDelegation(claim)}
+}
+
+// under reports whether the URL is on the same origin as the base URL and
+// within its path.
+func under(u, base url.URL) bool {
+	if u.Scheme != base.Scheme || u.Host != base.Host {
+		return false
+	}
+	prefix := strings.TrimSuffix(base.Path, "/") + "/"
+	return base.Path == "" || u.Path == base.Path || strings.HasPrefix(u.Path, prefix)
+}
@@ -0,0 +1,111 @@
+package claimverify
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-capabilities/pkg/assert"
+	"github.com/storacha/go-capabilities/pkg/types"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/principal"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/storacha/testthenetwork/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// impostor signs with its own key but claims to be another principal.
+type impostor struct {
+	principal.Signer
+	id did.DID
+}
+
+func (i impostor) DID() did.DID { return i.id }
+
+func locationClaim(t *testing.T, issuer principal.Signer, content multihash.Multihash, space did.DID, location url.URL) delegation.Delegation {
+	caps := []ucan.Capability[ucan.CaveatBuilder]{
+		ucan.NewCapability[ucan.CaveatBuilder](assert.LocationAbility, issuer.DID().String(), assert.LocationCaveats{
+			Content:  types.FromHash(content),
+			Location: []url.URL{location},
+			Space:    space,
+		}),
+	}
+	return testutil.Must(delegation.Delegate(issuer, issuer, caps, delegation.WithNoExpiration()))(t)
+}
+
+func indexClaim(t *testing.T, issuer principal.Signer, service ucan.Principal, content, index ipld.Link, opts ...delegation.Option) delegation.Delegation {
+	caps := []ucan.Capability[ucan.CaveatBuilder]{
+		ucan.NewCapability[ucan.CaveatBuilder](assert.IndexAbility, service.DID().String(), assert.IndexCaveats{
+			Content: content,
+			Index:   index,
+		}),
+	}
+	opts = append(opts, delegation.WithNoExpiration())
+	return testutil.Must(delegation.Delegate(issuer, service, caps, opts...))(t)
+}
+
+func TestVerify(t *testing.T) {
+	indexing := testutil.RandomSigner(t)
+	storage := testutil.RandomSigner(t)
+	alice := testutil.RandomSigner(t)
+	space := testutil.RandomPrincipal(t).DID()
+	digest, _ := testutil.RandomBytes(t, 32)
+	root, index := testutil.RandomCID(t), testutil.RandomCID(t)
+	storageURL := testutil.RandomLocalURL(t)
+	blobURL := *storageURL.JoinPath("blob", "z123")
+
+	v := New(indexing.Verifier(), WithStorageNode(storage.DID(), storageURL))
+
+	proof := testutil.Must(delegation.Delegate(indexing, alice, []ucan.Capability[ucan.NoCaveats]{
+		ucan.NewCapability(assert.IndexAbility, indexing.DID().String(), ucan.NoCaveats{}),
+	}, delegation.WithNoExpiration()))(t)
+
+	t.Run("valid", func(t *testing.T) {
+		require.NoError(t, v.VerifyAll([]delegation.Delegation{
+			locationClaim(t, storage, digest, space, blobURL),
+			indexClaim(t, alice, indexing, root, index, delegation.WithProof(delegation.FromDelegation(proof))),
+			indexClaim(t, indexing, indexing, root, index),
+		}))
+	})
+
+	t.Run("forged signature", func(t *testing.T) {
+		forged := locationClaim(t, impostor{testutil.RandomSigner(t), storage.DID()}, digest, space, blobURL)
+		require.ErrorContains(t, v.Verify(forged), "signature")
+	})
+
+	t.Run("unknown storage node", func(t *testing.T) {
+		other := testutil.RandomSigner(t)
+		err := v.Verify(locationClaim(t, other, digest, space, blobURL))
+		require.ErrorContains(t, err, "not a known storage node")
+	})
+
+	t.Run("location elsewhere", func(t *testing.T) {
+		elsewhere := testutil.RandomLocalURL(t)
+		err := v.Verify(locationClaim(t, storage, digest, space, *elsewhere.JoinPath("blob")))
+		require.ErrorContains(t, err, "is not served by storage node")
+	})
+
+	t.Run("missing proof", func(t *testing.T) {
+		err := v.Verify(indexClaim(t, alice, indexing, root, index))
+		require.Error(t, err)
+	})
+
+	t.Run("proof for another service", func(t *testing.T) {
+		other := testutil.RandomSigner(t)
+		proof := testutil.Must(delegation.Delegate(other, alice, []ucan.Capability[ucan.NoCaveats]{
+			ucan.NewCapability(assert.IndexAbility, other.DID().String(), ucan.NoCaveats{}),
+		}, delegation.WithNoExpiration()))(t)
+		err := v.Verify(indexClaim(t, alice, indexing, root, index, delegation.WithProof(delegation.FromDelegation(proof))))
+		require.Error(t, err)
+	})
+
+	t.Run("under", func(t *testing.T) {
+		base := url.URL{Scheme: "http", Host: "127.0.0.1:1234", Path: "/node"}
+		require.True(t, under(url.URL{Scheme: "http", Host: "127.0.0.1:1234", Path: "/node/blob/z1"}, base))
+		require.False(t, under(url.URL{Scheme: "http", Host: "127.0.0.1:1234", Path: "/nodes/blob/z1"}, base))
+		require.False(t, under(url.URL{Scheme: "https", Host: "127.0.0.1:1234", Path: "/node/blob/z1"}, base))
+		require.False(t, under(url.URL{Scheme: "http", Host: "127.0.0.1:4321", Path: "/node/blob/z1"}, base))
+	})
+}
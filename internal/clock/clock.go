@@ -0,0 +1,60 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides the current time. Components that need to know the time
+// should use a Clock instead of calling time.Now directly so that tests can
+// travel through time without sleeping.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is a clock that returns the wall-clock time.
+type SystemClock struct{}
+
+var _ Clock = (*SystemClock)(nil)
+
+func NewSystemClock() *SystemClock {
+	return &SystemClock{}
+}
+
+func (c *SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FakeClock is a clock that only moves when told to. It is safe for
+// concurrent use.
+type FakeClock struct {
+	mutex sync.RWMutex
+	now   time.Time
+}
+
+var _ Clock = (*FakeClock)(nil)
+
+// NewFakeClock creates a new clock frozen at the passed time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.now
+}
+
+// Advance moves the clock forward by the passed duration.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Set moves the clock to the passed time.
+func (c *FakeClock) Set(now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = now
+}
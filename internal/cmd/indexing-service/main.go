@@ -0,0 +1,120 @@
+// Command indexing-service runs the indexing service, like the "server start"
+// command of the indexing service module, but with flags for the public URL
+// and the publisher addresses, which that command does not accept and which
+// the service needs to serve its own claims and have IPNI fetch their
+// advertisements. It is built from the version of the module this module
+// requires to run the indexing service in a subprocess.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/redis/go-redis/v9"
+	ed25519 "github.com/storacha/go-ucanto/principal/ed25519/signer"
+	ucanserver "github.com/storacha/go-ucanto/server"
+	"github.com/storacha/indexing-service/cmd/config"
+	"github.com/storacha/indexing-service/pkg/construct"
+	"github.com/storacha/indexing-service/pkg/principalresolver"
+	"github.com/storacha/indexing-service/pkg/server"
+)
+
+var log = logging.Logger("cmd")
+
+// list is a flag that may be set more than once.
+type list []string
+
+func (l *list) String() string     { return strings.Join(*l, ",") }
+func (l *list) Set(v string) error { *l = append(*l, v); return nil }
+
+func main() {
+	logging.SetLogLevel("*", "info")
+
+	var announceURLs, announceAddrs list
+	host := flag.String("host", "127.0.0.1", "host to bind the server to")
+	port := flag.Int("port", 9000, "port to bind the server to")
+	privateKey := flag.String("private-key", "", "base64 encoded private key identity for the server")
+	publicURL := flag.String("public-url", "", "URL the server is publicly accessible at")
+	redisURL := flag.String("redis-url", "", "address of the Redis server to cache providers, claims and indexes in")
+	ipniEndpoint := flag.String("ipni-endpoint", "", "HTTP endpoint of the IPNI instance used to discover providers")
+	flag.Var(&announceURLs, "announce-url", "URL of an IPNI instance to announce advertisements to (defaults to -ipni-endpoint)")
+	publisherListenAddr := flag.String("publisher-listen-addr", "", "address the publisher serves advertisements on")
+	flag.Var(&announceAddrs, "publisher-announce-addr", "multiaddr put into announcements to tell IPNI where to fetch advertisements from")
+	flag.Parse()
+
+	if err := run(net.JoinHostPort(*host, strconv.Itoa(*port)), *privateKey, *publicURL, *redisURL, *ipniEndpoint, announceURLs, *publisherListenAddr, announceAddrs); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(addr, privateKey, publicURL, redisURL, ipniEndpoint string, announceURLs []string, publisherListenAddr string, announceAddrs []string) error {
+	id, err := ed25519.Parse(privateKey)
+	if err != nil {
+		return fmt.Errorf("parsing server private key: %w", err)
+	}
+	privKey, err := crypto.UnmarshalEd25519PrivateKey(id.Raw())
+	if err != nil {
+		return fmt.Errorf("unmarshaling private key: %w", err)
+	}
+	presolv, err := principalresolver.New(config.PrincipalMapping)
+	if err != nil {
+		return fmt.Errorf("creating principal resolver: %w", err)
+	}
+
+	sc := construct.ServiceConfig{
+		PrivateKey:                  privKey,
+		ProvidersRedis:              redis.Options{Addr: redisURL, DB: 0},
+		ClaimsRedis:                 redis.Options{Addr: redisURL, DB: 1},
+		IndexesRedis:                redis.Options{Addr: redisURL, DB: 2},
+		IndexerURL:                  ipniEndpoint,
+		PublisherDirectAnnounceURLs: announceURLs,
+		PublisherListenAddr:         publisherListenAddr,
+		PublisherAnnounceAddrs:      announceAddrs,
+	}
+	if publicURL != "" {
+		sc.PublicURL = []string{publicURL}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var opts []construct.Option
+	if publisherListenAddr != "" {
+		// serve advertisements so IPNI can fetch them once announced
+		opts = append(opts, construct.WithStartIPNIServer(true))
+	}
+	indexer, err := construct.Construct(sc, opts...)
+	if err != nil {
+		return err
+	}
+	if err := indexer.Startup(ctx); err != nil {
+		return err
+	}
+	defer indexer.Shutdown(context.Background())
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe(
+			addr,
+			indexer,
+			server.WithIdentity(id),
+			server.WithContentClaimsOptions(ucanserver.WithPrincipalResolver(presolv.ResolveDIDKey)),
+		)
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		log.Info("Shutting down")
+		return nil
+	}
+}
@@ -0,0 +1,98 @@
+package digestutil
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multibase"
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	digest, err := multihash.Sum([]byte("hello"), multihash.SHA2_256, -1)
+	require.NoError(t, err)
+
+	t.Run("multibase encodings", func(t *testing.T) {
+		for _, base := range []multibase.Encoding{multibase.Base58BTC, multibase.Base32, multibase.Base64, multibase.Base64url, multibase.Base64pad} {
+			s, err := FormatBase(digest, base)
+			require.NoError(t, err)
+			parsed, err := Parse(s)
+			require.NoError(t, err, s)
+			require.Equal(t, digest, parsed)
+		}
+	})
+
+	t.Run("bare base58", func(t *testing.T) {
+		parsed, err := Parse(digest.B58String())
+		require.NoError(t, err)
+		require.Equal(t, digest, parsed)
+	})
+
+	t.Run("round trips Format", func(t *testing.T) {
+		require.Equal(t, digest, MustParse(Format(digest)))
+	})
+
+	t.Run("not a multihash", func(t *testing.T) {
+		s, err := multibase.Encode(multibase.Base58BTC, []byte("not a multihash"))
+		require.NoError(t, err)
+		_, err = Parse(s)
+		require.ErrorContains(t, err, "decoding multihash")
+	})
+
+	t.Run("not multibase", func(t *testing.T) {
+		_, err := Parse("!!!")
+		require.ErrorContains(t, err, "decoding multibase digest")
+		require.Panics(t, func() { MustParse("!!!") })
+	})
+
+	t.Run("digest or CID", func(t *testing.T) {
+		parsed, err := ParseDigestOrCID(Format(digest))
+		require.NoError(t, err)
+		require.Equal(t, digest, parsed)
+
+		parsed, err = ParseDigestOrCID(CARLink(digest).String())
+		require.NoError(t, err)
+		require.Equal(t, digest, parsed)
+
+		_, err = ParseDigestOrCID("!!!")
+		require.ErrorContains(t, err, "neither a digest nor a CID")
+	})
+}
+
+func TestLinks(t *testing.T) {
+	digest, err := multihash.Sum([]byte("hello"), multihash.SHA2_256, -1)
+	require.NoError(t, err)
+
+	raw := cid.MustParse(RawLink(digest).String())
+	require.Equal(t, uint64(multicodec.Raw), raw.Prefix().Codec)
+	require.Equal(t, digest, multihash.Multihash(raw.Hash()))
+
+	car := cid.MustParse(CARLink(digest).String())
+	require.Equal(t, uint64(multicodec.Car), car.Prefix().Codec)
+
+	require.Equal(t, Key(RawLink(digest)), Key(CARLink(digest)))
+	require.Equal(t, Format(digest), Key(CARLink(digest)))
+	require.Equal(t, digest, ExtractDigest(Link(multicodec.DagCbor, digest)))
+}
+
+func TestDescribe(t *testing.T) {
+	sha256, err := multihash.Sum([]byte("hello"), multihash.SHA2_256, -1)
+	require.NoError(t, err)
+	require.Equal(t, Format(sha256)+" (sha2-256, 32 bytes)", Describe(sha256))
+
+	identity, err := multihash.Sum([]byte("hi"), multihash.IDENTITY, -1)
+	require.NoError(t, err)
+	require.Equal(t, Format(identity)+" (identity, 2 bytes)", Describe(identity))
+
+	require.Contains(t, Describe([]byte{0x12}), "invalid multihash 12 (1 bytes)")
+	require.Equal(t, "0x123456", HashName(0x123456))
+}
+
+func TestFormatBytes(t *testing.T) {
+	digest, err := multihash.Sum([]byte("hello"), multihash.SHA2_256, -1)
+	require.NoError(t, err)
+	require.Equal(t, Format(digest), FormatBytes(digest))
+	require.Equal(t, "00ff", FormatBytes([]byte{0x00, 0xff}))
+}
@@ -1,21 +1,52 @@
 package digestutil
 
 import (
-	"github.com/ipfs/go-cid"
-	"github.com/ipld/go-ipld-prime"
-	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"encoding/hex"
+	"fmt"
+
 	"github.com/multiformats/go-multibase"
 	"github.com/multiformats/go-multihash"
 )
 
+// Format encodes a multihash as a base58btc multibase string, the form used
+// for keys by the indexing service and in storage node blob URLs.
 func Format(digest multihash.Multihash) string {
 	key, _ := multibase.Encode(multibase.Base58BTC, digest)
 	return key
 }
 
-func ExtractDigest(link ipld.Link) multihash.Multihash {
-	if cl, ok := link.(cidlink.Link); ok {
-		return cl.Cid.Hash()
+// FormatBase encodes a multihash as a multibase string in the passed encoding,
+// e.g. multibase.Base32 or multibase.Base64.
+func FormatBase(digest multihash.Multihash, base multibase.Encoding) (string, error) {
+	return multibase.Encode(base, digest)
+}
+
+// FormatBytes formats bytes that may be a multihash, such as a cache key or
+// IPNI context ID. Multihashes are formatted with Format and anything else as
+// hex.
+func FormatBytes(b []byte) string {
+	if digest, err := multihash.Cast(b); err == nil {
+		return Format(digest)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Describe formats a multihash along with the name of its hash function and
+// the length of the hash, e.g. "zQm... (sha2-256, 32 bytes)". Bytes that are
+// not a valid multihash are described as such.
+func Describe(digest multihash.Multihash) string {
+	info, err := multihash.Decode(digest)
+	if err != nil {
+		return fmt.Sprintf("invalid multihash %s (%d bytes): %s", hex.EncodeToString(digest), len(digest), err)
+	}
+	return fmt.Sprintf("%s (%s, %d bytes)", Format(digest), HashName(info.Code), info.Length)
+}
+
+// HashName returns the name of a multihash function code, e.g. "sha2-256", or
+// its hex code if it is not known.
+func HashName(code uint64) string {
+	if name, ok := multihash.Codes[code]; ok {
+		return name
 	}
-	return cid.MustParse(link.String()).Hash()
+	return fmt.Sprintf("0x%x", code)
 }
@@ -0,0 +1,39 @@
+package digestutil
+
+import (
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-multihash"
+)
+
+// ExtractDigest returns the multihash of a link.
+func ExtractDigest(link ipld.Link) multihash.Multihash {
+	if cl, ok := link.(cidlink.Link); ok {
+		return cl.Cid.Hash()
+	}
+	return cid.MustParse(link.String()).Hash()
+}
+
+// Link creates a CIDv1 link with the passed codec and multihash.
+func Link(codec multicodec.Code, digest multihash.Multihash) ipld.Link {
+	return cidlink.Link{Cid: cid.NewCidV1(uint64(codec), digest)}
+}
+
+// RawLink creates a link to raw bytes, such as a blob, with the multihash.
+func RawLink(digest multihash.Multihash) ipld.Link {
+	return Link(multicodec.Raw, digest)
+}
+
+// CARLink creates a link to a CAR, such as a shard or an index, with the
+// multihash.
+func CARLink(digest multihash.Multihash) ipld.Link {
+	return Link(multicodec.Car, digest)
+}
+
+// Key returns the key of the content a link refers to, the formatted multihash
+// of the link, which is independent of the codec of the link.
+func Key(link ipld.Link) string {
+	return Format(ExtractDigest(link))
+}
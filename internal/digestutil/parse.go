@@ -0,0 +1,52 @@
+package digestutil
+
+import (
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multibase"
+	"github.com/multiformats/go-multihash"
+)
+
+// Parse decodes a multihash from a multibase string in any encoding, such as
+// base58btc ("z..."), base32 ("b...") or base64 ("m..." or "u..."). A bare
+// base58 string, as used for CIDv0 and legacy multihash keys ("Qm..."), is also
+// accepted.
+func Parse(s string) (multihash.Multihash, error) {
+	_, b, err := multibase.Decode(s)
+	if err != nil {
+		digest, b58err := multihash.FromB58String(s)
+		if b58err != nil {
+			return nil, fmt.Errorf("decoding multibase digest %q: %w", s, err)
+		}
+		return digest, nil
+	}
+	digest, err := multihash.Cast(b)
+	if err != nil {
+		return nil, fmt.Errorf("decoding multihash %q: %w", s, err)
+	}
+	return digest, nil
+}
+
+// MustParse is like Parse but panics if the string cannot be parsed.
+func MustParse(s string) multihash.Multihash {
+	digest, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return digest
+}
+
+// ParseDigestOrCID decodes a multihash from either a multibase digest string
+// or a CID string, in which case the multihash of the CID is returned.
+func ParseDigestOrCID(s string) (multihash.Multihash, error) {
+	digest, err := Parse(s)
+	if err == nil {
+		return digest, nil
+	}
+	c, cerr := cid.Decode(s)
+	if cerr != nil {
+		return nil, fmt.Errorf("%q is neither a digest nor a CID: %w", s, err)
+	}
+	return c.Hash(), nil
+}
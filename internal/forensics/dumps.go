@@ -0,0 +1,186 @@
+package forensics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	indexer "github.com/ipni/go-indexer-core"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/indexing-service/pkg/blobindex"
+	"github.com/storacha/indexing-service/pkg/providerresults"
+	"github.com/storacha/storage/pkg/store/allocationstore/allocation"
+	"github.com/storacha/testthenetwork/internal/digestutil"
+	"github.com/storacha/testthenetwork/internal/printer"
+	"github.com/storacha/testthenetwork/internal/redis"
+)
+
+// Decoder writes a human readable form of a value from a store or cache.
+type Decoder func(w io.Writer, value []byte) error
+
+// Text dumps the text returned by the function.
+func Text(text func() string) Dump {
+	return func(w io.Writer) error {
+		_, err := io.WriteString(w, text())
+		return err
+	}
+}
+
+// JSON dumps the value returned by the function as indented JSON.
+func JSON(value func() any) Dump {
+	return func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(value())
+	}
+}
+
+// Datastore dumps every entry in the datastore in key order, decoding values
+// with the decoder. Values that cannot be decoded are dumped as their size
+// along with the decode error.
+func Datastore(ds datastore.Datastore, decode Decoder) Dump {
+	return func(w io.Writer) error {
+		results, err := ds.Query(context.Background(), query.Query{Orders: []query.Order{query.OrderByKey{}}})
+		if err != nil {
+			return fmt.Errorf("querying datastore: %w", err)
+		}
+		entries, err := results.Rest()
+		if err != nil {
+			return fmt.Errorf("iterating query results: %w", err)
+		}
+		fmt.Fprintf(w, "# Entries (%d)\n\n", len(entries))
+		for _, e := range entries {
+			fmt.Fprintf(w, "%s (%d bytes)\n", e.Key, len(e.Value))
+			writeDecoded(w, decode, e.Value)
+		}
+		return nil
+	}
+}
+
+// Redis dumps every key in the cache in sorted order with its TTL, decoding
+// values with the decoder. Keys that are multihashes are formatted as such.
+func Redis(cache redis.Inspector, decode Decoder) Dump {
+	return func(w io.Writer) error {
+		keys := cache.Keys()
+		fmt.Fprintf(w, "# Keys (%d)\n\n", len(keys))
+		for _, k := range keys {
+			ttl, _ := cache.TTL(k)
+			values, _ := cache.Values(k)
+			fmt.Fprintf(w, "%s (ttl: %s, values: %d)\n", digestutil.FormatBytes([]byte(k)), formatTTL(ttl), len(values))
+			for _, v := range values {
+				writeDecoded(w, decode, []byte(v))
+			}
+		}
+		return nil
+	}
+}
+
+func formatTTL(ttl time.Duration) string {
+	if ttl == redis.NoExpiry {
+		return "none"
+	}
+	return ttl.String()
+}
+
+func writeDecoded(w io.Writer, decode Decoder, value []byte) {
+	var buf bytes.Buffer
+	if err := decode(&buf, value); err != nil {
+		fmt.Fprintf(w, "\t(failed to decode: %s)\n", err)
+		return
+	}
+	w.Write(buf.Bytes())
+	fmt.Fprintln(w, "")
+}
+
+// IndexerValues dumps every multihash ingested by an IPNI indexer, with the
+// provider, context ID and metadata size of each value it maps to.
+func IndexerValues(core indexer.Interface) Dump {
+	return func(w io.Writer) error {
+		it, err := core.Iter()
+		if err != nil {
+			return fmt.Errorf("creating iterator: %w", err)
+		}
+		defer it.Close()
+		type record struct {
+			key    string
+			values []indexer.Value
+		}
+		var records []record
+		for {
+			digest, values, err := it.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("iterating values: %w", err)
+			}
+			records = append(records, record{digestutil.Format(digest), values})
+		}
+		sort.Slice(records, func(i, j int) bool { return records[i].key < records[j].key })
+		fmt.Fprintf(w, "# Multihashes (%d)\n\n", len(records))
+		for _, r := range records {
+			fmt.Fprintln(w, r.key)
+			for _, v := range r.values {
+				fmt.Fprintf(w, "\tProvider: %s, Context ID: %s, Metadata: %d bytes\n", v.ProviderID, digestutil.FormatBytes(v.ContextID), len(v.MetadataBytes))
+			}
+		}
+		return nil
+	}
+}
+
+// DecodeClaim decodes a claim archive.
+func DecodeClaim(w io.Writer, value []byte) error {
+	claim, err := delegation.Extract(value)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\tCID:        %s\n", claim.Link())
+	printer.WriteClaimText(w, claim)
+	return nil
+}
+
+// DecodeIndex decodes a sharded DAG index archive.
+func DecodeIndex(w io.Writer, value []byte) error {
+	index, err := blobindex.Extract(bytes.NewReader(value))
+	if err != nil {
+		return err
+	}
+	printer.WriteIndexText(w, index)
+	return nil
+}
+
+// DecodeProviderResult decodes a CBOR encoded IPNI provider result.
+func DecodeProviderResult(w io.Writer, value []byte) error {
+	result, err := providerresults.UnmarshalCBOR(value)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(result, "\t", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\t%s\n", b)
+	return nil
+}
+
+// DecodeAllocation decodes a DAG-CBOR encoded storage node blob allocation.
+func DecodeAllocation(w io.Writer, value []byte) error {
+	alloc, err := allocation.Decode(value, dagcbor.Decode)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\tSpace:   %s\n", alloc.Space)
+	fmt.Fprintf(w, "\tDigest:  %s\n", digestutil.Format(alloc.Blob.Digest))
+	fmt.Fprintf(w, "\tSize:    %d\n", alloc.Blob.Size)
+	fmt.Fprintf(w, "\tExpires: %s (%d)\n", time.Unix(int64(alloc.Expires), 0).UTC().Format(time.RFC3339), alloc.Expires)
+	fmt.Fprintf(w, "\tCause:   %s\n", alloc.Cause)
+	return nil
+}
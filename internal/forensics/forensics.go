@@ -0,0 +1,165 @@
+// Package forensics collects the state of the services in the network when a
+// test fails. Services register dumps of their stores and caches as they start
+// and, if the test fails, the dumps are written to a bundle in the artifacts
+// directory of the test before the services are stopped and their in-memory
+// state is lost.
+package forensics
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/storacha/testthenetwork/internal/steps"
+	"github.com/storacha/testthenetwork/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// AlwaysEnv is the environment variable that, when set to a non-empty value,
+// causes bundles to be written for tests that pass as well as those that fail.
+const AlwaysEnv = "TTN_FORENSICS_ALWAYS"
+
+// DirName is the name of the directory the bundle is written to, within the
+// artifacts directory of the test.
+const DirName = "forensics"
+
+// Dump writes the state of a component of the network.
+type Dump func(w io.Writer) error
+
+type entry struct {
+	name string
+	dump Dump
+}
+
+// Bundle is the set of dumps registered for a test.
+type Bundle struct {
+	mu      sync.Mutex
+	entries []entry
+}
+
+// Add registers a dump that is written to a file with the passed name. Dumps
+// are written in the order they were added.
+func (b *Bundle) Add(name string, dump Dump) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries = append(b.entries, entry{name, dump})
+}
+
+// Names returns the file names of the registered dumps.
+func (b *Bundle) Names() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var names []string
+	for _, e := range b.entries {
+		names = append(names, e.name)
+	}
+	return names
+}
+
+// Write writes each dump to a file in the directory, creating it if it does
+// not exist. A dump that fails does not prevent the others from being written;
+// its error is appended to its file and returned.
+func (b *Bundle) Write(dir string) error {
+	b.mu.Lock()
+	entries := append([]entry{}, b.entries...)
+	b.mu.Unlock()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	var errs []error
+	for _, e := range entries {
+		if err := writeDump(filepath.Join(dir, e.name), e.dump); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func writeDump(path string, dump Dump) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := dump(f); err != nil {
+		fmt.Fprintf(f, "\n(dump failed: %s)\n", err)
+		return err
+	}
+	return nil
+}
+
+var (
+	mutex   sync.Mutex
+	bundles = map[testing.TB]*Bundle{}
+)
+
+// For returns the bundle of the test, creating it on first use.
+func For(t testing.TB) *Bundle {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if b, ok := bundles[t]; ok {
+		return b
+	}
+	b := &Bundle{}
+	bundles[t] = b
+	t.Cleanup(func() {
+		mutex.Lock()
+		delete(bundles, t)
+		mutex.Unlock()
+	})
+	return b
+}
+
+// Add registers a dump in the bundle of the test.
+func Add(t testing.TB, name string, dump Dump) {
+	For(t).Add(name, dump)
+}
+
+// Capture arranges for the bundle of the test to be written when it fails,
+// along with the latest of each attachment on its timeline, such as the last
+// query results. Cleanup functions run in reverse order, so call it once the
+// services have started for the dumps to be taken before they are stopped.
+func Capture(t testing.TB) {
+	b := For(t)
+	rec := steps.For(t)
+	t.Cleanup(func() {
+		if !t.Failed() && os.Getenv(AlwaysEnv) == "" {
+			return
+		}
+		latest := map[string]string{}
+		var names []string
+		for _, a := range rec.Attachments() {
+			if _, ok := latest[a.Name]; !ok {
+				names = append(names, a.Name)
+			}
+			latest[a.Name] = a.Content
+		}
+		for _, name := range names {
+			b.Add(fileName(name)+".txt", Text(func() string { return latest[name] }))
+		}
+
+		dir := filepath.Join(testutil.ArtifactsDir(t), DirName)
+		err := b.Write(dir)
+		rec.Event("wrote forensics bundle", steps.A("dir", dir), steps.A("files", len(b.Names())))
+		require.NoError(t, err)
+	})
+}
+
+// fileName converts an attachment name such as "query results" to a file name.
+func fileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return '-'
+	}, name)
+}
@@ -0,0 +1,69 @@
+package forensics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/storacha/testthenetwork/internal/clock"
+	"github.com/storacha/testthenetwork/internal/digestutil"
+	"github.com/storacha/testthenetwork/internal/redis"
+	"github.com/storacha/testthenetwork/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundleWrite(t *testing.T) {
+	var b Bundle
+	b.Add("ok.txt", Text(func() string { return "hello" }))
+	b.Add("broken.txt", func(w io.Writer) error {
+		fmt.Fprint(w, "partial")
+		return errors.New("boom")
+	})
+	b.Add("after.json", JSON(func() any { return map[string]int{"n": 1} }))
+	require.Equal(t, []string{"ok.txt", "broken.txt", "after.json"}, b.Names())
+
+	dir := t.TempDir()
+	err := b.Write(dir)
+	require.ErrorContains(t, err, "broken.txt: boom")
+
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		return string(data)
+	}
+	require.Equal(t, "hello", read("ok.txt"))
+	require.Equal(t, "partial\n(dump failed: boom)\n", read("broken.txt"))
+	require.Equal(t, "{\n  \"n\": 1\n}\n", read("after.json"))
+}
+
+func TestRedis(t *testing.T) {
+	ctx := context.Background()
+	digest, _ := testutil.RandomBytes(t, 32)
+	m := redis.NewMapRedis(redis.WithClock(clock.NewFakeClock(time.Now())))
+	require.NoError(t, m.Set(ctx, string(digest), "claim", time.Hour).Err())
+	require.NoError(t, m.SAdd(ctx, "\x00key", "a", "b").Err())
+
+	decode := func(w io.Writer, value []byte) error {
+		if string(value) == "b" {
+			return errors.New("bad value")
+		}
+		_, err := fmt.Fprintf(w, "\t%s", value)
+		return err
+	}
+	var sb strings.Builder
+	require.NoError(t, Redis(m, decode)(&sb))
+	require.Equal(t, "# Keys (2)\n\n"+
+		"006b6579 (ttl: none, values: 2)\n\ta\n\t(failed to decode: bad value)\n"+
+		digestutil.Format(digest)+" (ttl: 1h0m0s, values: 1)\n\tclaim\n", sb.String())
+}
+
+func TestFileName(t *testing.T) {
+	require.Equal(t, "query-results", fileName("query results"))
+	require.Equal(t, "service-logs", fileName("Service Logs"))
+}
@@ -0,0 +1,180 @@
+// Package netstep has the steps tests take against the network, such as
+// putting and fetching blobs, publishing index claims and querying the
+// indexing service, shared by the network tests and the conformance suites.
+// Each step is recorded with the steps package and fails the test if it fails.
+package netstep
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-capabilities/pkg/assert"
+	"github.com/storacha/go-ucanto/core/dag/blockstore"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/principal"
+	"github.com/storacha/indexing-service/pkg/blobindex"
+	"github.com/storacha/indexing-service/pkg/client"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/storacha/testthenetwork/internal/claimverify"
+	"github.com/storacha/testthenetwork/internal/clock"
+	"github.com/storacha/testthenetwork/internal/digestutil"
+	"github.com/storacha/testthenetwork/internal/steps"
+	"github.com/storacha/testthenetwork/internal/tracing"
+	"github.com/stretchr/testify/require"
+)
+
+// PutBlob uploads the data to the location, requiring it to be accepted.
+func PutBlob(t *testing.T, location url.URL, headers http.Header, data []byte) {
+	status, body := TryPutBlob(t, location, headers, data)
+	require.Equal(t, http.StatusOK, status, body)
+}
+
+// TryPutBlob uploads the data to the location and returns the status code and
+// body of the response, so that rejections can be asserted.
+func TryPutBlob(t *testing.T, location url.URL, headers http.Header, data []byte) (int, string) {
+	step := steps.Start(t, "http/put", steps.A("url", location.String()), steps.A("size", len(data)))
+	defer step.Done()
+	req, err := http.NewRequestWithContext(tracing.Context(t), "PUT", location.String(), bytes.NewReader(data))
+	require.NoError(t, err)
+	req.Header = headers
+
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	step.Set(steps.A("status", res.StatusCode))
+	return res.StatusCode, strings.TrimSpace(string(body))
+}
+
+// FetchBlob fetches the blob at the location and returns its bytes and their
+// digest, hashed with the passed multihash function.
+func FetchBlob(t *testing.T, location url.URL, hash uint64) ([]byte, multihash.Multihash) {
+	step := steps.Start(t, "fetch blob", steps.A("url", location.String()))
+	defer step.Done()
+	req, err := http.NewRequestWithContext(tracing.Context(t), "GET", location.String(), nil)
+	require.NoError(t, err)
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	data, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	digest, err := multihash.Sum(data, hash, -1)
+	require.NoError(t, err)
+	step.Set(steps.A("digest", digestutil.Format(digest)))
+	return data, digest
+}
+
+// ExpiresIn creates a delegation option that expires the delegation after the
+// passed duration, relative to the current time of the passed clock.
+func ExpiresIn(clk clock.Clock, ttl time.Duration) delegation.Option {
+	return delegation.WithExpiration(int(clk.Now().Add(ttl).Unix()))
+}
+
+// PublishIndexClaim publishes a claim that the index indexes the content,
+// expiring 30 seconds from the current time of the clock.
+func PublishIndexClaim(t *testing.T, indexingClient *client.Client, clk clock.Clock, issuer principal.Signer, proof delegation.Proof, content ipld.Link, index ipld.Link) {
+	step := steps.Start(t, "assert/index", steps.A("content", content), steps.A("index", index))
+	defer step.Done()
+	err := indexingClient.PublishIndexClaim(tracing.Context(t), issuer, assert.IndexCaveats{
+		Content: content,
+		Index:   index,
+	}, delegation.WithProof(proof), ExpiresIn(clk, 30*time.Second))
+	require.NoError(t, err)
+}
+
+// QueryClaims queries the indexing service for the digests, filtered by the
+// spaces if any are passed.
+func QueryClaims(t *testing.T, indexingClient *client.Client, hashes []multihash.Multihash, spaces ...did.DID) types.QueryResult {
+	var digests []string
+	for _, digest := range hashes {
+		digests = append(digests, digestutil.Format(digest))
+	}
+	step := steps.Start(t, "query claims", steps.A("digest", strings.Join(digests, ",")))
+	defer step.Done()
+	if len(spaces) > 0 {
+		var ids []string
+		for _, space := range spaces {
+			ids = append(ids, space.String())
+		}
+		step.Set(steps.A("space", strings.Join(ids, ",")))
+	}
+	result, err := indexingClient.QueryClaims(tracing.Context(t), types.Query{
+		Hashes: hashes,
+		Match:  types.Match{Subject: spaces},
+	})
+	require.NoError(t, err)
+	step.Set(steps.A("claims", len(result.Claims())), steps.A("indexes", len(result.Indexes())))
+	return result
+}
+
+// AwaitQueryClaims queries for the digest until the result is not empty,
+// giving IPNI time to crawl to the head of the advertisement chain.
+func AwaitQueryClaims(t *testing.T, indexingClient *client.Client, hashes []multihash.Multihash, spaces ...did.DID) types.QueryResult {
+	var result types.QueryResult
+	for i := 0; i < 5; i++ {
+		result = QueryClaims(t, indexingClient, hashes, spaces...)
+		if len(result.Claims()) > 0 || len(result.Indexes()) > 0 {
+			break
+		}
+		steps.Note(t, "waiting for IPNI sync", steps.A("attempt", fmt.Sprintf("%d/5", i+1)))
+		time.Sleep(time.Second)
+	}
+	return result
+}
+
+// CollectIndexes extracts the indexes in the query result.
+func CollectIndexes(t *testing.T, result types.QueryResult) []blobindex.ShardedDagIndexView {
+	br, err := blockstore.NewBlockReader(blockstore.WithBlocksIterator(result.Blocks()))
+	require.NoError(t, err)
+
+	var indexes []blobindex.ShardedDagIndexView
+	for _, link := range result.Indexes() {
+		b, ok, err := br.Get(link)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		index, err := blobindex.Extract(bytes.NewReader(b.Bytes()))
+		require.NoError(t, err)
+		indexes = append(indexes, index)
+	}
+	return indexes
+}
+
+// CollectClaims decodes the claims in the query result.
+func CollectClaims(t *testing.T, result types.QueryResult) []delegation.Delegation {
+	br, err := blockstore.NewBlockReader(blockstore.WithBlocksIterator(result.Blocks()))
+	require.NoError(t, err)
+
+	var claims []delegation.Delegation
+	for _, link := range result.Claims() {
+		claim, err := delegation.NewDelegationView(link, br)
+		require.NoError(t, err)
+		claims = append(claims, claim)
+	}
+	return claims
+}
+
+// RequireVerifiedClaims requires every claim to be authentic, as verified on
+// behalf of the indexing service, with location commitments issued by the
+// storage node for locations it serves.
+func RequireVerifiedClaims(t *testing.T, claims []delegation.Delegation, indexingID, storageID principal.Signer, storageURL url.URL) {
+	step := steps.Start(t, "verify claims", steps.A("claims", len(claims)))
+	defer step.Done()
+	verifier := claimverify.New(indexingID.Verifier(), claimverify.WithStorageNode(storageID.DID(), storageURL))
+	err := verifier.VerifyAll(claims)
+	if err != nil {
+		step.Fail(err)
+	}
+	require.NoError(t, err)
+}
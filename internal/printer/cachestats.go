@@ -0,0 +1,19 @@
+package printer
+
+import (
+	"fmt"
+
+	"github.com/storacha/testthenetwork/internal/redis/record"
+)
+
+func PrintCacheStats(stats ...record.Stats) {
+	fmt.Println("")
+	fmt.Println("# Cache Stats")
+	fmt.Println("")
+	fmt.Printf("| %-10s | %6s | %6s | %6s | %6s |\n", "Cache", "Hits", "Misses", "Writes", "Errors")
+	fmt.Printf("| %-10s | %6s | %6s | %6s | %6s |\n", "----------", "-----:", "-----:", "-----:", "-----:")
+	for _, s := range stats {
+		fmt.Printf("| %-10s | %6d | %6d | %6d | %6d |\n", s.Name, s.Hits, s.Misses, s.Writes, s.Errors)
+	}
+	fmt.Println("")
+}
@@ -0,0 +1,186 @@
+package printer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/codec/dagjson"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/storacha/go-capabilities/pkg/assert"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/storacha/testthenetwork/internal/digestutil"
+)
+
+// WriteClaimText writes the details of a claim, including every capability
+// it contains, in the text format.
+func WriteClaimText(w io.Writer, claim delegation.Delegation) {
+	fmt.Fprintf(w, "\tIssuer:     %s\n", claim.Issuer().DID())
+	fmt.Fprintf(w, "\tAudience:   %s\n", claim.Audience().DID())
+	if exp := claim.Expiration(); exp != nil {
+		fmt.Fprintf(w, "\tExpiration: %s\n", formatTimestamp(*exp))
+	} else {
+		fmt.Fprintln(w, "\tExpiration: never")
+	}
+	if nbf := claim.NotBefore(); nbf != 0 {
+		fmt.Fprintf(w, "\tNot Before: %s\n", formatTimestamp(nbf))
+	} else {
+		fmt.Fprintln(w, "\tNot Before: -")
+	}
+	fmt.Fprintf(w, "\tProofs (%d):\n", len(claim.Proofs()))
+	for _, prf := range claim.Proofs() {
+		fmt.Fprintf(w, "\t\t%s\n", prf.String())
+	}
+	caps := claim.Capabilities()
+	fmt.Fprintf(w, "\tCapabilities (%d):\n", len(caps))
+	for i, cap := range caps {
+		fmt.Fprintf(w, "\t\t%d. %s\n", i+1, cap.Can())
+		fmt.Fprintf(w, "\t\t\tWith: %s\n", cap.With())
+		writeCaveatsText(w, cap)
+	}
+}
+
+func formatTimestamp(ts ucan.UTCUnixTimestamp) string {
+	return fmt.Sprintf("%s (%d)", time.Unix(int64(ts), 0).UTC().Format(time.RFC3339), ts)
+}
+
+// writeCaveatsText writes the caveats of a capability. Caveats of the known
+// content claim types are decoded, anything else (including caveats that fail
+// to decode) is written as DAG-JSON.
+func writeCaveatsText(w io.Writer, cap ucan.Capability[any]) {
+	nb, ok := cap.Nb().(datamodel.Node)
+	if !ok {
+		fmt.Fprintf(w, "\t\t\tCaveats: %v\n", cap.Nb())
+		return
+	}
+
+	var err error
+	switch cap.Can() {
+	case assert.LocationAbility:
+		err = writeLocationCaveats(w, nb)
+	case assert.IndexAbility:
+		err = writeIndexCaveats(w, nb)
+	case assert.EqualsAbility:
+		err = writeEqualsCaveats(w, nb)
+	case assert.PartitionAbility:
+		err = writePartitionCaveats(w, nb)
+	case assert.InclusionAbility:
+		err = writeInclusionCaveats(w, nb)
+	case assert.RelationAbility:
+		err = writeRelationCaveats(w, nb)
+	default:
+		writeCaveatsDAGJSON(w, nb)
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(w, "\t\t\tCaveats (failed to decode: %s):\n", err)
+		writeCaveatsDAGJSON(w, nb)
+	}
+}
+
+func writeCaveatsDAGJSON(w io.Writer, nb datamodel.Node) {
+	var buf bytes.Buffer
+	if err := dagjson.Encode(nb, &buf); err != nil {
+		fmt.Fprintf(w, "\t\t\tCaveats: <failed to encode: %s>\n", err)
+		return
+	}
+	fmt.Fprintf(w, "\t\t\tCaveats: %s\n", buf.String())
+}
+
+func writeLocationCaveats(w io.Writer, n datamodel.Node) error {
+	nb, err := assert.LocationCaveatsReader.Read(n)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\t\t\tSpace:    %s\n", nb.Space)
+	fmt.Fprintf(w, "\t\t\tContent:  %s\n", digestutil.Format(nb.Content.Hash()))
+	for _, l := range nb.Location {
+		fmt.Fprintf(w, "\t\t\tLocation: %s\n", &l)
+	}
+	if nb.Range != nil {
+		if nb.Range.Length != nil {
+			fmt.Fprintf(w, "\t\t\tRange:    %d-%d\n", nb.Range.Offset, nb.Range.Offset+*nb.Range.Length)
+		} else {
+			fmt.Fprintf(w, "\t\t\tRange:    %d-\n", nb.Range.Offset)
+		}
+	}
+	return nil
+}
+
+func writeIndexCaveats(w io.Writer, n datamodel.Node) error {
+	nb, err := assert.IndexCaveatsReader.Read(n)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\t\t\tContent: %s\n", nb.Content.String())
+	fmt.Fprintf(w, "\t\t\tIndex:   %s\n", nb.Index.String())
+	return nil
+}
+
+func writeEqualsCaveats(w io.Writer, n datamodel.Node) error {
+	nb, err := assert.EqualsCaveatsReader.Read(n)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\t\t\tContent: %s\n", digestutil.Format(nb.Content.Hash()))
+	fmt.Fprintf(w, "\t\t\tEquals:  %s\n", nb.Equals.String())
+	return nil
+}
+
+func writePartitionCaveats(w io.Writer, n datamodel.Node) error {
+	nb, err := assert.PartitionCaveatsReader.Read(n)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\t\t\tContent: %s\n", digestutil.Format(nb.Content.Hash()))
+	if nb.Blocks != nil {
+		fmt.Fprintf(w, "\t\t\tBlocks:  %s\n", (*nb.Blocks).String())
+	}
+	writeLinks(w, "Parts", nb.Parts)
+	return nil
+}
+
+func writeInclusionCaveats(w io.Writer, n datamodel.Node) error {
+	nb, err := assert.InclusionCaveatsReader.Read(n)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\t\t\tContent:  %s\n", digestutil.Format(nb.Content.Hash()))
+	fmt.Fprintf(w, "\t\t\tIncludes: %s\n", nb.Includes.String())
+	if nb.Proof != nil {
+		fmt.Fprintf(w, "\t\t\tProof:    %s\n", (*nb.Proof).String())
+	}
+	return nil
+}
+
+func writeRelationCaveats(w io.Writer, n datamodel.Node) error {
+	nb, err := assert.RelationCaveatsReader.Read(n)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\t\t\tContent: %s\n", digestutil.Format(nb.Content.Hash()))
+	writeLinks(w, "Children", nb.Children)
+	fmt.Fprintf(w, "\t\t\tParts (%d):\n", len(nb.Parts))
+	for _, part := range nb.Parts {
+		fmt.Fprintf(w, "\t\t\t\t%s\n", part.Content.String())
+		if part.Includes != nil {
+			fmt.Fprintf(w, "\t\t\t\t\tIncludes: %s\n", part.Includes.Content.String())
+			if part.Includes.Parts != nil {
+				for _, p := range *part.Includes.Parts {
+					fmt.Fprintf(w, "\t\t\t\t\t\t%s\n", p.String())
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func writeLinks(w io.Writer, name string, links []ipld.Link) {
+	fmt.Fprintf(w, "\t\t\t%s (%d):\n", name, len(links))
+	for _, l := range links {
+		fmt.Fprintf(w, "\t\t\t\t%s\n", l.String())
+	}
+}
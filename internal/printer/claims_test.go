@@ -0,0 +1,80 @@
+package printer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/fluent/qp"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/storacha/go-capabilities/pkg/assert"
+	"github.com/storacha/go-capabilities/pkg/types"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/storacha/testthenetwork/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// customCaveats are caveats of a capability the printer does not know about.
+type customCaveats struct{ Note string }
+
+func (c customCaveats) ToIPLD() (datamodel.Node, error) {
+	return qp.BuildMap(basicnode.Prototype.Any, 1, func(ma datamodel.MapAssembler) {
+		qp.MapEntry(ma, "note", qp.String(c.Note))
+	})
+}
+
+func TestWriteClaimText(t *testing.T) {
+	issuer := testutil.RandomSigner(t)
+	digest, _ := testutil.RandomBytes(t, 10)
+	part := testutil.RandomCID(t)
+	index := testutil.RandomCID(t)
+	child := testutil.RandomCID(t)
+	proof := testutil.RandomCID(t)
+	with := issuer.DID().String()
+
+	caps := []ucan.Capability[ucan.CaveatBuilder]{
+		ucan.NewCapability[ucan.CaveatBuilder](assert.PartitionAbility, with, assert.PartitionCaveats{
+			Content: types.FromHash(digest),
+			Parts:   []ipld.Link{part},
+		}),
+		ucan.NewCapability[ucan.CaveatBuilder](assert.InclusionAbility, with, assert.InclusionCaveats{
+			Content:  types.FromHash(digest),
+			Includes: index,
+			Proof:    &proof,
+		}),
+		ucan.NewCapability[ucan.CaveatBuilder](assert.RelationAbility, with, assert.RelationCaveats{
+			Content:  types.FromHash(digest),
+			Children: []ipld.Link{child},
+			Parts: []assert.RelationPart{{
+				Content:  part,
+				Includes: &assert.RelationPartInclusion{Content: index},
+			}},
+		}),
+		ucan.NewCapability[ucan.CaveatBuilder]("custom/thing", with, customCaveats{Note: "hello"}),
+	}
+	claim, err := delegation.Delegate(issuer, issuer, caps, delegation.WithNoExpiration())
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	WriteClaimText(&buf, claim)
+	out := buf.String()
+
+	require.Contains(t, out, "Expiration: never")
+	require.Contains(t, out, "Capabilities (4):")
+	require.Contains(t, out, "1. "+assert.PartitionAbility)
+	require.Contains(t, out, "2. "+assert.InclusionAbility)
+	require.Contains(t, out, "3. "+assert.RelationAbility)
+	require.Contains(t, out, "4. custom/thing")
+	require.Contains(t, out, "Parts (1):")
+	require.Contains(t, out, "Includes: "+index.String())
+	// The pinned go-capabilities inclusion schema names the proof field "range"
+	// so its reader cannot decode the caveats it encodes. The caveats should
+	// still be printed, as DAG-JSON.
+	require.Contains(t, out, "Caveats (failed to decode:")
+	require.Contains(t, out, `"includes":{"/":"`+index.String()+`"}`)
+	require.Contains(t, out, "Children (1):")
+	require.Contains(t, out, "\t\t\t\t"+child.String())
+	require.Contains(t, out, `Caveats: {"note":"hello"}`)
+}
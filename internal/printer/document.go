@@ -0,0 +1,222 @@
+package printer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/codec/dagjson"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/fluent/qp"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-ucanto/core/dag/blockstore"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/indexing-service/pkg/blobindex"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/storacha/testthenetwork/internal/digestutil"
+	"github.com/stretchr/testify/require"
+)
+
+// indexEntry is an index from a query result along with its link.
+type indexEntry struct {
+	link  ipld.Link
+	index blobindex.ShardedDagIndexView
+}
+
+// QueryResultsNode builds an IPLD node describing the query results. Claims
+// include every capability with its caveats and indexes include their shards
+// and slices. Links and multihashes are represented as IPLD links and bytes.
+func QueryResultsNode(t *testing.T, results types.QueryResult) datamodel.Node {
+	br, err := blockstore.NewBlockReader(blockstore.WithBlocksIterator(results.Blocks()))
+	require.NoError(t, err)
+
+	var claims []delegation.Delegation
+	for _, link := range results.Claims() {
+		claim, err := delegation.NewDelegationView(link, br)
+		require.NoError(t, err)
+		claims = append(claims, claim)
+	}
+
+	var indexes []indexEntry
+	for _, link := range results.Indexes() {
+		b, ok, err := br.Get(link)
+		require.NoError(t, err)
+		require.True(t, ok)
+		index, err := blobindex.Extract(bytes.NewReader(b.Bytes()))
+		require.NoError(t, err)
+		indexes = append(indexes, indexEntry{link, index})
+	}
+
+	n, err := qp.BuildMap(basicnode.Prototype.Any, 2, func(ma datamodel.MapAssembler) {
+		qp.MapEntry(ma, "claims", qp.List(int64(len(claims)), func(la datamodel.ListAssembler) {
+			for _, claim := range claims {
+				qp.ListEntry(la, claimAssembler(claim))
+			}
+		}))
+		qp.MapEntry(ma, "indexes", qp.List(int64(len(indexes)), func(la datamodel.ListAssembler) {
+			for _, entry := range indexes {
+				qp.ListEntry(la, indexAssembler(entry))
+			}
+		}))
+	})
+	require.NoError(t, err)
+	return n
+}
+
+func claimAssembler(claim delegation.Delegation) qp.Assemble {
+	return qp.Map(6, func(ma datamodel.MapAssembler) {
+		qp.MapEntry(ma, "link", qp.Link(claim.Link()))
+		qp.MapEntry(ma, "issuer", qp.String(claim.Issuer().DID().String()))
+		qp.MapEntry(ma, "audience", qp.String(claim.Audience().DID().String()))
+		if exp := claim.Expiration(); exp != nil {
+			qp.MapEntry(ma, "expiration", qp.Int(int64(*exp)))
+		} else {
+			qp.MapEntry(ma, "expiration", qp.Null())
+		}
+		qp.MapEntry(ma, "notBefore", qp.Int(int64(claim.NotBefore())))
+		qp.MapEntry(ma, "capabilities", qp.List(int64(len(claim.Capabilities())), func(la datamodel.ListAssembler) {
+			for _, cap := range claim.Capabilities() {
+				qp.ListEntry(la, qp.Map(3, func(ma datamodel.MapAssembler) {
+					qp.MapEntry(ma, "can", qp.String(cap.Can()))
+					qp.MapEntry(ma, "with", qp.String(cap.With()))
+					if nb, ok := cap.Nb().(datamodel.Node); ok {
+						qp.MapEntry(ma, "nb", qp.Node(nb))
+					} else {
+						qp.MapEntry(ma, "nb", qp.Null())
+					}
+				}))
+			}
+		}))
+	})
+}
+
+func indexAssembler(entry indexEntry) qp.Assemble {
+	return qp.Map(3, func(ma datamodel.MapAssembler) {
+		qp.MapEntry(ma, "link", qp.Link(entry.link))
+		qp.MapEntry(ma, "content", qp.Link(entry.index.Content()))
+		qp.MapEntry(ma, "shards", qp.List(int64(entry.index.Shards().Size()), func(la datamodel.ListAssembler) {
+			for shard, slices := range entry.index.Shards().Iterator() {
+				qp.ListEntry(la, qp.Map(2, func(ma datamodel.MapAssembler) {
+					qp.MapEntry(ma, "digest", qp.Bytes(shard))
+					qp.MapEntry(ma, "slices", qp.List(int64(slices.Size()), func(la datamodel.ListAssembler) {
+						for slice, position := range slices.Iterator() {
+							qp.ListEntry(la, qp.Map(3, func(ma datamodel.MapAssembler) {
+								qp.MapEntry(ma, "digest", qp.Bytes(slice))
+								qp.MapEntry(ma, "offset", qp.Int(int64(position.Offset)))
+								qp.MapEntry(ma, "length", qp.Int(int64(position.Length)))
+							}))
+						}
+					}))
+				}))
+			}
+		}))
+	})
+}
+
+func writeQueryResultsDAGJSON(t *testing.T, w io.Writer, results types.QueryResult) {
+	err := dagjson.Encode(QueryResultsNode(t, results), w)
+	require.NoError(t, err)
+	_, err = fmt.Fprintln(w)
+	require.NoError(t, err)
+}
+
+func writeQueryResultsJSON(t *testing.T, w io.Writer, results types.QueryResult) {
+	v, err := jsonValue(QueryResultsNode(t, results))
+	require.NoError(t, err)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	require.NoError(t, enc.Encode(v))
+}
+
+// jsonValue converts an IPLD node to a value that can be encoded with
+// encoding/json. Links become CID strings. Bytes are decoded according to the
+// field that holds them: the digest of content, shards and slices becomes a
+// base58btc multibase string and the space of a location commitment a DID
+// string. Other bytes use the DAG-JSON encoding.
+func jsonValue(n datamodel.Node) (any, error) {
+	return fieldValue("", n)
+}
+
+// fieldValue converts the value of the named field, or of a list entry if the
+// field is empty, as described by jsonValue.
+func fieldValue(field string, n datamodel.Node) (any, error) {
+	switch n.Kind() {
+	case datamodel.Kind_Null:
+		return nil, nil
+	case datamodel.Kind_Bool:
+		return n.AsBool()
+	case datamodel.Kind_Int:
+		return n.AsInt()
+	case datamodel.Kind_Float:
+		return n.AsFloat()
+	case datamodel.Kind_String:
+		return n.AsString()
+	case datamodel.Kind_Bytes:
+		b, err := n.AsBytes()
+		if err != nil {
+			return nil, err
+		}
+		return bytesValue(field, b), nil
+	case datamodel.Kind_Link:
+		l, err := n.AsLink()
+		if err != nil {
+			return nil, err
+		}
+		return l.String(), nil
+	case datamodel.Kind_List:
+		list := make([]any, 0, n.Length())
+		it := n.ListIterator()
+		for !it.Done() {
+			_, v, err := it.Next()
+			if err != nil {
+				return nil, err
+			}
+			jv, err := fieldValue("", v)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, jv)
+		}
+		return list, nil
+	case datamodel.Kind_Map:
+		m := make(map[string]any, n.Length())
+		it := n.MapIterator()
+		for !it.Done() {
+			k, v, err := it.Next()
+			if err != nil {
+				return nil, err
+			}
+			ks, err := k.AsString()
+			if err != nil {
+				return nil, err
+			}
+			jv, err := fieldValue(ks, v)
+			if err != nil {
+				return nil, err
+			}
+			m[ks] = jv
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("unsupported node kind: %s", n.Kind())
+}
+
+func bytesValue(field string, b []byte) any {
+	switch field {
+	case "digest":
+		if _, err := multihash.Decode(b); err == nil {
+			return digestutil.Format(b)
+		}
+	case "space":
+		if id, err := did.Decode(b); err == nil {
+			return id.String()
+		}
+	}
+	return map[string]any{"/": map[string]any{"bytes": base64.RawStdEncoding.EncodeToString(b)}}
+}
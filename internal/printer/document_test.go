@@ -0,0 +1,46 @@
+package printer
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/fluent/qp"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/storacha/testthenetwork/internal/digestutil"
+	"github.com/storacha/testthenetwork/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONValue(t *testing.T) {
+	digest, _ := testutil.RandomBytes(t, 10)
+	space := testutil.RandomPrincipal(t).DID()
+	data := []byte("not a multihash")
+	dagJSONBytes := func(b []byte) any {
+		return map[string]any{"/": map[string]any{"bytes": base64.RawStdEncoding.EncodeToString(b)}}
+	}
+
+	n, err := qp.BuildMap(basicnode.Prototype.Any, 4, func(ma datamodel.MapAssembler) {
+		qp.MapEntry(ma, "content", qp.Map(1, func(ma datamodel.MapAssembler) {
+			qp.MapEntry(ma, "digest", qp.Bytes(digest))
+		}))
+		qp.MapEntry(ma, "space", qp.Bytes(space.Bytes()))
+		// bytes that would decode as a multihash or a DID are only printed as
+		// one in a field known to hold one
+		qp.MapEntry(ma, "other", qp.List(2, func(la datamodel.ListAssembler) {
+			qp.ListEntry(la, qp.Bytes(digest))
+			qp.ListEntry(la, qp.Bytes(space.Bytes()))
+		}))
+		qp.MapEntry(ma, "digest", qp.Bytes(data))
+	})
+	require.NoError(t, err)
+
+	v, err := jsonValue(n)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{
+		"content": map[string]any{"digest": digestutil.Format(digest)},
+		"space":   space.String(),
+		"other":   []any{dagJSONBytes(digest), dagJSONBytes(space.Bytes())},
+		"digest":  dagJSONBytes(data),
+	}, v)
+}
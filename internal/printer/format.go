@@ -0,0 +1,48 @@
+package printer
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+// Format is an output format for query results.
+type Format string
+
+const (
+	// FormatText is a human readable Markdown-ish layout.
+	FormatText Format = "text"
+	// FormatJSON is a JSON document where links are strings, digests are
+	// base58btc multibase strings, spaces are DIDs and any other bytes use the
+	// DAG-JSON encoding.
+	FormatJSON Format = "json"
+	// FormatDAGJSON is a DAG-JSON document where links and bytes use the
+	// DAG-JSON encodings, so it can be decoded back into IPLD data.
+	FormatDAGJSON Format = "dag-json"
+)
+
+// ParseFormat parses the name of an output format.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatText, FormatJSON, FormatDAGJSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown format: %q", s)
+}
+
+// WriteQueryResults writes the query results to w in the passed format.
+func WriteQueryResults(t *testing.T, w io.Writer, format Format, results types.QueryResult) {
+	switch format {
+	case FormatText:
+		writeQueryResultsText(t, w, results)
+	case FormatJSON:
+		writeQueryResultsJSON(t, w, results)
+	case FormatDAGJSON:
+		writeQueryResultsDAGJSON(t, w, results)
+	default:
+		require.Failf(t, "unknown format", "format: %q", format)
+	}
+}
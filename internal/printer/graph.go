@@ -0,0 +1,305 @@
+package printer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-capabilities/pkg/assert"
+	"github.com/storacha/go-ucanto/core/dag/blockstore"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/indexing-service/pkg/blobindex"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/storacha/testthenetwork/internal/digestutil"
+	"github.com/stretchr/testify/require"
+)
+
+// GraphFormat is an output format for the query results graph.
+type GraphFormat string
+
+const (
+	// GraphMermaid is a Mermaid flowchart.
+	GraphMermaid GraphFormat = "mermaid"
+	// GraphDOT is a Graphviz DOT digraph.
+	GraphDOT GraphFormat = "dot"
+)
+
+// ParseGraphFormat parses the name of a graph output format.
+func ParseGraphFormat(s string) (GraphFormat, error) {
+	switch f := GraphFormat(s); f {
+	case GraphMermaid, GraphDOT:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown graph format: %q", s)
+}
+
+// Ext is the conventional file extension for the format.
+func (f GraphFormat) Ext() string {
+	if f == GraphMermaid {
+		return "mmd"
+	}
+	return string(f)
+}
+
+// WriteQueryResultsGraph writes a graph of the relationships between the
+// content, claims and indexes in the query results. Content is linked to the
+// claims made about it, index claims to their index, indexes to their shards
+// and slices, location commitments to their URLs and equals claims to the
+// equivalent content.
+func WriteQueryResultsGraph(t *testing.T, w io.Writer, format GraphFormat, results types.QueryResult) {
+	g := buildGraph(t, results)
+	switch format {
+	case GraphMermaid:
+		g.writeMermaid(w)
+	case GraphDOT:
+		g.writeDOT(w)
+	default:
+		require.Failf(t, "unknown graph format", "format: %q", format)
+	}
+}
+
+// QueryResultsGraph returns the graph of the query results in the passed format.
+func QueryResultsGraph(t *testing.T, format GraphFormat, results types.QueryResult) []byte {
+	var buf bytes.Buffer
+	WriteQueryResultsGraph(t, &buf, format, results)
+	return buf.Bytes()
+}
+
+type nodeKind string
+
+const (
+	contentNode nodeKind = "content"
+	claimNode   nodeKind = "claim"
+	indexNode   nodeKind = "index"
+	shardNode   nodeKind = "shard"
+	sliceNode   nodeKind = "slice"
+	urlNode     nodeKind = "url"
+)
+
+type graphNode struct {
+	id    string
+	kind  nodeKind
+	label string
+}
+
+type graphEdge struct {
+	from, to *graphNode
+	label    string
+}
+
+type graph struct {
+	nodes []*graphNode
+	keys  map[string]*graphNode
+	edges []graphEdge
+	seen  map[graphEdge]struct{}
+}
+
+func newGraph() *graph {
+	return &graph{keys: map[string]*graphNode{}, seen: map[graphEdge]struct{}{}}
+}
+
+// node returns the node for the key, adding it if it does not exist. Content
+// nodes are refined to index or shard nodes once their role is known.
+func (g *graph) node(key string, kind nodeKind, label string) *graphNode {
+	if n, ok := g.keys[key]; ok {
+		if n.kind == contentNode && (kind == indexNode || kind == shardNode) {
+			n.kind = kind
+		}
+		return n
+	}
+	n := &graphNode{id: fmt.Sprintf("n%d", len(g.nodes)), kind: kind, label: label}
+	g.nodes = append(g.nodes, n)
+	g.keys[key] = n
+	return n
+}
+
+func (g *graph) digest(kind nodeKind, digest multihash.Multihash) *graphNode {
+	return g.node(string(digest), kind, abbreviate(digestutil.Format(digest)))
+}
+
+func (g *graph) link(kind nodeKind, link ipld.Link) *graphNode {
+	return g.digest(kind, digestutil.ExtractDigest(link))
+}
+
+func (g *graph) edge(from, to *graphNode, label string) {
+	e := graphEdge{from, to, label}
+	if _, ok := g.seen[e]; ok {
+		return
+	}
+	g.seen[e] = struct{}{}
+	g.edges = append(g.edges, e)
+}
+
+func buildGraph(t *testing.T, results types.QueryResult) *graph {
+	br, err := blockstore.NewBlockReader(blockstore.WithBlocksIterator(results.Blocks()))
+	require.NoError(t, err)
+
+	g := newGraph()
+	for _, link := range results.Claims() {
+		claim, err := delegation.NewDelegationView(link, br)
+		require.NoError(t, err)
+		for _, cap := range claim.Capabilities() {
+			g.addCapability(claim, cap.Can(), cap.Nb())
+		}
+	}
+	for _, link := range results.Indexes() {
+		b, ok, err := br.Get(link)
+		require.NoError(t, err)
+		require.True(t, ok)
+		index, err := blobindex.Extract(bytes.NewReader(b.Bytes()))
+		require.NoError(t, err)
+
+		in := g.link(indexNode, link)
+		g.edge(in, g.link(contentNode, index.Content()), "content")
+		for shard, slices := range index.Shards().Iterator() {
+			sn := g.digest(shardNode, shard)
+			g.edge(in, sn, "shard")
+			for slice, position := range slices.Iterator() {
+				g.edge(sn, g.digest(sliceNode, slice), fmt.Sprintf("%d-%d", position.Offset, position.Offset+position.Length))
+			}
+		}
+	}
+	return g
+}
+
+func (g *graph) addCapability(claim delegation.Delegation, can string, caveats any) {
+	nb, ok := caveats.(datamodel.Node)
+	if !ok {
+		return
+	}
+	cn := g.node(claim.Link().String(), claimNode, fmt.Sprintf("%s\n%s", can, abbreviate(claim.Link().String())))
+	if digest, ok := contentDigest(nb); ok {
+		g.edge(g.digest(contentNode, digest), cn, strings.TrimPrefix(can, "assert/"))
+	}
+
+	switch can {
+	case assert.LocationAbility:
+		if loc, err := assert.LocationCaveatsReader.Read(nb); err == nil {
+			cn.label = fmt.Sprintf("%s\nspace: %s", cn.label, abbreviate(loc.Space.String()))
+			for _, u := range loc.Location {
+				g.edge(cn, g.node(u.String(), urlNode, u.String()), "")
+			}
+		}
+	case assert.IndexAbility:
+		if idx, err := assert.IndexCaveatsReader.Read(nb); err == nil {
+			g.edge(cn, g.link(indexNode, idx.Index), "")
+		}
+	case assert.EqualsAbility:
+		if eq, err := assert.EqualsCaveatsReader.Read(nb); err == nil {
+			g.edge(cn, g.link(contentNode, eq.Equals), "equals")
+		}
+	case assert.PartitionAbility:
+		if part, err := assert.PartitionCaveatsReader.Read(nb); err == nil {
+			for _, l := range part.Parts {
+				g.edge(cn, g.link(contentNode, l), "part")
+			}
+		}
+	case assert.InclusionAbility:
+		if inc, err := assert.InclusionCaveatsReader.Read(nb); err == nil {
+			g.edge(cn, g.link(indexNode, inc.Includes), "includes")
+		}
+	case assert.RelationAbility:
+		if rel, err := assert.RelationCaveatsReader.Read(nb); err == nil {
+			for _, l := range rel.Children {
+				g.edge(cn, g.link(contentNode, l), "child")
+			}
+		}
+	}
+}
+
+// contentDigest extracts the digest of the content a claim is about from its
+// caveats. Content is either a link or a map with a multihash digest.
+func contentDigest(nb datamodel.Node) (multihash.Multihash, bool) {
+	n, err := nb.LookupByString("content")
+	if err != nil {
+		return nil, false
+	}
+	if l, err := n.AsLink(); err == nil {
+		return digestutil.ExtractDigest(l), true
+	}
+	dn, err := n.LookupByString("digest")
+	if err != nil {
+		return nil, false
+	}
+	b, err := dn.AsBytes()
+	if err != nil {
+		return nil, false
+	}
+	digest, err := multihash.Cast(b)
+	if err != nil {
+		return nil, false
+	}
+	return digest, true
+}
+
+// abbreviate shortens long identifiers so the graph stays readable.
+func abbreviate(s string) string {
+	if len(s) <= 20 {
+		return s
+	}
+	return s[:10] + "…" + s[len(s)-6:]
+}
+
+var mermaidShapes = map[nodeKind][2]string{
+	contentNode: {"([", "])"},
+	claimNode:   {"{{", "}}"},
+	indexNode:   {"[[", "]]"},
+	shardNode:   {"[", "]"},
+	sliceNode:   {"(", ")"},
+	urlNode:     {">", "]"},
+}
+
+func (g *graph) writeMermaid(w io.Writer) {
+	fmt.Fprintln(w, "flowchart LR")
+	for _, n := range g.nodes {
+		shape := mermaidShapes[n.kind]
+		label := strings.ReplaceAll(fmt.Sprintf("%s\n%s", n.kind, n.label), `"`, "#quot;")
+		label = strings.ReplaceAll(label, "\n", "<br/>")
+		fmt.Fprintf(w, "\t%s%s\"%s\"%s\n", n.id, shape[0], label, shape[1])
+	}
+	for _, e := range g.edges {
+		if e.label == "" {
+			fmt.Fprintf(w, "\t%s --> %s\n", e.from.id, e.to.id)
+		} else {
+			fmt.Fprintf(w, "\t%s -->|%s| %s\n", e.from.id, e.label, e.to.id)
+		}
+	}
+}
+
+var dotShapes = map[nodeKind]string{
+	contentNode: "ellipse",
+	claimNode:   "hexagon",
+	indexNode:   "box3d",
+	shardNode:   "box",
+	sliceNode:   "note",
+	urlNode:     "cds",
+}
+
+func (g *graph) writeDOT(w io.Writer) {
+	fmt.Fprintln(w, "digraph query_results {")
+	fmt.Fprintln(w, "\trankdir=LR;")
+	fmt.Fprintln(w, "\tnode [fontname=\"monospace\"];")
+	for _, n := range g.nodes {
+		fmt.Fprintf(w, "\t%s [label=%s, shape=%s];\n", n.id, dotQuote(fmt.Sprintf("%s\n%s", n.kind, n.label)), dotShapes[n.kind])
+	}
+	for _, e := range g.edges {
+		if e.label == "" {
+			fmt.Fprintf(w, "\t%s -> %s;\n", e.from.id, e.to.id)
+		} else {
+			fmt.Fprintf(w, "\t%s -> %s [label=%s];\n", e.from.id, e.to.id, dotQuote(e.label))
+		}
+	}
+	fmt.Fprintln(w, "}")
+}
+
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
@@ -0,0 +1,120 @@
+package printer
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-capabilities/pkg/assert"
+	ctypes "github.com/storacha/go-capabilities/pkg/types"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/storacha/indexing-service/pkg/blobindex"
+	"github.com/storacha/indexing-service/pkg/bytemap"
+	"github.com/storacha/indexing-service/pkg/service/queryresult"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/storacha/testthenetwork/internal/digestutil"
+	"github.com/storacha/testthenetwork/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// orderedResult lists the claims of a query result in a fixed order, as
+// queryresult.Build takes them in a map.
+type orderedResult struct {
+	types.QueryResult
+	claims []ipld.Link
+}
+
+func (r orderedResult) Claims() []ipld.Link { return r.claims }
+
+func TestQueryResultsGraph(t *testing.T) {
+	issuer := testutil.RandomSigner(t)
+	space := testutil.RandomPrincipal(t).DID()
+	root := testutil.RandomCID(t)
+	shard, _ := testutil.RandomBytes(t, 10)
+	slice, _ := testutil.RandomBytes(t, 10)
+	location := testutil.Must(url.Parse("https://storage.example/blob"))(t)
+
+	index := blobindex.NewShardedDagIndexView(root, 1)
+	index.SetSlice(shard, slice, blobindex.Position{Offset: 10, Length: 20})
+	archive := testutil.Must(io.ReadAll(testutil.Must(index.Archive())(t)))(t)
+	indexLink := digestutil.CARLink(testutil.Must(multihash.Sum(archive, multihash.SHA2_256, -1))(t))
+
+	indexClaim := testutil.Must(delegation.Delegate(issuer, issuer, []ucan.Capability[assert.IndexCaveats]{
+		ucan.NewCapability(assert.IndexAbility, issuer.DID().String(), assert.IndexCaveats{Content: root, Index: indexLink}),
+	}, delegation.WithNoExpiration()))(t)
+	locationClaim := testutil.Must(delegation.Delegate(issuer, issuer, []ucan.Capability[assert.LocationCaveats]{
+		ucan.NewCapability(assert.LocationAbility, issuer.DID().String(), assert.LocationCaveats{
+			Space:    space,
+			Content:  ctypes.FromHash(shard),
+			Location: []url.URL{*location},
+		}),
+	}, delegation.WithNoExpiration()))(t)
+
+	indexes := bytemap.NewByteMap[types.EncodedContextID, blobindex.ShardedDagIndexView](1)
+	indexes.Set(types.EncodedContextID("context"), index)
+	built := testutil.Must(queryresult.Build(map[cid.Cid]delegation.Delegation{
+		indexClaim.Link().(cidlink.Link).Cid:    indexClaim,
+		locationClaim.Link().(cidlink.Link).Cid: locationClaim,
+	}, indexes))(t)
+	result := orderedResult{built, []ipld.Link{indexClaim.Link(), locationClaim.Link()}}
+
+	// the nodes, in the order they are added, and the edges between them
+	labels := []string{
+		"claim\nassert/index\n" + abbreviate(indexClaim.Link().String()),
+		"content\n" + abbreviate(digestutil.Format(digestutil.ExtractDigest(root))),
+		"index\n" + abbreviate(digestutil.Format(digestutil.ExtractDigest(indexLink))),
+		"claim\nassert/location\n" + abbreviate(locationClaim.Link().String()) + "\nspace: " + abbreviate(space.String()),
+		"shard\n" + abbreviate(digestutil.Format(shard)),
+		"url\nhttps://storage.example/blob",
+		"slice\n" + abbreviate(digestutil.Format(slice)),
+	}
+	edges := [][3]string{
+		{"n1", "n0", "index"},
+		{"n0", "n2", ""},
+		{"n4", "n3", "location"},
+		{"n3", "n5", ""},
+		{"n2", "n1", "content"},
+		{"n2", "n4", "shard"},
+		{"n4", "n6", "10-30"},
+	}
+
+	t.Run("mermaid", func(t *testing.T) {
+		shapes := [][2]string{{"{{", "}}"}, {"([", "])"}, {"[[", "]]"}, {"{{", "}}"}, {"[", "]"}, {">", "]"}, {"(", ")"}}
+		want := []string{"flowchart LR"}
+		for i, label := range labels {
+			want = append(want, fmt.Sprintf("\tn%d%s\"%s\"%s", i, shapes[i][0], strings.ReplaceAll(label, "\n", "<br/>"), shapes[i][1]))
+		}
+		for _, e := range edges {
+			if e[2] == "" {
+				want = append(want, "\t"+e[0]+" --> "+e[1])
+			} else {
+				want = append(want, "\t"+e[0]+" -->|"+e[2]+"| "+e[1])
+			}
+		}
+		require.Equal(t, strings.Join(want, "\n")+"\n", string(QueryResultsGraph(t, GraphMermaid, result)))
+	})
+
+	t.Run("dot", func(t *testing.T) {
+		shapes := []string{"hexagon", "ellipse", "box3d", "hexagon", "box", "cds", "note"}
+		want := []string{"digraph query_results {", "\trankdir=LR;", "\tnode [fontname=\"monospace\"];"}
+		for i, label := range labels {
+			want = append(want, fmt.Sprintf("\tn%d [label=\"%s\", shape=%s];", i, strings.ReplaceAll(label, "\n", `\n`), shapes[i]))
+		}
+		for _, e := range edges {
+			if e[2] == "" {
+				want = append(want, "\t"+e[0]+" -> "+e[1]+";")
+			} else {
+				want = append(want, "\t"+e[0]+" -> "+e[1]+" [label=\""+e[2]+"\"];")
+			}
+		}
+		want = append(want, "}")
+		require.Equal(t, strings.Join(want, "\n")+"\n", string(QueryResultsGraph(t, GraphDOT, result)))
+	})
+}
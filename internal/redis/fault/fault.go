@@ -0,0 +1,223 @@
+package fault
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/storacha/indexing-service/pkg/redis"
+)
+
+// ErrTimeout is the error returned for calls faulted with a timeout. It wraps
+// os.ErrDeadlineExceeded, like the network errors returned by the real client.
+var ErrTimeout = fmt.Errorf("redis: %w", os.ErrDeadlineExceeded)
+
+// Fault describes how a call is misbehaved.
+type Fault struct {
+	// Latency is the time to wait before the call is forwarded to the wrapped
+	// client, or before the error is returned.
+	Latency time.Duration
+	// Err is returned instead of forwarding the call to the wrapped client. Use
+	// goredis.Nil to simulate a key that is missing.
+	Err error
+	// Timeout waits for Latency (or the context to be canceled) and then
+	// returns ErrTimeout instead of forwarding the call.
+	Timeout bool
+}
+
+// Rule selects calls that should be faulted.
+type Rule struct {
+	// Methods are the client methods the rule applies to e.g. "Get", "SAdd". The
+	// rule applies to all methods if empty.
+	Methods []string
+	// Keys selects the keys the rule applies to. The rule applies to all keys
+	// if nil.
+	Keys func(key string) bool
+	// Probability is the chance a matching call is faulted, in the range
+	// (0, 1]. Zero means every matching call is faulted. It is ignored if a
+	// script is set.
+	Probability float64
+	// Fault is applied to matching calls. It is ignored if a script is set.
+	Fault Fault
+	// Script is a sequence of faults applied to consecutive matching calls. A
+	// nil entry lets the call through unaltered. The rule no longer applies
+	// once the script is exhausted.
+	Script []*Fault
+}
+
+type rule struct {
+	Rule
+	calls int
+}
+
+// FaultyRedis is a client that injects errors, timeouts and latency into calls
+// to a wrapped client according to configured rules. Rules are evaluated in
+// the order they were added and the first matching rule wins. It is safe for
+// concurrent use.
+type FaultyRedis struct {
+	mutex sync.Mutex
+	child redis.Client
+	rules []*rule
+	rand  *rand.Rand
+}
+
+var _ redis.Client = (*FaultyRedis)(nil)
+
+// Option configures a FaultyRedis.
+type Option func(f *FaultyRedis)
+
+// WithRand configures the source of randomness used for probabilistic rules.
+func WithRand(r *rand.Rand) Option {
+	return func(f *FaultyRedis) {
+		f.rand = r
+	}
+}
+
+// WithRules configures the initial rules.
+func WithRules(rules ...Rule) Option {
+	return func(f *FaultyRedis) {
+		for _, r := range rules {
+			f.rules = append(f.rules, &rule{Rule: r})
+		}
+	}
+}
+
+// Wrap creates a client that injects faults into calls to the passed client.
+// No calls are faulted until rules are added.
+func Wrap(c redis.Client, options ...Option) *FaultyRedis {
+	f := &FaultyRedis{child: c, rand: rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))}
+	for _, opt := range options {
+		opt(f)
+	}
+	return f
+}
+
+// Add appends rules to the end of the rule list.
+func (f *FaultyRedis) Add(rules ...Rule) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, r := range rules {
+		f.rules = append(f.rules, &rule{Rule: r})
+	}
+}
+
+// Clear removes all rules.
+func (f *FaultyRedis) Clear() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.rules = nil
+}
+
+func (f *FaultyRedis) Expire(ctx context.Context, key string, expiration time.Duration) *goredis.BoolCmd {
+	if err := f.inject(ctx, "Expire", key); err != nil {
+		cmd := goredis.NewBoolCmd(ctx, nil)
+		cmd.SetErr(err)
+		return cmd
+	}
+	return f.child.Expire(ctx, key, expiration)
+}
+
+func (f *FaultyRedis) Get(ctx context.Context, key string) *goredis.StringCmd {
+	if err := f.inject(ctx, "Get", key); err != nil {
+		cmd := goredis.NewStringCmd(ctx, nil)
+		cmd.SetErr(err)
+		return cmd
+	}
+	return f.child.Get(ctx, key)
+}
+
+func (f *FaultyRedis) Persist(ctx context.Context, key string) *goredis.BoolCmd {
+	if err := f.inject(ctx, "Persist", key); err != nil {
+		cmd := goredis.NewBoolCmd(ctx, nil)
+		cmd.SetErr(err)
+		return cmd
+	}
+	return f.child.Persist(ctx, key)
+}
+
+func (f *FaultyRedis) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *goredis.StatusCmd {
+	if err := f.inject(ctx, "Set", key); err != nil {
+		cmd := goredis.NewStatusCmd(ctx, nil)
+		cmd.SetErr(err)
+		return cmd
+	}
+	return f.child.Set(ctx, key, value, expiration)
+}
+
+func (f *FaultyRedis) SAdd(ctx context.Context, key string, values ...interface{}) *goredis.IntCmd {
+	if err := f.inject(ctx, "SAdd", key); err != nil {
+		cmd := goredis.NewIntCmd(ctx, nil)
+		cmd.SetErr(err)
+		return cmd
+	}
+	return f.child.SAdd(ctx, key, values...)
+}
+
+func (f *FaultyRedis) SMembers(ctx context.Context, key string) *goredis.StringSliceCmd {
+	if err := f.inject(ctx, "SMembers", key); err != nil {
+		cmd := goredis.NewStringSliceCmd(ctx, nil)
+		cmd.SetErr(err)
+		return cmd
+	}
+	return f.child.SMembers(ctx, key)
+}
+
+// inject applies the fault selected for the call, if any. It returns the error
+// the call should fail with, or nil if the call should be forwarded.
+func (f *FaultyRedis) inject(ctx context.Context, method, key string) error {
+	fault := f.match(method, key)
+	if fault == nil {
+		return nil
+	}
+	if fault.Latency > 0 {
+		timer := time.NewTimer(fault.Latency)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if fault.Timeout {
+		return ErrTimeout
+	}
+	return fault.Err
+}
+
+func (f *FaultyRedis) match(method, key string) *Fault {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, r := range f.rules {
+		if len(r.Methods) > 0 && !slices.Contains(r.Methods, method) {
+			continue
+		}
+		if r.Keys != nil && !r.Keys(key) {
+			continue
+		}
+		if r.Script != nil {
+			if r.calls >= len(r.Script) {
+				continue
+			}
+			fault := r.Script[r.calls]
+			r.calls++
+			return fault
+		}
+		if r.Probability > 0 && f.rand.Float64() >= r.Probability {
+			continue
+		}
+		return &r.Fault
+	}
+	return nil
+}
+
+// KeyIn creates a key selector that matches any of the passed keys.
+func KeyIn(keys ...string) func(key string) bool {
+	return func(key string) bool {
+		return slices.Contains(keys, key)
+	}
+}
@@ -0,0 +1,162 @@
+package fault
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"os"
+	"testing"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	tnredis "github.com/storacha/testthenetwork/internal/redis"
+	"github.com/stretchr/testify/require"
+)
+
+var errBroken = errors.New("broken")
+
+func TestFaultyRedis(t *testing.T) {
+	ctx := context.Background()
+
+	newRedis := func(t *testing.T, options ...Option) *FaultyRedis {
+		m := tnredis.NewMapRedis()
+		require.NoError(t, m.Set(ctx, "a", "1", 0).Err())
+		require.NoError(t, m.Set(ctx, "b", "2", 0).Err())
+		return Wrap(m, options...)
+	}
+
+	t.Run("no rules", func(t *testing.T) {
+		f := newRedis(t)
+		require.Equal(t, "1", f.Get(ctx, "a").Val())
+		require.NoError(t, f.SAdd(ctx, "s", "x").Err())
+		require.Equal(t, []string{"x"}, f.SMembers(ctx, "s").Val())
+	})
+
+	t.Run("selects methods and keys", func(t *testing.T) {
+		f := newRedis(t, WithRules(Rule{
+			Methods: []string{"Get", "Persist"},
+			Keys:    KeyIn("a"),
+			Fault:   Fault{Err: errBroken},
+		}))
+		require.ErrorIs(t, f.Get(ctx, "a").Err(), errBroken)
+		require.ErrorIs(t, f.Persist(ctx, "a").Err(), errBroken)
+		require.Equal(t, "2", f.Get(ctx, "b").Val())
+		require.NoError(t, f.Set(ctx, "a", "3", 0).Err())
+		require.NoError(t, f.Expire(ctx, "a", time.Hour).Err())
+
+		f.Clear()
+		require.Equal(t, "3", f.Get(ctx, "a").Val())
+	})
+
+	t.Run("every method can be faulted", func(t *testing.T) {
+		f := newRedis(t, WithRules(Rule{Fault: Fault{Err: errBroken}}))
+		require.ErrorIs(t, f.Expire(ctx, "a", time.Hour).Err(), errBroken)
+		require.ErrorIs(t, f.Get(ctx, "a").Err(), errBroken)
+		require.ErrorIs(t, f.Persist(ctx, "a").Err(), errBroken)
+		require.ErrorIs(t, f.Set(ctx, "a", "1", 0).Err(), errBroken)
+		require.ErrorIs(t, f.SAdd(ctx, "s", "x").Err(), errBroken)
+		require.ErrorIs(t, f.SMembers(ctx, "s").Err(), errBroken)
+	})
+
+	t.Run("script runs out", func(t *testing.T) {
+		f := newRedis(t)
+		f.Add(Rule{
+			Methods: []string{"Get"},
+			Script:  []*Fault{{Err: goredis.Nil}, nil, {Err: errBroken}},
+		})
+		require.ErrorIs(t, f.Get(ctx, "a").Err(), goredis.Nil)
+		require.Equal(t, "1", f.Get(ctx, "a").Val())
+		require.ErrorIs(t, f.Get(ctx, "a").Err(), errBroken)
+		for range 3 {
+			require.Equal(t, "1", f.Get(ctx, "a").Val())
+		}
+	})
+
+	t.Run("script counts only matching calls", func(t *testing.T) {
+		f := newRedis(t, WithRules(Rule{
+			Keys:   KeyIn("a"),
+			Script: []*Fault{{Err: errBroken}},
+		}))
+		require.Equal(t, "2", f.Get(ctx, "b").Val())
+		require.ErrorIs(t, f.Get(ctx, "a").Err(), errBroken)
+		require.Equal(t, "1", f.Get(ctx, "a").Val())
+	})
+
+	t.Run("probability", func(t *testing.T) {
+		const calls = 1000
+		faulted := func(seed uint64) []bool {
+			f := newRedis(t, WithRand(rand.New(rand.NewPCG(seed, seed))), WithRules(Rule{
+				Probability: 0.25,
+				Fault:       Fault{Err: errBroken},
+			}))
+			var results []bool
+			for range calls {
+				results = append(results, f.Get(ctx, "a").Err() != nil)
+			}
+			return results
+		}
+
+		results := faulted(1)
+		n := 0
+		for _, r := range results {
+			if r {
+				n++
+			}
+		}
+		require.InDelta(t, calls/4, n, calls/20)
+		// the same seed faults the same calls
+		require.Equal(t, results, faulted(1))
+	})
+
+	t.Run("rules are evaluated in order", func(t *testing.T) {
+		errFirst, errSecond := errors.New("first"), errors.New("second")
+		f := newRedis(t, WithRules(
+			Rule{Keys: KeyIn("a"), Fault: Fault{Err: errFirst}},
+			Rule{Fault: Fault{Err: errSecond}},
+		))
+		require.ErrorIs(t, f.Get(ctx, "a").Err(), errFirst)
+		require.ErrorIs(t, f.Get(ctx, "b").Err(), errSecond)
+
+		// a later rule applies once an earlier one's script is exhausted
+		f = newRedis(t, WithRules(
+			Rule{Script: []*Fault{{Err: errFirst}}},
+			Rule{Fault: Fault{Err: errSecond}},
+		))
+		require.ErrorIs(t, f.Get(ctx, "a").Err(), errFirst)
+		require.ErrorIs(t, f.Get(ctx, "a").Err(), errSecond)
+	})
+
+	t.Run("probabilistic rule that does not fire falls through", func(t *testing.T) {
+		f := newRedis(t, WithRules(
+			// fires only if rand.Float64 returns 0
+			Rule{Probability: 5e-324, Fault: Fault{Err: errBroken}},
+			Rule{Fault: Fault{Err: goredis.Nil}},
+		))
+		for range 10 {
+			require.ErrorIs(t, f.Get(ctx, "a").Err(), goredis.Nil)
+		}
+	})
+
+	t.Run("latency", func(t *testing.T) {
+		f := newRedis(t, WithRules(Rule{Fault: Fault{Latency: 20 * time.Millisecond}}))
+		start := time.Now()
+		require.Equal(t, "1", f.Get(ctx, "a").Val())
+		require.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	})
+
+	t.Run("latency is cut short by the context", func(t *testing.T) {
+		f := newRedis(t, WithRules(Rule{Fault: Fault{Latency: time.Minute}}))
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, f.Get(ctx, "a").Err(), context.DeadlineExceeded)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		f := newRedis(t, WithRules(Rule{Fault: Fault{Latency: 20 * time.Millisecond, Timeout: true}}))
+		start := time.Now()
+		err := f.Get(ctx, "a").Err()
+		require.ErrorIs(t, err, ErrTimeout)
+		require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+		require.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	})
+}
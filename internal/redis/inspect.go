@@ -0,0 +1,161 @@
+package redis
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipni/go-libipni/find/model"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/indexing-service/pkg/blobindex"
+	"github.com/storacha/indexing-service/pkg/providerresults"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/storacha/testthenetwork/internal/digestutil"
+)
+
+// NoExpiry is the TTL reported for keys that do not expire.
+const NoExpiry = time.Duration(-1)
+
+// Inspector is implemented by clients whose contents can be examined by tests.
+type Inspector interface {
+	// Keys returns the keys that have not expired, in sorted order.
+	Keys() []string
+	// TTL returns the time remaining until the key expires, or NoExpiry if the
+	// key does not expire. It returns false if the key does not exist.
+	TTL(key string) (time.Duration, bool)
+	// Values returns the raw values stored at the key. It returns false if the
+	// key does not exist.
+	Values(key string) ([]string, bool)
+}
+
+// ProvidersCache decodes the contents of the indexing service providers cache,
+// which maps multihashes to IPNI provider results.
+type ProvidersCache struct {
+	Inspector
+}
+
+// Digests returns the multihashes that have cached provider results.
+func (c ProvidersCache) Digests() []multihash.Multihash {
+	var digests []multihash.Multihash
+	for _, k := range c.Keys() {
+		digests = append(digests, multihash.Multihash(k))
+	}
+	return digests
+}
+
+// Get returns the provider results cached for the multihash. It returns
+// types.ErrKeyNotFound if there are none.
+func (c ProvidersCache) Get(digest multihash.Multihash) ([]model.ProviderResult, error) {
+	values, ok := c.Values(string(digest))
+	if !ok {
+		return nil, types.ErrKeyNotFound
+	}
+	var results []model.ProviderResult
+	for _, v := range values {
+		r, err := providerresults.UnmarshalCBOR([]byte(v))
+		if err != nil {
+			return nil, fmt.Errorf("decoding provider result: %w", err)
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// ExpiresIn returns the time remaining until the provider results for the
+// multihash expire, or NoExpiry if they do not expire.
+func (c ProvidersCache) ExpiresIn(digest multihash.Multihash) (time.Duration, bool) {
+	return c.TTL(string(digest))
+}
+
+// ClaimsCache decodes the contents of the indexing service claims cache, which
+// maps claim CIDs to claims.
+type ClaimsCache struct {
+	Inspector
+}
+
+// All returns every claim in the cache.
+func (c ClaimsCache) All() ([]delegation.Delegation, error) {
+	var claims []delegation.Delegation
+	for _, k := range c.Keys() {
+		claim, err := c.get(k)
+		if err != nil {
+			return nil, err
+		}
+		claims = append(claims, claim)
+	}
+	return claims, nil
+}
+
+// Get returns the cached claim with the passed CID. It returns
+// types.ErrKeyNotFound if the claim is not cached.
+func (c ClaimsCache) Get(claim ipld.Link) (delegation.Delegation, error) {
+	return c.get(string(digestutil.ExtractDigest(claim)))
+}
+
+func (c ClaimsCache) get(key string) (delegation.Delegation, error) {
+	values, ok := c.Values(key)
+	if !ok || len(values) == 0 {
+		return nil, types.ErrKeyNotFound
+	}
+	claim, err := delegation.Extract([]byte(values[0]))
+	if err != nil {
+		return nil, fmt.Errorf("decoding claim: %w", err)
+	}
+	return claim, nil
+}
+
+// ExpiresIn returns the time remaining until the claim with the passed CID
+// expires, or NoExpiry if it does not expire.
+func (c ClaimsCache) ExpiresIn(claim ipld.Link) (time.Duration, bool) {
+	return c.TTL(string(digestutil.ExtractDigest(claim)))
+}
+
+// IndexesCache decodes the contents of the indexing service indexes cache,
+// which maps encoded IPNI context IDs to sharded DAG indexes.
+type IndexesCache struct {
+	Inspector
+}
+
+// ContextIDs returns the context IDs that have a cached index.
+func (c IndexesCache) ContextIDs() []types.EncodedContextID {
+	var ids []types.EncodedContextID
+	for _, k := range c.Keys() {
+		ids = append(ids, types.EncodedContextID(k))
+	}
+	return ids
+}
+
+// All returns every index in the cache.
+func (c IndexesCache) All() ([]blobindex.ShardedDagIndexView, error) {
+	var indexes []blobindex.ShardedDagIndexView
+	for _, id := range c.ContextIDs() {
+		index, err := c.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+// Get returns the index cached for the context ID. It returns
+// types.ErrKeyNotFound if there is none.
+func (c IndexesCache) Get(contextID types.EncodedContextID) (blobindex.ShardedDagIndexView, error) {
+	values, ok := c.Values(string(contextID))
+	if !ok || len(values) == 0 {
+		return nil, types.ErrKeyNotFound
+	}
+	index, err := blobindex.Extract(bytes.NewReader([]byte(values[0])))
+	if err != nil {
+		return nil, fmt.Errorf("decoding index: %w", err)
+	}
+	return index, nil
+}
+
+// ExpiresIn returns the time remaining until the index for the context ID
+// expires, or NoExpiry if it does not expire.
+func (c IndexesCache) ExpiresIn(contextID types.EncodedContextID) (time.Duration, bool) {
+	return c.TTL(string(contextID))
+}
@@ -2,60 +2,115 @@ package redis
 
 import (
 	"context"
+	"encoding"
+	"fmt"
 	"maps"
 	"slices"
+	"strconv"
 	"time"
 
 	goredis "github.com/redis/go-redis/v9"
 	"github.com/storacha/indexing-service/pkg/redis"
+	"github.com/storacha/testthenetwork/internal/clock"
+)
+
+// ErrWrongType is returned when a command is issued against a key holding a
+// value of a different type, matching the error returned by a Redis server.
+var ErrWrongType = redisError("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+// redisError is an error returned by the "server", as opposed to a network or
+// client error. It implements goredis.Error.
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+
+func (redisError) RedisError() {}
+
+var _ goredis.Error = redisError("")
+
+type valueType int
+
+const (
+	stringType valueType = iota
+	setType
 )
 
 type redisValue struct {
-	data    map[string]struct{}
+	typ     valueType
+	str     string
+	set     map[string]struct{}
 	expires time.Time
 }
 
+// MapRedis is an in-memory client that models the subset of Redis used by the
+// indexing service: string and set types, key expiry and WRONGTYPE errors.
+// Expired keys are evicted lazily, when they are next accessed. It is not safe
+// for concurrent use.
 type MapRedis struct {
-	data map[string]*redisValue
+	data  map[string]*redisValue
+	clock clock.Clock
 }
 
 var _ redis.Client = (*MapRedis)(nil)
 
-func NewMapRedis() *MapRedis {
-	return &MapRedis{data: make(map[string]*redisValue)}
+// Option configures a MapRedis.
+type Option func(m *MapRedis)
+
+// WithClock configures the clock used to calculate and check key expiry. The
+// system clock is used by default.
+func WithClock(c clock.Clock) Option {
+	return func(m *MapRedis) {
+		m.clock = c
+	}
+}
+
+func NewMapRedis(options ...Option) *MapRedis {
+	m := &MapRedis{data: make(map[string]*redisValue), clock: clock.NewSystemClock()}
+	for _, opt := range options {
+		opt(m)
+	}
+	return m
 }
 
+// Expire sets a timeout on the key. Like Redis, a non-positive expiration
+// deletes the key. It returns false if the key does not exist.
 func (m *MapRedis) Expire(ctx context.Context, key string, expiration time.Duration) *goredis.BoolCmd {
-	cmd := goredis.NewBoolCmd(ctx, nil)
-	val, ok := m.data[key]
-	if ok {
-		val.expires = time.Now().Add(expiration)
-		cmd.SetVal(true)
+	cmd := goredis.NewBoolCmd(ctx, "expire", key, expiration)
+	val, ok := m.lookup(key)
+	if !ok {
+		return cmd
 	}
+	if expiration <= 0 {
+		delete(m.data, key)
+	} else {
+		val.expires = m.clock.Now().Add(expiration)
+	}
+	cmd.SetVal(true)
 	return cmd
 }
 
+// Get returns the value of a string key. It returns goredis.Nil if the key does
+// not exist and ErrWrongType if the key holds a set.
 func (m *MapRedis) Get(ctx context.Context, key string) *goredis.StringCmd {
-	cmd := goredis.NewStringCmd(ctx, nil)
-	val, ok := m.data[key]
+	cmd := goredis.NewStringCmd(ctx, "get", key)
+	val, ok := m.lookup(key)
 	if !ok {
 		cmd.SetErr(goredis.Nil)
-	} else {
-		if !val.expires.IsZero() && val.expires.Before(time.Now()) {
-			cmd.SetErr(goredis.Nil)
-		} else {
-			for k := range val.data {
-				cmd.SetVal(k)
-				break
-			}
-		}
+		return cmd
+	}
+	if val.typ != stringType {
+		cmd.SetErr(ErrWrongType)
+		return cmd
 	}
+	cmd.SetVal(val.str)
 	return cmd
 }
 
+// Persist removes the timeout on the key. It returns false if the key does not
+// exist or does not have a timeout.
 func (m *MapRedis) Persist(ctx context.Context, key string) *goredis.BoolCmd {
-	cmd := goredis.NewBoolCmd(ctx, nil)
-	val, ok := m.data[key]
+	cmd := goredis.NewBoolCmd(ctx, "persist", key)
+	val, ok := m.lookup(key)
 	if ok && !val.expires.IsZero() {
 		val.expires = time.Time{}
 		cmd.SetVal(true)
@@ -63,47 +118,180 @@ func (m *MapRedis) Persist(ctx context.Context, key string) *goredis.BoolCmd {
 	return cmd
 }
 
+// Set sets the key to hold the string value, replacing any existing value
+// regardless of its type. Like Redis, any existing timeout is discarded unless
+// expiration is goredis.KeepTTL.
 func (m *MapRedis) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *goredis.StatusCmd {
-	cmd := goredis.NewStatusCmd(ctx, nil)
+	cmd := goredis.NewStatusCmd(ctx, "set", key, value)
+	str, err := toString(value)
+	if err != nil {
+		cmd.SetErr(err)
+		return cmd
+	}
 	var expires time.Time
 	if expiration > 0 {
-		expires = time.Now().Add(expiration)
+		expires = m.clock.Now().Add(expiration)
+	} else if expiration == goredis.KeepTTL {
+		if val, ok := m.lookup(key); ok {
+			expires = val.expires
+		}
 	}
-	data := map[string]struct{}{value.(string): {}}
-	m.data[key] = &redisValue{data, expires}
+	m.data[key] = &redisValue{typ: stringType, str: str, expires: expires}
+	cmd.SetVal("OK")
 	return cmd
 }
 
+// SAdd adds the values to the set stored at the key, creating it if it does not
+// exist. It returns the number of values that were not already members and
+// ErrWrongType if the key holds a string.
 func (m *MapRedis) SAdd(ctx context.Context, key string, values ...interface{}) *goredis.IntCmd {
-	cmd := goredis.NewIntCmd(ctx, nil)
-	data := map[string]struct{}{}
-	expires := time.Time{}
-	val, ok := m.data[key]
-	if ok {
-		data = val.data
-		expires = val.expires
+	cmd := goredis.NewIntCmd(ctx, append([]interface{}{"sadd", key}, values...)...)
+	if len(values) == 0 {
+		cmd.SetErr(redisError("ERR wrong number of arguments for 'sadd' command"))
+		return cmd
 	}
-	written := uint64(0)
+	members := make([]string, 0, len(values))
 	for _, v := range values {
-		_, ok := data[v.(string)]
-		if !ok {
-			data[v.(string)] = struct{}{}
+		str, err := toString(v)
+		if err != nil {
+			cmd.SetErr(err)
+			return cmd
+		}
+		members = append(members, str)
+	}
+	val, ok := m.lookup(key)
+	if !ok {
+		val = &redisValue{typ: setType, set: map[string]struct{}{}}
+		m.data[key] = val
+	} else if val.typ != setType {
+		cmd.SetErr(ErrWrongType)
+		return cmd
+	}
+	written := int64(0)
+	for _, member := range members {
+		if _, ok := val.set[member]; !ok {
+			val.set[member] = struct{}{}
 			written++
 		}
 	}
-	m.data[key] = &redisValue{data, expires}
-	cmd.SetVal(int64(written))
+	cmd.SetVal(written)
 	return cmd
 }
 
+// SMembers returns the members of the set stored at the key. Like Redis, it
+// returns an empty list (and no error) if the key does not exist and
+// ErrWrongType if the key holds a string.
 func (m *MapRedis) SMembers(ctx context.Context, key string) *goredis.StringSliceCmd {
-	cmd := goredis.NewStringSliceCmd(ctx, nil)
-	val, ok := m.data[key]
+	cmd := goredis.NewStringSliceCmd(ctx, "smembers", key)
+	val, ok := m.lookup(key)
 	if !ok {
-		cmd.SetErr(goredis.Nil)
-	} else {
-		values := slices.Collect(maps.Keys(val.data))
-		cmd.SetVal(values)
+		cmd.SetVal([]string{})
+		return cmd
 	}
+	if val.typ != setType {
+		cmd.SetErr(ErrWrongType)
+		return cmd
+	}
+	cmd.SetVal(slices.Collect(maps.Keys(val.set)))
 	return cmd
 }
+
+// lookup returns the value stored at the key, evicting it if it has expired.
+func (m *MapRedis) lookup(key string) (*redisValue, bool) {
+	val, ok := m.data[key]
+	if !ok {
+		return nil, false
+	}
+	if m.expired(val) {
+		delete(m.data, key)
+		return nil, false
+	}
+	return val, true
+}
+
+var _ Inspector = (*MapRedis)(nil)
+
+// Keys returns the keys that have not expired, in sorted order.
+func (m *MapRedis) Keys() []string {
+	var keys []string
+	for k, v := range m.data {
+		if m.expired(v) {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// TTL returns the time remaining until the key expires, or NoExpiry if the key
+// does not expire. It returns false if the key does not exist.
+func (m *MapRedis) TTL(key string) (time.Duration, bool) {
+	val, ok := m.data[key]
+	if !ok || m.expired(val) {
+		return 0, false
+	}
+	if val.expires.IsZero() {
+		return NoExpiry, true
+	}
+	return val.expires.Sub(m.clock.Now()), true
+}
+
+// Values returns the raw values stored at the key: the value of a string key
+// or the members of a set key. It returns false if the key does not exist.
+func (m *MapRedis) Values(key string) ([]string, bool) {
+	val, ok := m.data[key]
+	if !ok || m.expired(val) {
+		return nil, false
+	}
+	if val.typ == stringType {
+		return []string{val.str}, true
+	}
+	values := slices.Collect(maps.Keys(val.set))
+	slices.Sort(values)
+	return values, true
+}
+
+// expired reports whether the value has expired. Like Redis, a key expires
+// when its expiry time is reached.
+func (m *MapRedis) expired(val *redisValue) bool {
+	return !val.expires.IsZero() && !m.clock.Now().Before(val.expires)
+}
+
+// toString converts a command argument to the string stored by Redis, in the
+// same way the go-redis client encodes arguments.
+func toString(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case int:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case time.Duration:
+		return strconv.FormatInt(v.Nanoseconds(), 10), nil
+	case encoding.BinaryMarshaler:
+		b, err := v.MarshalBinary()
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	default:
+		return "", fmt.Errorf("redis: can't marshal %T (implement encoding.BinaryMarshaler)", v)
+	}
+}
@@ -0,0 +1,222 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/storacha/testthenetwork/internal/clock"
+	"github.com/stretchr/testify/require"
+)
+
+// The expectations in these tests follow the documented behaviour of the Redis
+// commands: https://redis.io/docs/latest/commands/
+
+func TestMapRedis(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("get missing key", func(t *testing.T) {
+		m := NewMapRedis()
+		err := m.Get(ctx, "k").Err()
+		require.ErrorIs(t, err, goredis.Nil)
+	})
+
+	t.Run("set and get", func(t *testing.T) {
+		m := NewMapRedis()
+		require.Equal(t, "OK", m.Set(ctx, "k", "v", 0).Val())
+		v, err := m.Get(ctx, "k").Result()
+		require.NoError(t, err)
+		require.Equal(t, "v", v)
+	})
+
+	t.Run("set encodes arguments", func(t *testing.T) {
+		m := NewMapRedis()
+		require.NoError(t, m.Set(ctx, "bytes", []byte("v"), 0).Err())
+		require.NoError(t, m.Set(ctx, "int", 42, 0).Err())
+		require.NoError(t, m.Set(ctx, "bool", true, 0).Err())
+		require.Equal(t, "v", m.Get(ctx, "bytes").Val())
+		require.Equal(t, "42", m.Get(ctx, "int").Val())
+		require.Equal(t, "1", m.Get(ctx, "bool").Val())
+		require.Error(t, m.Set(ctx, "struct", struct{}{}, 0).Err())
+	})
+
+	t.Run("get set key is wrong type", func(t *testing.T) {
+		m := NewMapRedis()
+		require.NoError(t, m.SAdd(ctx, "k", "a", "b").Err())
+		err := m.Get(ctx, "k").Err()
+		require.ErrorIs(t, err, ErrWrongType)
+		var rerr goredis.Error
+		require.ErrorAs(t, err, &rerr)
+	})
+
+	t.Run("set replaces set key", func(t *testing.T) {
+		m := NewMapRedis()
+		require.NoError(t, m.SAdd(ctx, "k", "a").Err())
+		require.NoError(t, m.Set(ctx, "k", "v", 0).Err())
+		require.Equal(t, "v", m.Get(ctx, "k").Val())
+		require.ErrorIs(t, m.SMembers(ctx, "k").Err(), ErrWrongType)
+	})
+
+	t.Run("sadd string key is wrong type", func(t *testing.T) {
+		m := NewMapRedis()
+		require.NoError(t, m.Set(ctx, "k", "v", 0).Err())
+		require.ErrorIs(t, m.SAdd(ctx, "k", "a").Err(), ErrWrongType)
+		require.Equal(t, "v", m.Get(ctx, "k").Val())
+	})
+
+	t.Run("sadd counts new members", func(t *testing.T) {
+		m := NewMapRedis()
+		require.Equal(t, int64(2), m.SAdd(ctx, "k", "a", "b").Val())
+		require.Equal(t, int64(1), m.SAdd(ctx, "k", "b", "c").Val())
+		require.Equal(t, int64(0), m.SAdd(ctx, "k", "a", "a").Val())
+		require.ElementsMatch(t, []string{"a", "b", "c"}, m.SMembers(ctx, "k").Val())
+	})
+
+	t.Run("sadd without members", func(t *testing.T) {
+		m := NewMapRedis()
+		require.Error(t, m.SAdd(ctx, "k").Err())
+		_, ok := m.Values("k")
+		require.False(t, ok)
+	})
+
+	t.Run("smembers missing key is empty", func(t *testing.T) {
+		m := NewMapRedis()
+		v, err := m.SMembers(ctx, "k").Result()
+		require.NoError(t, err)
+		require.Empty(t, v)
+	})
+
+	t.Run("smembers string key is wrong type", func(t *testing.T) {
+		m := NewMapRedis()
+		require.NoError(t, m.Set(ctx, "k", "v", 0).Err())
+		require.ErrorIs(t, m.SMembers(ctx, "k").Err(), ErrWrongType)
+	})
+
+	t.Run("set with expiration", func(t *testing.T) {
+		clk := clock.NewFakeClock(time.Now())
+		m := NewMapRedis(WithClock(clk))
+		require.NoError(t, m.Set(ctx, "k", "v", time.Minute).Err())
+
+		ttl, ok := m.TTL("k")
+		require.True(t, ok)
+		require.Equal(t, time.Minute, ttl)
+
+		clk.Advance(time.Minute - time.Second)
+		require.Equal(t, "v", m.Get(ctx, "k").Val())
+
+		clk.Advance(time.Second)
+		require.ErrorIs(t, m.Get(ctx, "k").Err(), goredis.Nil)
+	})
+
+	t.Run("set discards ttl", func(t *testing.T) {
+		clk := clock.NewFakeClock(time.Now())
+		m := NewMapRedis(WithClock(clk))
+		require.NoError(t, m.Set(ctx, "k", "v", time.Minute).Err())
+		require.NoError(t, m.Set(ctx, "k", "v2", 0).Err())
+
+		ttl, ok := m.TTL("k")
+		require.True(t, ok)
+		require.Equal(t, NoExpiry, ttl)
+	})
+
+	t.Run("set keeps ttl", func(t *testing.T) {
+		clk := clock.NewFakeClock(time.Now())
+		m := NewMapRedis(WithClock(clk))
+		require.NoError(t, m.Set(ctx, "k", "v", time.Minute).Err())
+		clk.Advance(time.Second)
+		require.NoError(t, m.Set(ctx, "k", "v2", goredis.KeepTTL).Err())
+
+		ttl, ok := m.TTL("k")
+		require.True(t, ok)
+		require.Equal(t, time.Minute-time.Second, ttl)
+		require.Equal(t, "v2", m.Get(ctx, "k").Val())
+	})
+
+	t.Run("smembers honours expiry", func(t *testing.T) {
+		clk := clock.NewFakeClock(time.Now())
+		m := NewMapRedis(WithClock(clk))
+		require.NoError(t, m.SAdd(ctx, "k", "a").Err())
+		require.True(t, m.Expire(ctx, "k", time.Minute).Val())
+
+		clk.Advance(time.Minute)
+		v, err := m.SMembers(ctx, "k").Result()
+		require.NoError(t, err)
+		require.Empty(t, v)
+	})
+
+	t.Run("sadd to expired key creates new set", func(t *testing.T) {
+		clk := clock.NewFakeClock(time.Now())
+		m := NewMapRedis(WithClock(clk))
+		require.NoError(t, m.SAdd(ctx, "k", "a").Err())
+		require.True(t, m.Expire(ctx, "k", time.Minute).Val())
+
+		clk.Advance(time.Hour)
+		require.Equal(t, int64(1), m.SAdd(ctx, "k", "b").Val())
+		require.Equal(t, []string{"b"}, m.SMembers(ctx, "k").Val())
+
+		ttl, ok := m.TTL("k")
+		require.True(t, ok)
+		require.Equal(t, NoExpiry, ttl)
+	})
+
+	t.Run("sadd keeps ttl", func(t *testing.T) {
+		clk := clock.NewFakeClock(time.Now())
+		m := NewMapRedis(WithClock(clk))
+		require.NoError(t, m.SAdd(ctx, "k", "a").Err())
+		require.True(t, m.Expire(ctx, "k", time.Minute).Val())
+		require.NoError(t, m.SAdd(ctx, "k", "b").Err())
+
+		ttl, ok := m.TTL("k")
+		require.True(t, ok)
+		require.Equal(t, time.Minute, ttl)
+	})
+
+	t.Run("expired keys are evicted", func(t *testing.T) {
+		clk := clock.NewFakeClock(time.Now())
+		m := NewMapRedis(WithClock(clk))
+		require.NoError(t, m.Set(ctx, "k", "v", time.Minute).Err())
+		clk.Advance(time.Minute)
+		require.Empty(t, m.Keys())
+
+		require.ErrorIs(t, m.Get(ctx, "k").Err(), goredis.Nil)
+		require.NotContains(t, m.data, "k")
+	})
+
+	t.Run("expire missing key", func(t *testing.T) {
+		m := NewMapRedis()
+		v, err := m.Expire(ctx, "k", time.Minute).Result()
+		require.NoError(t, err)
+		require.False(t, v)
+	})
+
+	t.Run("expire expired key", func(t *testing.T) {
+		clk := clock.NewFakeClock(time.Now())
+		m := NewMapRedis(WithClock(clk))
+		require.NoError(t, m.Set(ctx, "k", "v", time.Minute).Err())
+		clk.Advance(time.Minute)
+		require.False(t, m.Expire(ctx, "k", time.Minute).Val())
+	})
+
+	t.Run("expire with non-positive duration deletes", func(t *testing.T) {
+		m := NewMapRedis()
+		require.NoError(t, m.Set(ctx, "k", "v", 0).Err())
+		require.True(t, m.Expire(ctx, "k", 0).Val())
+		require.ErrorIs(t, m.Get(ctx, "k").Err(), goredis.Nil)
+	})
+
+	t.Run("persist", func(t *testing.T) {
+		clk := clock.NewFakeClock(time.Now())
+		m := NewMapRedis(WithClock(clk))
+		require.False(t, m.Persist(ctx, "k").Val())
+
+		require.NoError(t, m.Set(ctx, "k", "v", 0).Err())
+		require.False(t, m.Persist(ctx, "k").Val()) // no ttl to remove
+
+		require.True(t, m.Expire(ctx, "k", time.Minute).Val())
+		require.True(t, m.Persist(ctx, "k").Val())
+
+		clk.Advance(time.Hour)
+		require.Equal(t, "v", m.Get(ctx, "k").Val())
+	})
+}
@@ -0,0 +1,188 @@
+package record
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/storacha/indexing-service/pkg/redis"
+)
+
+// Outcome is the result of a command sent to a Redis client.
+type Outcome string
+
+const (
+	// Hit means a read found the key.
+	Hit Outcome = "hit"
+	// Miss means a read or expiry change did not find the key.
+	Miss Outcome = "miss"
+	// OK means a write or expiry change succeeded.
+	OK Outcome = "ok"
+	// Error means the command failed.
+	Error Outcome = "error"
+)
+
+// Command is a record of a single command sent to a Redis client.
+type Command struct {
+	Method  string
+	Key     string
+	Outcome Outcome
+	Err     error
+	Latency time.Duration
+}
+
+// Stats are aggregated counts of the outcomes of commands sent to a Redis
+// client.
+type Stats struct {
+	// Name identifies the client the stats were collected for.
+	Name   string
+	Hits   int
+	Misses int
+	Writes int
+	Errors int
+}
+
+// RecordingRedis is a client that records every command it forwards to a
+// wrapped client. It is safe for concurrent use.
+type RecordingRedis struct {
+	mutex    sync.Mutex
+	name     string
+	child    redis.Client
+	commands []Command
+}
+
+var _ redis.Client = (*RecordingRedis)(nil)
+
+// Wrap creates a client that records commands sent to the passed client. The
+// name identifies the client in stats, e.g. "providers", "claims" or "indexes".
+func Wrap(name string, c redis.Client) *RecordingRedis {
+	return &RecordingRedis{name: name, child: c}
+}
+
+func (r *RecordingRedis) Expire(ctx context.Context, key string, expiration time.Duration) *goredis.BoolCmd {
+	start := time.Now()
+	cmd := r.child.Expire(ctx, key, expiration)
+	r.record("Expire", key, expiryOutcome(cmd), cmd.Err(), start)
+	return cmd
+}
+
+func (r *RecordingRedis) Get(ctx context.Context, key string) *goredis.StringCmd {
+	start := time.Now()
+	cmd := r.child.Get(ctx, key)
+	r.record("Get", key, readOutcome(cmd.Err(), true), cmd.Err(), start)
+	return cmd
+}
+
+func (r *RecordingRedis) Persist(ctx context.Context, key string) *goredis.BoolCmd {
+	start := time.Now()
+	cmd := r.child.Persist(ctx, key)
+	r.record("Persist", key, expiryOutcome(cmd), cmd.Err(), start)
+	return cmd
+}
+
+func (r *RecordingRedis) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *goredis.StatusCmd {
+	start := time.Now()
+	cmd := r.child.Set(ctx, key, value, expiration)
+	r.record("Set", key, writeOutcome(cmd.Err()), cmd.Err(), start)
+	return cmd
+}
+
+func (r *RecordingRedis) SAdd(ctx context.Context, key string, values ...interface{}) *goredis.IntCmd {
+	start := time.Now()
+	cmd := r.child.SAdd(ctx, key, values...)
+	r.record("SAdd", key, writeOutcome(cmd.Err()), cmd.Err(), start)
+	return cmd
+}
+
+func (r *RecordingRedis) SMembers(ctx context.Context, key string) *goredis.StringSliceCmd {
+	start := time.Now()
+	cmd := r.child.SMembers(ctx, key)
+	r.record("SMembers", key, readOutcome(cmd.Err(), len(cmd.Val()) > 0), cmd.Err(), start)
+	return cmd
+}
+
+// Name returns the name the client was wrapped with.
+func (r *RecordingRedis) Name() string {
+	return r.name
+}
+
+// Commands returns the commands recorded so far, in the order they completed.
+func (r *RecordingRedis) Commands() []Command {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	commands := make([]Command, len(r.commands))
+	copy(commands, r.commands)
+	return commands
+}
+
+// Stats aggregates the outcomes of the commands recorded so far.
+func (r *RecordingRedis) Stats() Stats {
+	stats := Stats{Name: r.name}
+	for _, c := range r.Commands() {
+		switch c.Outcome {
+		case Hit:
+			stats.Hits++
+		case Miss:
+			stats.Misses++
+		case OK:
+			stats.Writes++
+		case Error:
+			stats.Errors++
+		}
+	}
+	return stats
+}
+
+// Reset discards the commands recorded so far.
+func (r *RecordingRedis) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.commands = nil
+}
+
+func (r *RecordingRedis) record(method, key string, outcome Outcome, err error, start time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.commands = append(r.commands, Command{
+		Method:  method,
+		Key:     key,
+		Outcome: outcome,
+		Err:     err,
+		Latency: time.Since(start),
+	})
+}
+
+func readOutcome(err error, found bool) Outcome {
+	if errors.Is(err, goredis.Nil) {
+		return Miss
+	}
+	if err != nil {
+		return Error
+	}
+	if !found {
+		return Miss
+	}
+	return Hit
+}
+
+func writeOutcome(err error) Outcome {
+	if err != nil {
+		return Error
+	}
+	return OK
+}
+
+// expiryOutcome classifies the result of Expire or Persist, which report false
+// when the key does not exist (or, for Persist, has no timeout to remove) and
+// so changed nothing.
+func expiryOutcome(cmd *goredis.BoolCmd) Outcome {
+	if cmd.Err() != nil {
+		return Error
+	}
+	if !cmd.Val() {
+		return Miss
+	}
+	return OK
+}
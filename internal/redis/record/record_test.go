@@ -0,0 +1,91 @@
+package record
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	tnredis "github.com/storacha/testthenetwork/internal/redis"
+	"github.com/storacha/testthenetwork/internal/redis/fault"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordingRedis(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("outcomes", func(t *testing.T) {
+		m := tnredis.NewMapRedis()
+		require.NoError(t, m.Set(ctx, "string", "v", time.Hour).Err())
+		require.NoError(t, m.Set(ctx, "persistent", "v", 0).Err())
+		require.NoError(t, m.SAdd(ctx, "set", "a").Err())
+		r := Wrap("test", m)
+
+		for _, tc := range []struct {
+			method string
+			key    string
+			call   func() error
+			want   Outcome
+		}{
+			{"Get", "string", func() error { return r.Get(ctx, "string").Err() }, Hit},
+			{"Get", "missing", func() error { return r.Get(ctx, "missing").Err() }, Miss},
+			{"Get", "set", func() error { return r.Get(ctx, "set").Err() }, Error},
+			{"SMembers", "set", func() error { return r.SMembers(ctx, "set").Err() }, Hit},
+			{"SMembers", "missing", func() error { return r.SMembers(ctx, "missing").Err() }, Miss},
+			{"SMembers", "string", func() error { return r.SMembers(ctx, "string").Err() }, Error},
+			{"Set", "new", func() error { return r.Set(ctx, "new", "v", 0).Err() }, OK},
+			{"Set", "bad", func() error { return r.Set(ctx, "bad", struct{}{}, 0).Err() }, Error},
+			{"SAdd", "set", func() error { return r.SAdd(ctx, "set", "b").Err() }, OK},
+			{"SAdd", "string", func() error { return r.SAdd(ctx, "string", "b").Err() }, Error},
+			{"Expire", "set", func() error { return r.Expire(ctx, "set", time.Hour).Err() }, OK},
+			{"Expire", "missing", func() error { return r.Expire(ctx, "missing", time.Hour).Err() }, Miss},
+			{"Persist", "string", func() error { return r.Persist(ctx, "string").Err() }, OK},
+			{"Persist", "persistent", func() error { return r.Persist(ctx, "persistent").Err() }, Miss},
+			{"Persist", "missing", func() error { return r.Persist(ctx, "missing").Err() }, Miss},
+		} {
+			r.Reset()
+			err := tc.call()
+			commands := r.Commands()
+			require.Len(t, commands, 1)
+			c := commands[0]
+			require.Equal(t, tc.method, c.Method)
+			require.Equal(t, tc.key, c.Key)
+			require.Equal(t, tc.want, c.Outcome, "%s %s", tc.method, tc.key)
+			require.Equal(t, err, c.Err)
+		}
+	})
+
+	t.Run("faulted expiry is an error", func(t *testing.T) {
+		broken := errors.New("broken")
+		f := fault.Wrap(tnredis.NewMapRedis(), fault.WithRules(fault.Rule{Fault: fault.Fault{Err: broken}}))
+		r := Wrap("test", f)
+		require.ErrorIs(t, r.Expire(ctx, "k", time.Hour).Err(), broken)
+		require.ErrorIs(t, r.Persist(ctx, "k").Err(), broken)
+		require.Equal(t, Stats{Name: "test", Errors: 2}, r.Stats())
+	})
+
+	t.Run("stats and reset", func(t *testing.T) {
+		r := Wrap("claims", tnredis.NewMapRedis())
+		require.Equal(t, "claims", r.Name())
+		require.ErrorIs(t, r.Get(ctx, "k").Err(), goredis.Nil)
+		require.NoError(t, r.Set(ctx, "k", "v", 0).Err())
+		require.NoError(t, r.Get(ctx, "k").Err())
+		require.NoError(t, r.Get(ctx, "k").Err())
+		require.Error(t, r.SAdd(ctx, "k", "a").Err())
+		require.Equal(t, Stats{Name: "claims", Hits: 2, Misses: 1, Writes: 1, Errors: 1}, r.Stats())
+		require.Equal(t, []string{"Get", "Set", "Get", "Get", "SAdd"}, methods(r.Commands()))
+
+		r.Reset()
+		require.Empty(t, r.Commands())
+		require.Equal(t, Stats{Name: "claims"}, r.Stats())
+	})
+}
+
+func methods(commands []Command) []string {
+	var names []string
+	for _, c := range commands {
+		names = append(names, c.Method)
+	}
+	return names
+}
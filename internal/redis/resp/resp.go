@@ -0,0 +1,356 @@
+// Package resp serves clients of the subset of Redis used by the indexing
+// service over the Redis serialization protocol (RESP2), so that an indexing
+// service running in another process can use them as its caches. Each client
+// is a database, selected with SELECT as on a Redis server.
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/storacha/indexing-service/pkg/redis"
+)
+
+// Server serves databases to Redis clients.
+type Server struct {
+	dbs []redis.Client
+
+	mutex    sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+// NewServer creates a server of the databases, numbered from 0 in the order
+// they are passed.
+func NewServer(dbs ...redis.Client) *Server {
+	return &Server{dbs: dbs, conns: map[net.Conn]struct{}{}}
+}
+
+// Serve accepts connections on the listener until the server is closed.
+func (s *Server) Serve(l net.Listener) error {
+	s.mutex.Lock()
+	s.listener = l
+	s.mutex.Unlock()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		s.mutex.Lock()
+		s.conns[conn] = struct{}{}
+		s.mutex.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(conn)
+			s.mutex.Lock()
+			delete(s.conns, conn)
+			s.mutex.Unlock()
+		}()
+	}
+}
+
+// Close stops accepting connections and closes those that are open.
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mutex.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	db := 0
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				writeError(w, "ERR "+err.Error())
+				w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		name := strings.ToUpper(args[0])
+		switch name {
+		case "QUIT":
+			writeSimple(w, "OK")
+			w.Flush()
+			return
+		case "SELECT":
+			n, err := selectDB(args, len(s.dbs))
+			if err != nil {
+				writeError(w, err.Error())
+			} else {
+				db = n
+				writeSimple(w, "OK")
+			}
+		default:
+			s.exec(w, s.dbs[db], name, args[1:])
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// exec runs a command against the database and writes the reply.
+func (s *Server) exec(w *bufio.Writer, db redis.Client, name string, args []string) {
+	ctx := context.Background()
+	switch name {
+	case "PING":
+		writeSimple(w, "PONG")
+	case "CLIENT":
+		// CLIENT SETNAME and SETINFO are sent by clients when they connect
+		writeSimple(w, "OK")
+	case "GET":
+		if !arity(w, name, args, 1) {
+			return
+		}
+		val, err := db.Get(ctx, args[0]).Result()
+		if errors.Is(err, goredis.Nil) {
+			w.WriteString("$-1\r\n")
+			return
+		}
+		writeBulkResult(w, val, err)
+	case "SET":
+		if len(args) < 2 {
+			writeArityError(w, name)
+			return
+		}
+		expiration, err := parseSetOptions(args[2:])
+		if err != nil {
+			writeError(w, err.Error())
+			return
+		}
+		writeStatusResult(w, db.Set(ctx, args[0], args[1], expiration).Err())
+	case "EXPIRE", "PEXPIRE":
+		if !arity(w, name, args, 2) {
+			return
+		}
+		n, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			writeError(w, "ERR value is not an integer or out of range")
+			return
+		}
+		unit := time.Second
+		if name == "PEXPIRE" {
+			unit = time.Millisecond
+		}
+		ok, err := db.Expire(ctx, args[0], time.Duration(n)*unit).Result()
+		writeBoolResult(w, ok, err)
+	case "PERSIST":
+		if !arity(w, name, args, 1) {
+			return
+		}
+		ok, err := db.Persist(ctx, args[0]).Result()
+		writeBoolResult(w, ok, err)
+	case "SADD":
+		if len(args) < 2 {
+			writeArityError(w, name)
+			return
+		}
+		var members []any
+		for _, m := range args[1:] {
+			members = append(members, m)
+		}
+		n, err := db.SAdd(ctx, args[0], members...).Result()
+		if err != nil {
+			writeError(w, errorMessage(err))
+			return
+		}
+		writeInt(w, n)
+	case "SMEMBERS":
+		if !arity(w, name, args, 1) {
+			return
+		}
+		members, err := db.SMembers(ctx, args[0]).Result()
+		if err != nil {
+			writeError(w, errorMessage(err))
+			return
+		}
+		fmt.Fprintf(w, "*%d\r\n", len(members))
+		for _, m := range members {
+			writeBulk(w, m)
+		}
+	default:
+		writeError(w, fmt.Sprintf("ERR unknown command '%s'", strings.ToLower(name)))
+	}
+}
+
+// readCommand reads a command sent as an array of bulk strings.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("protocol error: expected array, got %q", line)
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, fmt.Errorf("protocol error: invalid array length %q", line)
+	}
+	args := make([]string, 0, n)
+	for range n {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("protocol error: expected bulk string, got %q", line)
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("protocol error: invalid bulk length %q", line)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// parseSetOptions returns the expiration set by the options of a SET command.
+// KEEPTTL is passed to the client as an expiration of -1, as go-redis does.
+func parseSetOptions(opts []string) (time.Duration, error) {
+	var expiration time.Duration
+	for i := 0; i < len(opts); i++ {
+		switch opt := strings.ToUpper(opts[i]); opt {
+		case "EX", "PX":
+			if i+1 >= len(opts) {
+				return 0, errors.New("ERR syntax error")
+			}
+			n, err := strconv.ParseInt(opts[i+1], 10, 64)
+			if err != nil || n <= 0 {
+				return 0, errors.New("ERR invalid expire time in 'set' command")
+			}
+			unit := time.Second
+			if opt == "PX" {
+				unit = time.Millisecond
+			}
+			expiration = time.Duration(n) * unit
+			i++
+		case "KEEPTTL":
+			expiration = goredis.KeepTTL
+		default:
+			return 0, errors.New("ERR syntax error")
+		}
+	}
+	return expiration, nil
+}
+
+func selectDB(args []string, count int) (int, error) {
+	if len(args) != 2 {
+		return 0, errors.New("ERR wrong number of arguments for 'select' command")
+	}
+	n, err := strconv.Atoi(args[1])
+	if err != nil {
+		return 0, errors.New("ERR value is not an integer or out of range")
+	}
+	if n < 0 || n >= count {
+		return 0, errors.New("ERR DB index is out of range")
+	}
+	return n, nil
+}
+
+func arity(w *bufio.Writer, name string, args []string, n int) bool {
+	if len(args) != n {
+		writeArityError(w, name)
+		return false
+	}
+	return true
+}
+
+// errorMessage returns the message of an error to reply with. Errors from the
+// database that are not Redis errors, such as injected faults, are reported
+// as generic errors.
+func errorMessage(err error) string {
+	var rerr goredis.Error
+	if errors.As(err, &rerr) {
+		return rerr.Error()
+	}
+	return "ERR " + err.Error()
+}
+
+func writeArityError(w *bufio.Writer, name string) {
+	writeError(w, fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+}
+
+func writeSimple(w *bufio.Writer, s string) {
+	fmt.Fprintf(w, "+%s\r\n", s)
+}
+
+func writeError(w *bufio.Writer, msg string) {
+	fmt.Fprintf(w, "-%s\r\n", strings.ReplaceAll(msg, "\r\n", " "))
+}
+
+func writeInt(w *bufio.Writer, n int64) {
+	fmt.Fprintf(w, ":%d\r\n", n)
+}
+
+func writeBulk(w *bufio.Writer, s string) {
+	fmt.Fprintf(w, "$%d\r\n%s\r\n", len(s), s)
+}
+
+func writeBulkResult(w *bufio.Writer, s string, err error) {
+	if err != nil {
+		writeError(w, errorMessage(err))
+		return
+	}
+	writeBulk(w, s)
+}
+
+func writeStatusResult(w *bufio.Writer, err error) {
+	if err != nil {
+		writeError(w, errorMessage(err))
+		return
+	}
+	writeSimple(w, "OK")
+}
+
+func writeBoolResult(w *bufio.Writer, ok bool, err error) {
+	if err != nil {
+		writeError(w, errorMessage(err))
+		return
+	}
+	if ok {
+		writeInt(w, 1)
+	} else {
+		writeInt(w, 0)
+	}
+}
@@ -0,0 +1,62 @@
+package resp
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/storacha/testthenetwork/internal/clock"
+	"github.com/storacha/testthenetwork/internal/redis"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewFakeClock(time.Now())
+	db0 := redis.NewMapRedis(redis.WithClock(clk))
+	db1 := redis.NewMapRedis(redis.WithClock(clk))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := NewServer(db0, db1)
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+
+	client := goredis.NewClient(&goredis.Options{Addr: l.Addr().String(), DB: 1})
+	t.Cleanup(func() { client.Close() })
+
+	require.NoError(t, client.Ping(ctx).Err())
+
+	// commands go to the selected database
+	require.NoError(t, client.Set(ctx, "key", "value", time.Minute).Err())
+	require.Equal(t, "value", client.Get(ctx, "key").Val())
+	ttl, ok := db1.TTL("key")
+	require.True(t, ok)
+	require.Equal(t, time.Minute, ttl)
+	require.Empty(t, db0.Keys())
+
+	require.True(t, client.Persist(ctx, "key").Val())
+	require.True(t, client.PExpire(ctx, "key", 1500*time.Millisecond).Val())
+	ttl, _ = db1.TTL("key")
+	require.Equal(t, 1500*time.Millisecond, ttl)
+
+	clk.Advance(2 * time.Second)
+	require.ErrorIs(t, client.Get(ctx, "key").Err(), goredis.Nil)
+	require.False(t, client.Expire(ctx, "key", time.Minute).Val())
+
+	require.Equal(t, int64(2), client.SAdd(ctx, "set", "a", "b").Val())
+	require.ElementsMatch(t, []string{"a", "b"}, client.SMembers(ctx, "set").Val())
+	// like Redis, a missing set is empty rather than nil
+	missing, err := client.SMembers(ctx, "missing").Result()
+	require.NoError(t, err)
+	require.Empty(t, missing)
+
+	// errors from the database are returned to the client
+	err = client.Get(ctx, "set").Err()
+	require.EqualError(t, err, redis.ErrWrongType.Error())
+
+	err = client.Do(ctx, "flushall").Err()
+	require.ErrorContains(t, err, "unknown command 'flushall'")
+}
@@ -0,0 +1,102 @@
+package span
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/storacha/indexing-service/pkg/redis"
+	"github.com/storacha/testthenetwork/internal/digestutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/storacha/testthenetwork/internal/redis/span"
+
+// TracingRedis is a client that creates an OpenTelemetry span for each command
+// it forwards to a wrapped client, as a child of the span in the context passed
+// to the command.
+type TracingRedis struct {
+	name   string
+	child  redis.Client
+	tracer trace.Tracer
+}
+
+var _ redis.Client = (*TracingRedis)(nil)
+
+// Wrap creates a client that traces commands sent to the passed client. The
+// name identifies the client in span attributes, e.g. "providers", "claims" or
+// "indexes". Spans are created with the global tracer provider.
+func Wrap(name string, c redis.Client) *TracingRedis {
+	return &TracingRedis{name: name, child: c, tracer: otel.Tracer(tracerName)}
+}
+
+func (r *TracingRedis) Expire(ctx context.Context, key string, expiration time.Duration) *goredis.BoolCmd {
+	ctx, span := r.start(ctx, "EXPIRE", key, attribute.String("redis.expiration", expiration.String()))
+	cmd := r.child.Expire(ctx, key, expiration)
+	end(span, cmd.Err(), false)
+	return cmd
+}
+
+func (r *TracingRedis) Get(ctx context.Context, key string) *goredis.StringCmd {
+	ctx, span := r.start(ctx, "GET", key)
+	cmd := r.child.Get(ctx, key)
+	end(span, cmd.Err(), true)
+	return cmd
+}
+
+func (r *TracingRedis) Persist(ctx context.Context, key string) *goredis.BoolCmd {
+	ctx, span := r.start(ctx, "PERSIST", key)
+	cmd := r.child.Persist(ctx, key)
+	end(span, cmd.Err(), false)
+	return cmd
+}
+
+func (r *TracingRedis) SAdd(ctx context.Context, key string, values ...interface{}) *goredis.IntCmd {
+	ctx, span := r.start(ctx, "SADD", key, attribute.Int("redis.members", len(values)))
+	cmd := r.child.SAdd(ctx, key, values...)
+	end(span, cmd.Err(), false)
+	return cmd
+}
+
+func (r *TracingRedis) SMembers(ctx context.Context, key string) *goredis.StringSliceCmd {
+	ctx, span := r.start(ctx, "SMEMBERS", key)
+	cmd := r.child.SMembers(ctx, key)
+	if cmd.Err() == nil {
+		span.SetAttributes(attribute.Int("redis.members", len(cmd.Val())), attribute.Bool("cache.hit", len(cmd.Val()) > 0))
+	}
+	end(span, cmd.Err(), false)
+	return cmd
+}
+
+func (r *TracingRedis) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *goredis.StatusCmd {
+	ctx, span := r.start(ctx, "SET", key, attribute.String("redis.expiration", expiration.String()))
+	cmd := r.child.Set(ctx, key, value, expiration)
+	end(span, cmd.Err(), false)
+	return cmd
+}
+
+func (r *TracingRedis) start(ctx context.Context, op string, key string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return r.tracer.Start(ctx, r.name+" "+op, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(append([]attribute.KeyValue{
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", op),
+		attribute.String("cache.name", r.name),
+		attribute.String("cache.key", digestutil.FormatBytes([]byte(key))),
+	}, attrs...)...))
+}
+
+// end ends the span, recording the error unless it is goredis.Nil, which means
+// the key was not found. If hit is set, whether the key was found is recorded.
+func end(span trace.Span, err error, hit bool) {
+	if hit {
+		span.SetAttributes(attribute.Bool("cache.hit", err == nil))
+	}
+	if err != nil && !errors.Is(err, goredis.Nil) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -2,11 +2,13 @@ package sync
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 
 	goredis "github.com/redis/go-redis/v9"
 	"github.com/storacha/indexing-service/pkg/redis"
+	tnredis "github.com/storacha/testthenetwork/internal/redis"
 )
 
 type MutexRedis struct {
@@ -21,8 +23,9 @@ func (m *MutexRedis) Expire(ctx context.Context, key string, expiration time.Dur
 }
 
 func (m *MutexRedis) Get(ctx context.Context, key string) *goredis.StringCmd {
-	m.RLock()
-	defer m.RUnlock()
+	// reads may evict expired keys so require an exclusive lock
+	m.Lock()
+	defer m.Unlock()
 	return m.child.Get(ctx, key)
 }
 
@@ -45,12 +48,40 @@ func (m *MutexRedis) SAdd(ctx context.Context, key string, values ...interface{}
 }
 
 func (m *MutexRedis) SMembers(ctx context.Context, key string) *goredis.StringSliceCmd {
+	// reads may evict expired keys so require an exclusive lock
+	m.Lock()
+	defer m.Unlock()
+	return m.child.SMembers(ctx, key)
+}
+
+func (m *MutexRedis) Keys() []string {
 	m.RLock()
 	defer m.RUnlock()
-	return m.child.SMembers(ctx, key)
+	return m.inspector().Keys()
+}
+
+func (m *MutexRedis) TTL(key string) (time.Duration, bool) {
+	m.RLock()
+	defer m.RUnlock()
+	return m.inspector().TTL(key)
+}
+
+func (m *MutexRedis) Values(key string) ([]string, bool) {
+	m.RLock()
+	defer m.RUnlock()
+	return m.inspector().Values(key)
+}
+
+func (m *MutexRedis) inspector() tnredis.Inspector {
+	i, ok := m.child.(tnredis.Inspector)
+	if !ok {
+		panic(fmt.Errorf("wrapped client %T cannot be inspected", m.child))
+	}
+	return i
 }
 
 var _ redis.Client = (*MutexRedis)(nil)
+var _ tnredis.Inspector = (*MutexRedis)(nil)
 
 func MutexWrap(c redis.Client) *MutexRedis {
 	return &MutexRedis{child: c}
@@ -0,0 +1,106 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/storacha/testthenetwork/internal/steps"
+)
+
+type htmlReport struct {
+	Name      string
+	Generated time.Time
+	Passed    int
+	Failed    int
+	Skipped   int
+	Tests     []htmlTest
+}
+
+type htmlTest struct {
+	Name        string
+	Status      string
+	Duration    time.Duration
+	Steps       []htmlStep
+	Attachments []steps.Attachment
+}
+
+type htmlStep struct {
+	steps.Step
+	Index  int
+	Offset time.Duration
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"ms": func(d time.Duration) string { return d.Round(time.Millisecond).String() },
+	"indent": func(depth int) template.CSS {
+		return template.CSS(fmt.Sprintf("padding-left: %dem", depth+1))
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Name}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.6em; text-align: left; vertical-align: top; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+pre { background: #f6f6f6; padding: 0.6em; overflow-x: auto; }
+.ok, .passed { color: #1a7f37; }
+.failed { color: #cf222e; font-weight: bold; }
+.event, .skipped { color: #6e7781; }
+.attrs { font-family: monospace; font-size: 0.85em; color: #555; }
+</style>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+<p>Generated {{.Generated.Format "2006-01-02 15:04:05 MST"}}: <span class="passed">{{.Passed}} passed</span>, <span class="failed">{{.Failed}} failed</span>, <span class="skipped">{{.Skipped}} skipped</span>.</p>
+{{range .Tests}}{{$test := .}}
+<h2 class="{{.Status}}">{{.Name}} ({{.Status}}, {{ms .Duration}})</h2>
+<table>
+<tr><th>#</th><th>Status</th><th>Offset</th><th>Duration</th><th>Step</th></tr>
+{{range $s := .Steps}}<tr>
+<td class="num">{{$s.Index}}</td>
+<td class="{{$s.Status}}">{{$s.Status}}</td>
+<td class="num">{{ms $s.Offset}}</td>
+<td class="num">{{if ne $s.Status "event"}}{{ms $s.Duration}}{{end}}</td>
+<td style="{{indent $s.Depth}}">{{$s.Name}}{{if $s.Error}}: <span class="failed">{{$s.Error}}</span>{{end}}
+{{if $s.Attrs}}<div class="attrs">{{range $s.Attrs}}{{.Key}}={{.Value}}<br>{{end}}</div>{{end}}</td>
+</tr>
+{{end}}</table>
+{{range .Attachments}}<details{{if eq $test.Status "failed"}} open{{end}}>
+<summary>{{.Name}}</summary>
+<pre>{{.Content}}</pre>
+</details>
+{{end}}{{end}}
+</body>
+</html>
+`))
+
+// WriteHTML writes an HTML report with the timeline of each test, including
+// step timings and attachments such as query results and service logs.
+func WriteHTML(w io.Writer, name string, recorders []*steps.Recorder) error {
+	report := htmlReport{Name: name, Generated: time.Now()}
+	for _, r := range recorders {
+		all := r.Steps()
+		test := htmlTest{Name: r.Name(), Duration: elapsed(all), Attachments: r.Attachments()}
+		switch {
+		case r.Failed():
+			test.Status = "failed"
+			report.Failed++
+		case r.Skipped():
+			test.Status = "skipped"
+			report.Skipped++
+		default:
+			test.Status = "passed"
+			report.Passed++
+		}
+		for i, s := range all {
+			test.Steps = append(test.Steps, htmlStep{s, i + 1, s.Start.Sub(all[0].Start)})
+		}
+		report.Tests = append(report.Tests, test)
+	}
+	return htmlTemplate.Execute(w, report)
+}
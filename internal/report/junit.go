@@ -0,0 +1,160 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/storacha/testthenetwork/internal/steps"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+	SystemOut *junitText      `xml:"system-out,omitempty"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",cdata"`
+}
+
+type junitText struct {
+	Text string `xml:",cdata"`
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// WriteJUnit writes a JUnit XML report with a suite for each test and a case
+// for each step in the test. If the test failed outside of a step, it has an
+// extra failing case so the failure is not lost.
+func WriteJUnit(w io.Writer, name string, recorders []*steps.Recorder) error {
+	report := junitTestSuites{Name: name}
+	var total time.Duration
+	for _, r := range recorders {
+		suite := junitSuite(r)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Skipped += suite.Skipped
+		total += elapsed(r.Steps())
+		report.Suites = append(report.Suites, suite)
+	}
+	report.Time = seconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func junitSuite(r *steps.Recorder) junitTestSuite {
+	all := r.Steps()
+	suite := junitTestSuite{Name: r.Name(), Time: seconds(elapsed(all))}
+	if len(all) > 0 {
+		suite.Timestamp = all[0].Start.UTC().Format("2006-01-02T15:04:05")
+	}
+	stepFailed := false
+	for i, s := range all {
+		if s.Status == steps.Event {
+			continue
+		}
+		tc := junitTestCase{
+			Name:      fmt.Sprintf("%03d %s", i+1, s.Name),
+			ClassName: r.Name(),
+			Time:      seconds(s.Duration),
+		}
+		if s.Status == steps.Failed {
+			stepFailed = true
+			msg := s.Error
+			if msg == "" {
+				msg = "test failed during step"
+			}
+			tc.Failure = &junitFailure{Message: msg, Body: formatAttrs(s.Attrs)}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	if r.Failed() && !stepFailed {
+		suite.Cases = append(suite.Cases, junitTestCase{
+			Name:      "assertions",
+			ClassName: r.Name(),
+			Time:      seconds(0),
+			Failure:   &junitFailure{Message: "test failed after its last step"},
+		})
+		suite.Failures++
+	}
+	if r.Skipped() {
+		suite.Cases = append(suite.Cases, junitTestCase{
+			Name:      "skipped",
+			ClassName: r.Name(),
+			Time:      seconds(0),
+			Skipped:   &struct{}{},
+		})
+		suite.Skipped++
+	}
+	suite.Tests = len(suite.Cases)
+	if attachments := r.Attachments(); len(attachments) > 0 {
+		var b strings.Builder
+		for _, a := range attachments {
+			fmt.Fprintf(&b, "== %s ==\n%s\n", a.Name, a.Content)
+		}
+		suite.SystemOut = &junitText{b.String()}
+	}
+	return suite
+}
+
+// elapsed is the time from the start of the first step to the end of the
+// last one to finish.
+func elapsed(all []steps.Step) time.Duration {
+	if len(all) == 0 {
+		return 0
+	}
+	var end time.Time
+	for _, s := range all {
+		if s.End.After(end) {
+			end = s.End
+		}
+	}
+	return end.Sub(all[0].Start)
+}
+
+func formatAttrs(attrs []steps.Attr) string {
+	var b strings.Builder
+	for _, a := range attrs {
+		fmt.Fprintf(&b, "%s=%s\n", a.Key, a.Value)
+	}
+	return b.String()
+}
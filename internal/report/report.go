@@ -0,0 +1,100 @@
+// Package report writes run reports from the timelines recorded by the steps
+// package: a JUnit XML report for CI and an HTML report with step timings,
+// query results and service logs.
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/storacha/testthenetwork/internal/steps"
+	"github.com/storacha/testthenetwork/internal/testutil"
+)
+
+// DirEnv is the environment variable that configures the directory reports are
+// written to. It defaults to the artifacts directory.
+const DirEnv = "TTN_REPORT_DIR"
+
+const (
+	JUnitFile = "junit.xml"
+	HTMLFile  = "report.html"
+)
+
+// Dir returns the directory reports are written to.
+func Dir() string {
+	if dir := os.Getenv(DirEnv); dir != "" {
+		return dir
+	}
+	return testutil.ArtifactsRoot()
+}
+
+// Write writes the JUnit XML and HTML reports for the recorded tests to the
+// report directory.
+func Write(name string, recorders []*steps.Recorder) error {
+	dir := Dir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, name, recorders); err != nil {
+		return fmt.Errorf("writing JUnit report: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, JUnitFile), buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	buf.Reset()
+	if err := WriteHTML(&buf, name, recorders); err != nil {
+		return fmt.Errorf("writing HTML report: %w", err)
+	}
+	return os.WriteFile(filepath.Join(dir, HTMLFile), buf.Bytes(), 0644)
+}
+
+// CaptureLogs captures the logs of the services for the duration of the test
+// and attaches them to its timeline. Only entries at or above the level of
+// each logging subsystem are captured. The returned function returns the logs
+// captured so far.
+func CaptureLogs(t testing.TB) func() string {
+	rec := steps.For(t)
+	pr := logging.NewPipeReader(logging.PipeFormat(logging.PlaintextOutput))
+	var (
+		buf lockedBuffer
+		wg  sync.WaitGroup
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		io.Copy(&buf, pr)
+	}()
+	t.Cleanup(func() {
+		pr.Close()
+		wg.Wait()
+		if logs := buf.String(); logs != "" {
+			rec.Attach("service logs", logs)
+		}
+	})
+	return buf.String
+}
+
+// lockedBuffer is a buffer that can be read while it is being written to.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,59 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/storacha/testthenetwork/internal/clock"
+	"github.com/storacha/testthenetwork/internal/steps"
+	"github.com/stretchr/testify/require"
+)
+
+func newRecorder(t *testing.T) *steps.Recorder {
+	clk := clock.NewFakeClock(time.Now())
+	r := steps.NewRecorder(t, steps.WithClock(clk), steps.WithOutput(io.Discard))
+	s := r.Start("blob/add", steps.A("digest", "zQm"))
+	clk.Advance(1500 * time.Millisecond)
+	s.Done()
+	r.Event("waiting for IPNI sync")
+	r.Start("query claims").Fail(errors.New("<boom>"))
+	r.Attach("query results", "# Query Results")
+	r.Close()
+	return r
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteJUnit(&buf, "run", []*steps.Recorder{newRecorder(t)}))
+
+	var doc junitTestSuites
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+	require.Equal(t, 2, doc.Tests)
+	require.Equal(t, 1, doc.Failures)
+	require.Len(t, doc.Suites, 1)
+
+	suite := doc.Suites[0]
+	require.Equal(t, t.Name(), suite.Name)
+	require.Len(t, suite.Cases, 2) // events are not cases
+	require.Equal(t, "001 blob/add", suite.Cases[0].Name)
+	require.Equal(t, "1.500", suite.Cases[0].Time)
+	require.Nil(t, suite.Cases[0].Failure)
+	require.Equal(t, "003 query claims", suite.Cases[1].Name)
+	require.Equal(t, "<boom>", suite.Cases[1].Failure.Message)
+	require.Contains(t, suite.SystemOut.Text, "== query results ==\n# Query Results")
+}
+
+func TestWriteHTML(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteHTML(&buf, "run", []*steps.Recorder{newRecorder(t)}))
+	out := buf.String()
+	require.Contains(t, out, "<h2 class=\"passed\">"+t.Name())
+	require.Contains(t, out, "blob/add")
+	require.Contains(t, out, "1.5s")
+	require.Contains(t, out, "&lt;boom&gt;")
+	require.Contains(t, out, "<summary>query results</summary>")
+}
@@ -0,0 +1,396 @@
+// Package scenario parses scenarios written in YAML, which describe the steps
+// a test takes against the network, such as uploading content to a space and
+// publishing its index, and the claims and indexes queries are expected to
+// return, so that scenarios can be written without writing Go.
+//
+// Content, blobs, spaces, issuers and query results are referred to by names
+// the scenario chooses. Spaces and issuers are created the first time they are
+// named. Content is named by the generate step that creates it, which also
+// names its CAR as a blob, and indexes by the index step that creates them.
+// Where a digest is expected, a blob name refers to the digest of the blob and
+// a content name followed by ".root" to the digest of the root of the content.
+package scenario
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/multiformats/go-multihash"
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is a named list of steps run against a network of its own.
+type Scenario struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	// NoCache disables the caches of the indexing service.
+	NoCache bool   `yaml:"no_cache"`
+	Steps   []Step `yaml:"steps"`
+}
+
+// Step is a step of a scenario. Exactly one of its fields is set.
+type Step struct {
+	Generate     *Generate     `yaml:"generate"`
+	Index        *Index        `yaml:"index"`
+	BlobAdd      *Blob         `yaml:"blob/add"`
+	Put          *Blob         `yaml:"put"`
+	Conclude     *Blob         `yaml:"conclude"`
+	Upload       *Blob         `yaml:"upload"`
+	PublishIndex *PublishIndex `yaml:"publish_index"`
+	Query        *Query        `yaml:"query"`
+	Expect       *Expect       `yaml:"expect"`
+}
+
+// Kind returns the key the step is written with, such as "blob/add".
+func (s Step) Kind() string {
+	switch {
+	case s.Generate != nil:
+		return "generate"
+	case s.Index != nil:
+		return "index"
+	case s.BlobAdd != nil:
+		return "blob/add"
+	case s.Put != nil:
+		return "put"
+	case s.Conclude != nil:
+		return "conclude"
+	case s.Upload != nil:
+		return "upload"
+	case s.PublishIndex != nil:
+		return "publish_index"
+	case s.Query != nil:
+		return "query"
+	case s.Expect != nil:
+		return "expect"
+	}
+	return ""
+}
+
+func (s Step) count() int {
+	n := 0
+	for _, set := range []bool{
+		s.Generate != nil, s.Index != nil, s.BlobAdd != nil, s.Put != nil, s.Conclude != nil,
+		s.Upload != nil, s.PublishIndex != nil, s.Query != nil, s.Expect != nil,
+	} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+// Generate generates a CAR of random content.
+type Generate struct {
+	// Name names the content and its CAR.
+	Name string `yaml:"name"`
+	// Size is the size of the content in bytes. It defaults to 256.
+	Size int `yaml:"size"`
+	// Hash is the name of the multihash function the content and the CAR are
+	// hashed with, such as sha2-512. It defaults to sha2-256.
+	Hash string `yaml:"hash"`
+}
+
+// Index generates a sharded DAG index of content.
+type Index struct {
+	// Name names the index and the blob it is archived to.
+	Name    string `yaml:"name"`
+	Content string `yaml:"content"`
+	// Shards are the blobs the index spans. They default to the CAR of the
+	// content.
+	Shards []string `yaml:"shards"`
+}
+
+// Blob is a blob in a space. It is the argument of the blob/add, put and
+// conclude steps, which allocate the blob in the space, put it to the address
+// allocated for it and conclude the put, and of the upload step, which does all
+// three but skips the put if the storage node already has the blob.
+type Blob struct {
+	Blob  string `yaml:"blob"`
+	Space string `yaml:"space"`
+}
+
+// PublishIndex publishes an index claim for content.
+type PublishIndex struct {
+	Content string `yaml:"content"`
+	Index   string `yaml:"index"`
+	Issuer  string `yaml:"issuer"`
+}
+
+// Query queries the indexing service for claims.
+type Query struct {
+	// Name names the results. It defaults to "query<n>" for the nth query.
+	Name    string   `yaml:"name"`
+	Digests []string `yaml:"digests"`
+	// Spaces filters the results by space.
+	Spaces []string `yaml:"spaces"`
+	// Await queries until the results are not empty, giving IPNI time to sync.
+	Await bool `yaml:"await"`
+}
+
+// Expect asserts what the results of a query contain.
+type Expect struct {
+	// Query is the name of the results. It defaults to the last query.
+	Query string `yaml:"query"`
+	// Indexes are the indexes the results must have, in any order. Unless it is
+	// set, the indexes are not checked, so set it to [] to expect none.
+	Indexes []string `yaml:"indexes"`
+	// Claims is the number of claims the results must have, if set.
+	Claims *int `yaml:"claims"`
+	// Contains are claims the results must each have one of.
+	Contains []Claim `yaml:"contains"`
+	// NotContains are claims the results must have none of.
+	NotContains []Claim `yaml:"not_contains"`
+}
+
+// Claim describes the claims an expectation matches. Fields that are not set
+// match any claim.
+type Claim struct {
+	// Ability is the ability of the claim, such as assert/location.
+	Ability string `yaml:"ability"`
+	Issuer  string `yaml:"issuer"`
+	// Content is the digest of the content of the claim.
+	Content string `yaml:"content"`
+	// Space is the space of a location commitment.
+	Space string `yaml:"space"`
+	// OutsideSpaces matches location commitments for a space not in the list.
+	OutsideSpaces []string `yaml:"outside_spaces"`
+	Index         string   `yaml:"index"`
+}
+
+// Load reads and parses the scenario in the file.
+func Load(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Parse parses a scenario and checks that its steps are well formed and only
+// refer to content, blobs and results that earlier steps create.
+func Parse(data []byte) (*Scenario, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var s Scenario
+	if err := dec.Decode(&s); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("empty scenario")
+		}
+		return nil, err
+	}
+	if s.Name == "" {
+		return nil, errors.New("scenario has no name")
+	}
+	if len(s.Steps) == 0 {
+		return nil, errors.New("scenario has no steps")
+	}
+	if err := newChecker().check(&s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// checker checks the steps of a scenario, setting defaults as it goes.
+type checker struct {
+	contents map[string]bool
+	blobs    map[string]bool
+	indexes  map[string]bool
+	queries  map[string]bool
+	last     string
+}
+
+func newChecker() *checker {
+	return &checker{
+		contents: map[string]bool{},
+		blobs:    map[string]bool{},
+		indexes:  map[string]bool{},
+		queries:  map[string]bool{},
+	}
+}
+
+func (c *checker) check(s *Scenario) error {
+	for i := range s.Steps {
+		step := &s.Steps[i]
+		if step.count() != 1 {
+			return fmt.Errorf("step %d: must have exactly one of generate, index, blob/add, put, conclude, upload, publish_index, query or expect", i+1)
+		}
+		if err := c.step(step, len(c.queries)+1); err != nil {
+			return fmt.Errorf("step %d (%s): %w", i+1, step.Kind(), err)
+		}
+	}
+	return nil
+}
+
+func (c *checker) step(s *Step, queryNum int) error {
+	switch {
+	case s.Generate != nil:
+		g := s.Generate
+		if err := c.define(g.Name); err != nil {
+			return err
+		}
+		if g.Size == 0 {
+			g.Size = 256
+		}
+		if g.Size < 0 {
+			return fmt.Errorf("negative size %d", g.Size)
+		}
+		if g.Hash == "" {
+			g.Hash = "sha2-256"
+		}
+		if _, err := HashCode(g.Hash); err != nil {
+			return err
+		}
+		c.contents[g.Name] = true
+		c.blobs[g.Name] = true
+	case s.Index != nil:
+		x := s.Index
+		if err := c.define(x.Name); err != nil {
+			return err
+		}
+		if !c.contents[x.Content] {
+			return fmt.Errorf("unknown content %q", x.Content)
+		}
+		if len(x.Shards) == 0 {
+			x.Shards = []string{x.Content}
+		}
+		for _, shard := range x.Shards {
+			if !c.blobs[shard] || c.indexes[shard] {
+				return fmt.Errorf("unknown shard %q", shard)
+			}
+		}
+		c.indexes[x.Name] = true
+		c.blobs[x.Name] = true
+	case s.BlobAdd != nil:
+		return c.blob(s.BlobAdd)
+	case s.Put != nil:
+		return c.blob(s.Put)
+	case s.Conclude != nil:
+		return c.blob(s.Conclude)
+	case s.Upload != nil:
+		return c.blob(s.Upload)
+	case s.PublishIndex != nil:
+		p := s.PublishIndex
+		if !c.contents[p.Content] {
+			return fmt.Errorf("unknown content %q", p.Content)
+		}
+		if !c.indexes[p.Index] {
+			return fmt.Errorf("unknown index %q", p.Index)
+		}
+		if p.Issuer == "" {
+			return errors.New("no issuer")
+		}
+	case s.Query != nil:
+		q := s.Query
+		if len(q.Digests) == 0 {
+			return errors.New("no digests")
+		}
+		for _, d := range q.Digests {
+			if err := c.digest(d); err != nil {
+				return err
+			}
+		}
+		if q.Name == "" {
+			q.Name = fmt.Sprintf("query%d", queryNum)
+		}
+		if c.queries[q.Name] {
+			return fmt.Errorf("query %q already defined", q.Name)
+		}
+		c.queries[q.Name] = true
+		c.last = q.Name
+	case s.Expect != nil:
+		e := s.Expect
+		if e.Query == "" {
+			e.Query = c.last
+		}
+		if !c.queries[e.Query] {
+			return fmt.Errorf("unknown query %q", e.Query)
+		}
+		for _, index := range e.Indexes {
+			if !c.indexes[index] {
+				return fmt.Errorf("unknown index %q", index)
+			}
+		}
+		for _, claim := range append(append([]Claim{}, e.Contains...), e.NotContains...) {
+			if err := c.claim(claim); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *checker) define(name string) error {
+	if name == "" {
+		return errors.New("no name")
+	}
+	if strings.Contains(name, ".") {
+		return fmt.Errorf("name %q contains a dot", name)
+	}
+	if c.blobs[name] {
+		return fmt.Errorf("%q already defined", name)
+	}
+	return nil
+}
+
+func (c *checker) blob(b *Blob) error {
+	if !c.blobs[b.Blob] {
+		return fmt.Errorf("unknown blob %q", b.Blob)
+	}
+	if b.Space == "" {
+		return errors.New("no space")
+	}
+	return nil
+}
+
+func (c *checker) digest(ref string) error {
+	name, part := SplitRef(ref)
+	switch part {
+	case "":
+		if !c.blobs[name] {
+			return fmt.Errorf("unknown blob %q", name)
+		}
+	case "root":
+		if !c.contents[name] {
+			return fmt.Errorf("unknown content %q", name)
+		}
+	default:
+		return fmt.Errorf("unknown digest %q, expected a blob or <content>.root", ref)
+	}
+	return nil
+}
+
+func (c *checker) claim(claim Claim) error {
+	if claim.Content != "" {
+		if err := c.digest(claim.Content); err != nil {
+			return err
+		}
+	}
+	if claim.Index != "" && !c.indexes[claim.Index] {
+		return fmt.Errorf("unknown index %q", claim.Index)
+	}
+	return nil
+}
+
+// SplitRef splits a reference to a digest into the name it refers to and the
+// part of it, which is empty for a blob and "root" for the root of content.
+func SplitRef(ref string) (name, part string) {
+	name, part, _ = strings.Cut(ref, ".")
+	return name, part
+}
+
+// HashCode returns the code of the named multihash function.
+func HashCode(name string) (uint64, error) {
+	code, ok := multihash.Names[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown hash %q", name)
+	}
+	return code, nil
+}
@@ -0,0 +1,71 @@
+package scenario
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		s, err := Parse([]byte(`
+name: defaults
+steps:
+  - generate: {name: content}
+  - index: {name: index, content: content}
+  - query: {digests: [content.root]}
+  - query: {digests: [index]}
+  - expect: {indexes: []}
+`))
+		require.NoError(t, err)
+		require.Equal(t, 256, s.Steps[0].Generate.Size)
+		require.Equal(t, "sha2-256", s.Steps[0].Generate.Hash)
+		require.Equal(t, []string{"content"}, s.Steps[1].Index.Shards)
+		require.Equal(t, "query1", s.Steps[2].Query.Name)
+		require.Equal(t, "query2", s.Steps[3].Query.Name)
+		require.Equal(t, "query2", s.Steps[4].Expect.Query)
+		require.NotNil(t, s.Steps[4].Expect.Indexes)
+		require.Nil(t, s.Steps[4].Expect.Claims)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, tc := range []struct {
+			name, yaml, err string
+		}{
+			{"empty", ``, "empty scenario"},
+			{"no name", `steps: [{generate: {name: c}}]`, "scenario has no name"},
+			{"no steps", `name: x`, "scenario has no steps"},
+			{"unknown step", `{name: x, steps: [{download: {}}]}`, "field download not found"},
+			{"unknown field", `{name: x, steps: [{generate: {name: c, bytes: 1}}]}`, "field bytes not found"},
+			{"two kinds", `{name: x, steps: [{generate: {name: c}, query: {digests: [c]}}]}`, "step 1: must have exactly one"},
+			{"unknown hash", `{name: x, steps: [{generate: {name: c, hash: md5000}}]}`, `step 1 (generate): unknown hash "md5000"`},
+			{"redefined", `{name: x, steps: [{generate: {name: c}}, {generate: {name: c}}]}`, `step 2 (generate): "c" already defined`},
+			{"dotted name", `{name: x, steps: [{generate: {name: c.d}}]}`, `name "c.d" contains a dot`},
+			{"unknown blob", `{name: x, steps: [{upload: {blob: c, space: s}}]}`, `step 1 (upload): unknown blob "c"`},
+			{"no space", `{name: x, steps: [{generate: {name: c}}, {blob/add: {blob: c}}]}`, "step 2 (blob/add): no space"},
+			{"index as shard", `{name: x, steps: [{generate: {name: c}}, {index: {name: i, content: c}}, {index: {name: j, content: c, shards: [i]}}]}`, `unknown shard "i"`},
+			{"blob as index", `{name: x, steps: [{generate: {name: c}}, {publish_index: {content: c, index: c, issuer: a}}]}`, `unknown index "c"`},
+			{"no issuer", `{name: x, steps: [{generate: {name: c}}, {index: {name: i, content: c}}, {publish_index: {content: c, index: i}}]}`, "no issuer"},
+			{"root of blob", `{name: x, steps: [{generate: {name: c}}, {index: {name: i, content: c}}, {query: {digests: [i.root]}}]}`, `unknown content "i"`},
+			{"unknown part", `{name: x, steps: [{generate: {name: c}}, {query: {digests: [c.slice]}}]}`, `unknown digest "c.slice"`},
+			{"expect before query", `{name: x, steps: [{expect: {claims: 1}}]}`, `unknown query ""`},
+			{"unknown claim content", `{name: x, steps: [{generate: {name: c}}, {query: {digests: [c]}}, {expect: {contains: [{content: d}]}}]}`, `unknown blob "d"`},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				_, err := Parse([]byte(tc.yaml))
+				require.ErrorContains(t, err, tc.err)
+			})
+		}
+	})
+
+	t.Run("scenarios in testdata", func(t *testing.T) {
+		paths, err := filepath.Glob(filepath.Join("..", "..", "testdata", "scenarios", "*.yaml"))
+		require.NoError(t, err)
+		require.NotEmpty(t, paths)
+		for _, path := range paths {
+			_, err := Load(path)
+			require.NoError(t, err)
+		}
+	})
+}
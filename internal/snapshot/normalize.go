@@ -0,0 +1,254 @@
+package snapshot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/testthenetwork/internal/digestutil"
+)
+
+// tokens matches the values in strings that differ between runs: DIDs, CIDs,
+// multibase encoded multihashes and the host and port of local services.
+var tokens = regexp.MustCompile(`did:key:z[1-9A-HJ-NP-Za-km-z]+|\bb[a-z2-7]{40,}|\bz[1-9A-HJ-NP-Za-km-z]{30,}|\b(?:127\.0\.0\.1|localhost):\d+`)
+
+// timeKeys are the keys of fields that hold Unix timestamps.
+var timeKeys = map[string]bool{"expiration": true, "notBefore": true, "expires": true, "exp": true, "nbf": true}
+
+// sortedKeys are the keys of lists whose order is not significant, such as the
+// claims in query results.
+var sortedKeys = map[string]bool{"claims": true, "indexes": true}
+
+// Normalizer replaces the values in a JSON document that differ between runs
+// with stable placeholders. Values given a name are replaced with the name and
+// the rest are numbered in the order they are first seen, once lists whose
+// order is not significant have been sorted.
+type Normalizer struct {
+	names  map[string]string
+	now    time.Time
+	counts map[string]int
+}
+
+// Option configures a Normalizer.
+type Option func(*Normalizer)
+
+// WithName names a value so that it is replaced with a placeholder that says
+// what it is, rather than a numbered one. The value may be a DID, a multihash,
+// a link (which names its multihash), a URL (which names its host) or a string
+// as it appears in the document. Placeholders are formatted by kind:
+//
+//	DID:       <did:name>
+//	multihash: <name>
+//	CID:       <codec:name>
+//	host:      <host:name>
+func WithName(value any, name string) Option {
+	return func(n *Normalizer) {
+		switch v := value.(type) {
+		case did.DID:
+			n.names[v.String()] = name
+		case multihash.Multihash:
+			n.names[digestutil.Format(v)] = name
+		case ipld.Link:
+			n.names[digestutil.Format(digestutil.ExtractDigest(v))] = name
+		case url.URL:
+			n.names[v.Host] = name
+		case *url.URL:
+			n.names[v.Host] = name
+		case string:
+			n.names[v] = name
+		default:
+			panic(fmt.Sprintf("cannot name value of type %T", value))
+		}
+	}
+}
+
+// WithNow sets the time timestamps are made relative to, such as
+// "<now+30s>". Without it all timestamps are replaced with "<time>".
+func WithNow(now time.Time) Option {
+	return func(n *Normalizer) {
+		n.now = now
+	}
+}
+
+// New creates a normalizer.
+func New(opts ...Option) *Normalizer {
+	n := &Normalizer{names: map[string]string{}, counts: map[string]int{}}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n
+}
+
+// JSON normalizes a JSON document and returns it indented. Numbering of
+// unnamed values carries on from previous calls.
+func (n *Normalizer) JSON(doc []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("decoding JSON: %w", err)
+	}
+	v = n.value("", v)
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, fmt.Errorf("encoding JSON: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// value normalizes a decoded JSON value found under the passed key.
+func (n *Normalizer) value(key string, v any) any {
+	switch v := v.(type) {
+	case string:
+		return n.string(v, true)
+	case json.Number:
+		if timeKeys[key] {
+			return n.time(v)
+		}
+		return v
+	case []any:
+		if sortedKeys[key] {
+			n.sort(v)
+		}
+		list := make([]any, len(v))
+		for i, e := range v {
+			list[i] = n.value("", e)
+		}
+		return list
+	case map[string]any:
+		// number values in a stable order, as JSON objects are unordered
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		m := make(map[string]any, len(v))
+		for _, k := range keys {
+			m[k] = n.value(k, v[k])
+		}
+		return m
+	}
+	return v
+}
+
+// sort orders the elements of a list by their normalized form, with unnamed
+// values replaced by a placeholder of their kind so that the order does not
+// depend on the values themselves.
+func (n *Normalizer) sort(list []any) {
+	keys := make([]string, len(list))
+	for i, e := range list {
+		b, _ := json.Marshal(n.shape(e))
+		keys[i] = string(b)
+	}
+	sort.Stable(byKey{list, keys})
+}
+
+// shape normalizes a value without numbering unnamed values.
+func (n *Normalizer) shape(v any) any {
+	switch v := v.(type) {
+	case string:
+		return n.string(v, false)
+	case []any:
+		list := make([]any, len(v))
+		for i, e := range v {
+			list[i] = n.shape(e)
+		}
+		return list
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			if s, ok := e.(json.Number); ok && timeKeys[k] {
+				m[k] = n.time(s)
+				continue
+			}
+			m[k] = n.shape(e)
+		}
+		return m
+	}
+	return v
+}
+
+type byKey struct {
+	list []any
+	keys []string
+}
+
+func (b byKey) Len() int           { return len(b.list) }
+func (b byKey) Less(i, j int) bool { return b.keys[i] < b.keys[j] }
+func (b byKey) Swap(i, j int) {
+	b.list[i], b.list[j] = b.list[j], b.list[i]
+	b.keys[i], b.keys[j] = b.keys[j], b.keys[i]
+}
+
+// string replaces the tokens in a string with placeholders. If number is false
+// unnamed tokens are replaced with the placeholder of their kind.
+func (n *Normalizer) string(s string, number bool) string {
+	return tokens.ReplaceAllStringFunc(s, func(tok string) string {
+		switch {
+		case len(tok) > 4 && tok[:4] == "did:":
+			return "<did:" + n.name(tok, "did", number) + ">"
+		case tok[0] == 'b':
+			c, err := cid.Decode(tok)
+			if err != nil {
+				return tok
+			}
+			return "<" + codecName(c) + ":" + n.name(digestutil.Format(c.Hash()), "digest", number) + ">"
+		case tok[0] == 'z':
+			if _, err := digestutil.Parse(tok); err != nil {
+				return tok
+			}
+			return "<" + n.name(tok, "digest", number) + ">"
+		default:
+			return "<host:" + n.name(tok, "host", number) + ">"
+		}
+	})
+}
+
+// name returns the name of a value, numbering it if it has not been named.
+func (n *Normalizer) name(value, kind string, number bool) string {
+	if name, ok := n.names[value]; ok {
+		return name
+	}
+	if !number {
+		return kind
+	}
+	n.counts[kind]++
+	name := fmt.Sprintf("%s-%d", kind, n.counts[kind])
+	n.names[value] = name
+	return name
+}
+
+// time replaces a Unix timestamp with its offset from now.
+func (n *Normalizer) time(v json.Number) any {
+	secs, err := v.Int64()
+	if err != nil || secs == 0 {
+		return v
+	}
+	if n.now.IsZero() {
+		return "<time>"
+	}
+	d := time.Unix(secs, 0).Sub(n.now.Truncate(time.Second))
+	switch {
+	case d > 0:
+		return "<now+" + d.String() + ">"
+	case d < 0:
+		return "<now-" + (-d).String() + ">"
+	}
+	return "<now>"
+}
+
+func codecName(c cid.Cid) string {
+	return multicodec.Code(c.Prefix().Codec).String()
+}
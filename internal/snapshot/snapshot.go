@@ -0,0 +1,67 @@
+// Package snapshot compares normalized documents, such as query results,
+// against golden files committed to the repository. Values that differ between
+// runs are replaced with stable placeholders before comparing, so a change to
+// the golden file means the services returned something different: an extra
+// claim, a missing index or a changed caveat.
+//
+// Run the tests with -update to write the golden files instead of comparing
+// against them, then review and commit the changes.
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/storacha/testthenetwork/internal/printer"
+	"github.com/storacha/testthenetwork/internal/steps"
+	"github.com/storacha/testthenetwork/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// Dir is the directory golden files are kept in, relative to the directory of
+// the package under test.
+const Dir = "testdata/snapshots"
+
+var update = flag.Bool("update", false, "write golden files in "+Dir+" instead of comparing against them")
+
+// QueryResults returns the JSON form of the query results, normalized by n.
+func QueryResults(t *testing.T, n *Normalizer, results types.QueryResult) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	printer.WriteQueryResults(t, &buf, printer.FormatJSON, results)
+	doc, err := n.JSON(buf.Bytes())
+	require.NoError(t, err)
+	return doc
+}
+
+// Match compares the document with the golden file of the test with the passed
+// name, failing the test if they differ. The golden files of a test are kept in
+// a directory named after it. With -update the golden file is written instead.
+// When the document does not match it is written to the artifacts of the test
+// for comparison.
+func Match(t testing.TB, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join(Dir, testutil.TestPath(t.Name()), name+".json")
+	if *update {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, got, 0644))
+		steps.Note(t, "updated snapshot", steps.A("path", path))
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		require.Failf(t, "missing snapshot", "%s does not exist, run the test with -update to create it", path)
+	}
+	require.NoError(t, err)
+	if !bytes.Equal(want, got) {
+		testutil.WriteArtifact(t, "snapshot-"+name+".json", got)
+		require.Equal(t, string(want), string(got), "snapshot %s differs, run the test with -update to accept the changes", path)
+	}
+}
@@ -0,0 +1,54 @@
+package snapshot
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/storacha/testthenetwork/internal/digestutil"
+	"github.com/storacha/testthenetwork/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizer(t *testing.T) {
+	now := time.Unix(1700000000, 500)
+	space := testutil.RandomPrincipal(t).DID()
+	issuer := testutil.RandomPrincipal(t).DID()
+	shard, _ := testutil.RandomBytes(t, 32)
+	blob, _ := testutil.RandomBytes(t, 32)
+	root := testutil.RandomCID(t)
+	host := &url.URL{Scheme: "http", Host: "127.0.0.1:4567"}
+
+	claim := func(digest string) string {
+		return fmt.Sprintf(`{"link": "%s", "issuer": "%s", "expiration": %d, "notBefore": 0, "nb": {"space": "%s", "content": {"digest": "%s"}, "location": ["%s/blob/%s"]}}`,
+			testutil.RandomCID(t), issuer, now.Add(30*time.Second).Unix(), space, digest, host, digest)
+	}
+	shardClaim, blobClaim := claim(digestutil.Format(shard)), claim(digestutil.Format(blob))
+	index := fmt.Sprintf(`{"content": "%s", "size": 256}`, root)
+
+	normalize := func(doc string) string {
+		n := New(WithNow(now), WithName(space, "space"), WithName(shard, "shard"), WithName(root, "root"), WithName(host, "storage"))
+		b, err := n.JSON([]byte(doc))
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	a := normalize(fmt.Sprintf(`{"claims": [%s, %s], "indexes": [%s]}`, shardClaim, blobClaim, index))
+	b := normalize(fmt.Sprintf(`{"indexes": [%s], "claims": [%s, %s]}`, index, blobClaim, shardClaim))
+	require.Equal(t, a, b) // order of claims is not significant
+
+	require.Contains(t, a, `"space": "<did:space>"`)
+	require.Contains(t, a, `"issuer": "<did:did-1>"`)
+	require.Contains(t, a, `"digest": "<shard>"`)
+	require.Contains(t, a, `"digest": "<digest-2>"`) // after the link of its claim, which sorts first
+	require.Contains(t, a, `"http://<host:storage>/blob/<shard>"`)
+	require.Contains(t, a, `"content": "<raw:root>"`)
+	require.Contains(t, a, `"expiration": "<now+30s>"`)
+	require.Contains(t, a, `"notBefore": 0`)
+	require.Contains(t, a, `"size": 256`)
+	require.NotContains(t, a, "did:key:")
+
+	_, err := New().JSON([]byte("{"))
+	require.Error(t, err)
+}
@@ -0,0 +1,384 @@
+// Package steps records the steps a test takes against the network, with their
+// attributes, outcome and duration. Steps are printed as they start and finish
+// and the timeline of each test is printed and written as a JSON artifact when
+// the test completes.
+package steps
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/storacha/testthenetwork/internal/clock"
+	"github.com/storacha/testthenetwork/internal/testutil"
+	"github.com/storacha/testthenetwork/internal/tracing"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Status is the outcome of a step.
+type Status string
+
+const (
+	// Running is the status of a step that has started but not finished.
+	Running Status = "running"
+	// OK is the status of a step that finished successfully.
+	OK Status = "ok"
+	// Failed is the status of a step that failed, or was running when the test
+	// failed.
+	Failed Status = "failed"
+	// Event is the status of an instantaneous note on the timeline.
+	Event Status = "event"
+)
+
+func (s Status) marker() string {
+	switch s {
+	case Running:
+		return "→"
+	case OK:
+		return "✔"
+	case Failed:
+		return "✘"
+	}
+	return "•"
+}
+
+// Attr is a key/value attribute of a step, such as a digest or URL.
+type Attr struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// A creates an attribute. The value is formatted with fmt.Sprint.
+func A(key string, value any) Attr {
+	return Attr{Key: key, Value: fmt.Sprint(value)}
+}
+
+func spanAttributes(attrs []Attr) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		kvs = append(kvs, attribute.String(a.Key, a.Value))
+	}
+	return kvs
+}
+
+// Step is a step in a test timeline.
+type Step struct {
+	rec     *Recorder
+	printed int // number of attributes already printed
+	initial int // number of attributes the step started with
+	endSpan func(err error, attrs ...attribute.KeyValue)
+
+	Name     string        `json:"name"`
+	Depth    int           `json:"depth"`
+	Attrs    []Attr        `json:"attrs,omitempty"`
+	Status   Status        `json:"status"`
+	Error    string        `json:"error,omitempty"`
+	Start    time.Time     `json:"start"`
+	End      time.Time     `json:"end"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// Set adds attributes to the step.
+func (s *Step) Set(attrs ...Attr) {
+	s.rec.mu.Lock()
+	defer s.rec.mu.Unlock()
+	s.Attrs = append(s.Attrs, attrs...)
+}
+
+// Done finishes the step, if it has not already finished. The step has failed
+// if the test has failed, so it can be deferred to mark the step that was
+// running when an assertion failed.
+func (s *Step) Done() {
+	if s.rec.t.Failed() {
+		s.finish(Failed, "")
+	} else {
+		s.finish(OK, "")
+	}
+}
+
+// Fail finishes the step with an error, if it has not already finished. It
+// does not fail the test.
+func (s *Step) Fail(err error) {
+	s.finish(Failed, err.Error())
+}
+
+func (s *Step) finish(status Status, msg string) {
+	r := s.rec
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s.Status != Running {
+		return
+	}
+	s.Status = status
+	s.Error = msg
+	s.End = r.clock.Now()
+	s.Duration = s.End.Sub(s.Start)
+	r.running--
+	r.print(s)
+
+	var err error
+	if status == Failed {
+		err = errors.New(msg)
+		if msg == "" {
+			err = errors.New("test failed")
+		}
+	}
+	s.endSpan(err, spanAttributes(s.Attrs[s.initial:])...)
+}
+
+// Attachment is text attached to a test timeline, such as printed query
+// results or service logs.
+type Attachment struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+// Recorder records the steps of a test.
+type Recorder struct {
+	t           testing.TB
+	mu          sync.Mutex
+	clock       clock.Clock
+	out         io.Writer
+	steps       []*Step
+	attachments []Attachment
+	running     int
+	failed      bool
+	skipped     bool
+}
+
+// Option configures a Recorder.
+type Option func(r *Recorder)
+
+// WithClock configures the clock used to time steps. The system clock is used
+// by default.
+func WithClock(c clock.Clock) Option {
+	return func(r *Recorder) {
+		r.clock = c
+	}
+}
+
+// WithOutput configures where steps are printed as they start and finish.
+// They are printed to stdout by default.
+func WithOutput(w io.Writer) Option {
+	return func(r *Recorder) {
+		r.out = w
+	}
+}
+
+// NewRecorder creates a recorder for the steps of the test.
+func NewRecorder(t testing.TB, options ...Option) *Recorder {
+	r := &Recorder{t: t, clock: clock.NewSystemClock(), out: os.Stdout}
+	for _, opt := range options {
+		opt(r)
+	}
+	return r
+}
+
+// Start starts a step. Steps started while another is running are nested
+// within it.
+func (r *Recorder) Start(name string, attrs ...Attr) *Step {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := &Step{rec: r, Name: name, Depth: r.running, Attrs: attrs, Status: Running, Start: r.clock.Now()}
+	s.initial = len(attrs)
+	s.endSpan = tracing.StartSpan(r.t, name, spanAttributes(attrs)...)
+	r.steps = append(r.steps, s)
+	r.running++
+	r.print(s)
+	return s
+}
+
+// Event records an instantaneous note on the timeline.
+func (r *Recorder) Event(name string, attrs ...Attr) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.clock.Now()
+	s := &Step{rec: r, Name: name, Depth: r.running, Attrs: attrs, Status: Event, Start: now, End: now}
+	r.steps = append(r.steps, s)
+	r.print(s)
+	tracing.AddEvent(r.t, name, spanAttributes(attrs)...)
+}
+
+// Attach attaches text to the timeline.
+func (r *Recorder) Attach(name string, content string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attachments = append(r.attachments, Attachment{name, content})
+}
+
+// Attachments returns the text attached to the timeline.
+func (r *Recorder) Attachments() []Attachment {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Attachment{}, r.attachments...)
+}
+
+// Name is the name of the test the steps were recorded for.
+func (r *Recorder) Name() string {
+	return r.t.Name()
+}
+
+// Failed reports whether the test failed. It is only accurate once the
+// recorder has been closed.
+func (r *Recorder) Failed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.failed
+}
+
+// Skipped reports whether the test was skipped. It is only accurate once the
+// recorder has been closed.
+func (r *Recorder) Skipped() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.skipped
+}
+
+// Steps returns a copy of the recorded steps.
+func (r *Recorder) Steps() []Step {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	steps := make([]Step, 0, len(r.steps))
+	for _, s := range r.steps {
+		steps = append(steps, *s)
+	}
+	return steps
+}
+
+// Close marks any steps that are still running as failed, since they can no
+// longer finish, and records the outcome of the test.
+func (r *Recorder) Close() {
+	r.mu.Lock()
+	r.failed = r.t.Failed()
+	r.skipped = r.t.Skipped()
+	var running []*Step
+	for _, s := range r.steps {
+		if s.Status == Running {
+			running = append(running, s)
+		}
+	}
+	r.mu.Unlock()
+	for _, s := range running {
+		s.finish(Failed, "did not finish")
+	}
+}
+
+func (r *Recorder) print(s *Step) {
+	var b strings.Builder
+	b.WriteString(strings.Repeat("  ", s.Depth))
+	b.WriteString(s.Status.marker())
+	b.WriteString(" ")
+	b.WriteString(s.Name)
+	if s.Status == OK || s.Status == Failed {
+		fmt.Fprintf(&b, " (%s)", s.Duration.Round(time.Microsecond))
+	}
+	if s.Error != "" {
+		fmt.Fprintf(&b, ": %s", s.Error)
+	}
+	// only print attributes that were set since the step was last printed
+	for _, a := range s.Attrs[s.printed:] {
+		fmt.Fprintf(&b, " %s=%s", a.Key, a.Value)
+	}
+	s.printed = len(s.Attrs)
+	fmt.Fprintln(r.out, b.String())
+}
+
+// WriteTimeline writes the steps as a table, with each step offset from the
+// start of the first step.
+func (r *Recorder) WriteTimeline(w io.Writer) {
+	steps := r.Steps()
+	fmt.Fprintln(w, "")
+	fmt.Fprintf(w, "# Timeline (%s)\n", r.t.Name())
+	fmt.Fprintln(w, "")
+	fmt.Fprintf(w, "| %3s | %-6s | %10s | %10s | %s\n", "#", "Status", "Offset", "Duration", "Step")
+	fmt.Fprintf(w, "| %3s | %-6s | %10s | %10s | %s\n", "--:", "------", "---------:", "---------:", "----")
+	for i, s := range steps {
+		offset := s.Start.Sub(steps[0].Start).Round(time.Millisecond)
+		duration := ""
+		if s.Status != Event {
+			duration = s.Duration.Round(time.Millisecond).String()
+		}
+		name := strings.Repeat("  ", s.Depth) + s.Name
+		if s.Error != "" {
+			name += ": " + s.Error
+		}
+		fmt.Fprintf(w, "| %3d | %-6s | %10s | %10s | %s\n", i+1, s.Status, offset, duration, name)
+	}
+	fmt.Fprintln(w, "")
+}
+
+// WriteJSON writes the steps as a JSON document.
+func (r *Recorder) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Test        string       `json:"test"`
+		Failed      bool         `json:"failed"`
+		Steps       []Step       `json:"steps"`
+		Attachments []Attachment `json:"attachments,omitempty"`
+	}{r.t.Name(), r.t.Failed(), r.Steps(), r.Attachments()})
+}
+
+var (
+	mutex     sync.Mutex
+	recorders = map[testing.TB]*Recorder{}
+	completed []*Recorder
+)
+
+// For returns the recorder for the test, creating it on first use. When the
+// test completes, steps that are still running are failed, the timeline is
+// printed and the steps are written to a JSON artifact.
+func For(t testing.TB) *Recorder {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if r, ok := recorders[t]; ok {
+		return r
+	}
+	r := NewRecorder(t)
+	recorders[t] = r
+	t.Cleanup(func() {
+		r.Close()
+
+		mutex.Lock()
+		delete(recorders, t)
+		completed = append(completed, r)
+		mutex.Unlock()
+
+		r.WriteTimeline(r.out)
+		var buf bytes.Buffer
+		require.NoError(t, r.WriteJSON(&buf))
+		testutil.WriteArtifact(t, "steps.json", buf.Bytes())
+	})
+	return r
+}
+
+// Completed returns the recorders of the tests that have completed, in the
+// order they completed.
+func Completed() []*Recorder {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return append([]*Recorder{}, completed...)
+}
+
+// Start starts a step in the timeline of the test.
+func Start(t testing.TB, name string, attrs ...Attr) *Step {
+	return For(t).Start(name, attrs...)
+}
+
+// Note records an event in the timeline of the test.
+func Note(t testing.TB, name string, attrs ...Attr) {
+	For(t).Event(name, attrs...)
+}
+
+// Attach attaches text to the timeline of the test.
+func Attach(t testing.TB, name string, content string) {
+	For(t).Attach(name, content)
+}
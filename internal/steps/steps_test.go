@@ -0,0 +1,62 @@
+package steps
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/storacha/testthenetwork/internal/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	clk := clock.NewFakeClock(time.Now())
+	var out bytes.Buffer
+	r := NewRecorder(t, WithClock(clk), WithOutput(&out))
+
+	outer := r.Start("outer", A("digest", "zQm"))
+	inner := r.Start("inner")
+	clk.Advance(time.Second)
+	inner.Set(A("url", "http://example.org"))
+	inner.Done()
+	r.Event("note")
+	failed := r.Start("failing")
+	failed.Fail(errors.New("boom"))
+	failed.Done() // already finished
+	clk.Advance(time.Second)
+	outer.Done()
+	r.Start("unfinished")
+	r.Close()
+
+	steps := r.Steps()
+	require.Len(t, steps, 5)
+	require.Equal(t, OK, steps[0].Status)
+	require.Equal(t, 2*time.Second, steps[0].Duration)
+	require.Equal(t, 1, steps[1].Depth)
+	require.Equal(t, time.Second, steps[1].Duration)
+	require.Equal(t, []Attr{{"url", "http://example.org"}}, steps[1].Attrs)
+	require.Equal(t, Event, steps[2].Status)
+	require.Equal(t, Failed, steps[3].Status)
+	require.Equal(t, "boom", steps[3].Error)
+	require.Equal(t, Failed, steps[4].Status)
+	require.Equal(t, 0, steps[4].Depth)
+
+	require.Contains(t, out.String(), "→ outer digest=zQm\n")
+	require.Contains(t, out.String(), "  ✔ inner (1s) url=http://example.org\n")
+	require.Contains(t, out.String(), "  ✘ failing (0s): boom\n")
+	require.Contains(t, out.String(), "✔ outer (2s)\n")
+
+	var buf bytes.Buffer
+	require.NoError(t, r.WriteJSON(&buf))
+	var doc struct {
+		Test  string `json:"test"`
+		Steps []Step `json:"steps"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+	require.Equal(t, t.Name(), doc.Test)
+	require.Len(t, doc.Steps, 5)
+	require.Equal(t, "failing", doc.Steps[3].Name)
+	require.Equal(t, Failed, doc.Steps[3].Status)
+}
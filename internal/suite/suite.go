@@ -0,0 +1,174 @@
+// Package suite has the scenarios every network is expected to pass. The
+// conformance package runs them against the implementations passed to it, and
+// the network tests run them against the components they select, checking
+// the outcome further with what they can see of the in-process services.
+package suite
+
+import (
+	"io"
+	"net/url"
+	"testing"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-capabilities/pkg/assert"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/principal"
+	"github.com/storacha/indexing-service/pkg/blobindex"
+	"github.com/storacha/indexing-service/pkg/client"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/storacha/testthenetwork/internal/claimassert"
+	"github.com/storacha/testthenetwork/internal/clock"
+	"github.com/storacha/testthenetwork/internal/digestutil"
+	"github.com/storacha/testthenetwork/internal/netstep"
+	"github.com/storacha/testthenetwork/internal/printer"
+	"github.com/storacha/testthenetwork/internal/steps"
+	"github.com/storacha/testthenetwork/internal/testutil"
+	"github.com/storacha/testthenetwork/internal/upload"
+	"github.com/stretchr/testify/require"
+)
+
+// Network is the started services a scenario runs against and the principals
+// that use them.
+type Network struct {
+	Clock clock.Clock
+
+	StorageID  principal.Signer
+	StorageURL url.URL
+	IndexingID principal.Signer
+
+	UploadService  *upload.UploadService
+	IndexingClient *client.Client
+
+	// Alice and Bob can publish claims to the indexing service with their
+	// proofs.
+	Alice      principal.Signer
+	AliceProof delegation.Proof
+	Bob        principal.Signer
+	BobProof   delegation.Proof
+}
+
+// Content is a CAR of random content and its index.
+type Content struct {
+	Root        ipld.Link
+	RootDigest  multihash.Multihash
+	Digest      multihash.Multihash
+	Data        []byte
+	IndexDigest multihash.Multihash
+	IndexLink   ipld.Link
+	IndexData   []byte
+}
+
+// Outcome is what a scenario uploaded and the result of its final query, so
+// that callers can check it further.
+type Outcome struct {
+	// Space is the space the query was filtered by, or the content was
+	// uploaded to if it was not filtered.
+	Space did.DID
+	// OtherSpace is the space the content was also uploaded to, if any.
+	OtherSpace did.DID
+	Content    Content
+	Result     types.QueryResult
+}
+
+// RoundTrip uploads content and its index to a space, fetches the content from
+// the location the storage node commits to, publishes an index claim for it
+// and queries the indexing service for the content, asserting the index and
+// the claims needed to retrieve it are returned.
+func RoundTrip(t *testing.T, n *Network) Outcome {
+	space := testutil.RandomPrincipal(t).DID()
+	content := GenerateContent(t, 256)
+
+	address := n.UploadService.BlobAdd(t, space, content.Digest, uint64(len(content.Data)))
+	require.NotNil(t, address)
+	netstep.PutBlob(t, address.URL, address.Headers, content.Data)
+	claim := n.UploadService.ConcludeHTTPPut(t, space, content.Digest, uint64(len(content.Data)))
+	nb, err := assert.LocationCaveatsReader.Read(claim.Capabilities()[0].Nb())
+	require.NoError(t, err)
+	require.NotEmpty(t, nb.Location)
+	_, digest := netstep.FetchBlob(t, nb.Location[0], multihash.SHA2_256)
+	require.Equal(t, content.Digest, digest)
+
+	address = n.UploadService.BlobAdd(t, space, content.IndexDigest, uint64(len(content.IndexData)))
+	require.NotNil(t, address)
+	netstep.PutBlob(t, address.URL, address.Headers, content.IndexData)
+	n.UploadService.ConcludeHTTPPut(t, space, content.IndexDigest, uint64(len(content.IndexData)))
+	netstep.PublishIndexClaim(t, n.IndexingClient, n.Clock, n.Alice, n.AliceProof, content.Root, content.IndexLink)
+
+	// without caches the content is only found once IPNI has synced
+	result := netstep.AwaitQueryClaims(t, n.IndexingClient, []multihash.Multihash{content.RootDigest})
+	printer.PrintQueryResults(t, result)
+
+	indexes := netstep.CollectIndexes(t, result)
+	require.Len(t, indexes, 1)
+	require.Equal(t, content.IndexLink, result.Indexes()[0])
+	require.True(t, indexes[0].Shards().Has(content.Digest))
+
+	claims := netstep.CollectClaims(t, result)
+	claimassert.Contains(t, claims, claimassert.Index(content.Root, content.IndexLink).Issuer(n.Alice.DID()).ExpiresAfter(n.Clock.Now()))
+	claimassert.Contains(t, claims, claimassert.Location(content.IndexDigest, space).Issuer(n.StorageID.DID()).LocationURL(n.StorageURL))
+	claimassert.Contains(t, claims, claimassert.Location(content.Digest, space).Issuer(n.StorageID.DID()).LocationURL(n.StorageURL))
+	netstep.RequireVerifiedClaims(t, claims, n.IndexingID, n.StorageID, n.StorageURL)
+
+	return Outcome{Space: space, Content: content, Result: result}
+}
+
+// FilterBySpace uploads the same content and index to the spaces of alice and
+// bob, who both publish an index claim for it, and asserts that a query
+// filtered by bob's space returns location commitments for that space only.
+func FilterBySpace(t *testing.T, n *Network) Outcome {
+	aliceSpace := testutil.RandomPrincipal(t).DID()
+	content := GenerateContent(t, 256)
+	n.Upload(t, aliceSpace, content.Digest, content.Data)
+	n.Upload(t, aliceSpace, content.IndexDigest, content.IndexData)
+	netstep.PublishIndexClaim(t, n.IndexingClient, n.Clock, n.Alice, n.AliceProof, content.Root, content.IndexLink)
+
+	// the storage node already has the blobs, so it allocates no space for bob
+	// to put them to
+	bobSpace := testutil.RandomPrincipal(t).DID()
+	require.Nil(t, n.UploadService.BlobAdd(t, bobSpace, content.Digest, uint64(len(content.Data))))
+	n.UploadService.ConcludeHTTPPut(t, bobSpace, content.Digest, uint64(len(content.Data)))
+	require.Nil(t, n.UploadService.BlobAdd(t, bobSpace, content.IndexDigest, uint64(len(content.IndexData))))
+	n.UploadService.ConcludeHTTPPut(t, bobSpace, content.IndexDigest, uint64(len(content.IndexData)))
+	netstep.PublishIndexClaim(t, n.IndexingClient, n.Clock, n.Bob, n.BobProof, content.Root, content.IndexLink)
+
+	result := netstep.AwaitQueryClaims(t, n.IndexingClient, []multihash.Multihash{content.RootDigest}, bobSpace)
+	printer.PrintQueryResults(t, result)
+
+	require.Len(t, netstep.CollectIndexes(t, result), 1)
+	require.Equal(t, content.IndexLink, result.Indexes()[0])
+
+	claims := netstep.CollectClaims(t, result)
+	claimassert.Contains(t, claims, claimassert.Index(content.Root, content.IndexLink))
+	claimassert.Contains(t, claims, claimassert.Location(content.IndexDigest, bobSpace))
+	claimassert.Contains(t, claims, claimassert.Location(content.Digest, bobSpace))
+	claimassert.NotContains(t, claims, claimassert.Claim().OutsideSpaces(bobSpace))
+	netstep.RequireVerifiedClaims(t, claims, n.IndexingID, n.StorageID, n.StorageURL)
+
+	return Outcome{Space: bobSpace, OtherSpace: aliceSpace, Content: content, Result: result}
+}
+
+// GenerateContent generates a CAR of random content and an index of it.
+func GenerateContent(t *testing.T, size int) Content {
+	step := steps.Start(t, "generate content", steps.A("size", size))
+	defer step.Done()
+	root, rootDigest, digest, data := testutil.RandomCAR(t, size)
+	index := testutil.Must(blobindex.FromShardArchives(root, [][]byte{data}))(t)
+	indexData := testutil.Must(io.ReadAll(testutil.Must(index.Archive())(t)))(t)
+	indexDigest := testutil.Must(multihash.Sum(indexData, multihash.SHA2_256, -1))(t)
+	indexLink := digestutil.CARLink(indexDigest)
+	step.Set(steps.A("root", root), steps.A("blob", digestutil.Format(digest)), steps.A("index", indexLink))
+	return Content{root, rootDigest, digest, data, indexDigest, indexLink, indexData}
+}
+
+// Upload adds the blob to the space, puts it to the storage node if the node
+// does not already have it and concludes the put, returning the location
+// commitment the node issues.
+func (n *Network) Upload(t *testing.T, space did.DID, digest multihash.Multihash, data []byte) delegation.Delegation {
+	address := n.UploadService.BlobAdd(t, space, digest, uint64(len(data)))
+	if address != nil {
+		netstep.PutBlob(t, address.URL, address.Headers, data)
+	}
+	return n.UploadService.ConcludeHTTPPut(t, space, digest, uint64(len(data)))
+}
@@ -0,0 +1,60 @@
+package testutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// ArtifactsDirEnv is the environment variable that configures the directory
+// test artifacts are written to. It defaults to DefaultArtifactsDir, relative
+// to the directory of the package under test.
+const ArtifactsDirEnv = "TTN_ARTIFACTS_DIR"
+
+const DefaultArtifactsDir = "artifacts"
+
+// ArtifactsDir returns the directory artifacts for the current test are written
+// to, creating it if it does not exist. Each (sub)test has its own directory.
+func ArtifactsDir(t testing.TB) string {
+	t.Helper()
+	dir := filepath.Join(ArtifactsRoot(), TestPath(t.Name()))
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	return dir
+}
+
+// ArtifactsRoot returns the directory artifacts for all tests are written to.
+func ArtifactsRoot() string {
+	if dir := os.Getenv(ArtifactsDirEnv); dir != "" {
+		return dir
+	}
+	return DefaultArtifactsDir
+}
+
+// WriteArtifact writes a test artifact with the passed file name and returns
+// the path it was written to.
+func WriteArtifact(t testing.TB, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(ArtifactsDir(t), name)
+	require.NoError(t, os.WriteFile(path, data, 0644))
+	t.Logf("wrote artifact: %s", path)
+	return path
+}
+
+// TestPath converts a test name to a relative path, where subtests are
+// subdirectories of their parent test.
+func TestPath(name string) string {
+	parts := strings.Split(name, "/")
+	for i, p := range parts {
+		parts[i] = strings.Map(func(r rune) rune {
+			switch {
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+				return r
+			}
+			return '_'
+		}, p)
+	}
+	return filepath.Join(parts...)
+}
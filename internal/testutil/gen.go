@@ -1,21 +1,18 @@
 package testutil
 
 import (
-	crand "crypto/rand"
 	"fmt"
 	"io"
 	"net/url"
 	"testing"
 
-	"github.com/ipfs/go-cid"
 	"github.com/ipld/go-ipld-prime"
-	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
 	"github.com/multiformats/go-multihash"
 	"github.com/storacha/go-ucanto/core/car"
 	"github.com/storacha/go-ucanto/core/ipld/block"
 	"github.com/storacha/go-ucanto/principal"
-	"github.com/storacha/go-ucanto/principal/ed25519/signer"
 	"github.com/storacha/go-ucanto/ucan"
+	"github.com/storacha/testthenetwork/internal/digestutil"
 	"github.com/stretchr/testify/require"
 )
 
@@ -23,23 +20,66 @@ import (
 // size. It returns the link of the root block, the hash of the root block, the
 // hash of the CAR itself and the bytes of the CAR.
 func RandomCAR(t *testing.T, size int) (ipld.Link, multihash.Multihash, multihash.Multihash, []byte) {
-	digest, bytes := RandomBytes(t, size)
-	root := cidlink.Link{Cid: cid.NewCidV1(cid.Raw, digest)}
+	return RandomCARWithHash(t, size, multihash.SHA2_256)
+}
+
+// RandomCARWithHash is like RandomCAR but hashes the root block and the CAR
+// with the passed multihash function.
+func RandomCARWithHash(t *testing.T, size int, code uint64) (ipld.Link, multihash.Multihash, multihash.Multihash, []byte) {
+	digest, bytes := RandomBytesWithHash(t, size, code)
+	root := digestutil.RawLink(digest)
 	r := car.Encode([]ipld.Link{root}, func(yield func(block.Block, error) bool) {
 		yield(block.NewBlock(root, bytes), nil)
 	})
 	carBytes, err := io.ReadAll(r)
 	require.NoError(t, err)
-	carDigest, err := multihash.Sum(carBytes, multihash.SHA2_256, -1)
+	carDigest, err := multihash.Sum(carBytes, code, -1)
 	require.NoError(t, err)
 	return root, digest, carDigest, carBytes
 }
 
+// RandomMultiBlockCAR creates a CAR with the passed number of blocks of random
+// bytes of the specified size, hashed with the passed multihash function. The
+// first block is the root. It returns the link of the root block, the hash of
+// each block, in the order they appear in the CAR, the hash of the CAR itself
+// and the bytes of the CAR.
+func RandomMultiBlockCAR(t *testing.T, blocks, size int, code uint64) (ipld.Link, []multihash.Multihash, multihash.Multihash, []byte) {
+	var blks []block.Block
+	var digests []multihash.Multihash
+	for range blocks {
+		digest, bytes := RandomBytesWithHash(t, size, code)
+		blks = append(blks, block.NewBlock(digestutil.RawLink(digest), bytes))
+		digests = append(digests, digest)
+	}
+	root := blks[0].Link()
+	r := car.Encode([]ipld.Link{root}, func(yield func(block.Block, error) bool) {
+		for _, b := range blks {
+			if !yield(b, nil) {
+				return
+			}
+		}
+	})
+	carBytes, err := io.ReadAll(r)
+	require.NoError(t, err)
+	carDigest, err := multihash.Sum(carBytes, code, -1)
+	require.NoError(t, err)
+	return root, digests, carDigest, carBytes
+}
+
+// RandomBytes generates random bytes of the specified size, from the
+// deterministic source of the test, and hashes them with SHA2-256.
 func RandomBytes(t *testing.T, size int) (multihash.Multihash, []byte) {
+	return RandomBytesWithHash(t, size, multihash.SHA2_256)
+}
+
+// RandomBytesWithHash generates random bytes of the specified size, from the
+// deterministic source of the test, and hashes them with the passed multihash
+// function.
+func RandomBytesWithHash(t *testing.T, size int, code uint64) (multihash.Multihash, []byte) {
 	bytes := make([]byte, size)
-	_, err := crand.Read(bytes)
+	_, err := io.ReadFull(RandReader(t), bytes)
 	require.NoError(t, err)
-	digest, err := multihash.Sum(bytes, multihash.SHA2_256, -1)
+	digest, err := multihash.Sum(bytes, code, -1)
 	require.NoError(t, err)
 	return digest, bytes
 }
@@ -48,8 +88,10 @@ func RandomPrincipal(t *testing.T) ucan.Principal {
 	return RandomSigner(t)
 }
 
+// RandomSigner generates an ed25519 identity from the deterministic source of
+// the test.
 func RandomSigner(t *testing.T) principal.Signer {
-	id, err := signer.Generate()
+	id, err := newSigner(RandReader(t))
 	require.NoError(t, err)
 	return id
 }
@@ -75,5 +117,5 @@ func RandomLocalURL(t *testing.T) url.URL {
 
 func RandomCID(t *testing.T) ipld.Link {
 	digest, _ := RandomBytes(t, 10)
-	return cidlink.Link{Cid: cid.NewCidV1(cid.Raw, digest)}
+	return digestutil.RawLink(digest)
 }
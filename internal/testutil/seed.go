@@ -0,0 +1,125 @@
+package testutil
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	mrand "math/rand/v2"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/multiformats/go-varint"
+	"github.com/storacha/go-ucanto/principal"
+	"github.com/storacha/go-ucanto/principal/ed25519/signer"
+	"github.com/storacha/go-ucanto/principal/ed25519/verifier"
+	"github.com/stretchr/testify/require"
+)
+
+// SeedEnv is the environment variable that sets the seed used to generate
+// content and identities. A seed passed to SetSeed takes precedence over it.
+const SeedEnv = "TTN_SEED"
+
+var (
+	seedOnce sync.Once
+	seedSet  string
+	seed     uint64
+	seedErr  error
+)
+
+// SetSeed sets the seed that generated content and identities are derived
+// from, such as the value of a -seed flag. An empty seed leaves it to the
+// TTN_SEED environment variable. It must be called before Seed.
+func SetSeed(s string) {
+	seedSet = s
+}
+
+// Seed returns the seed that generated content and identities are derived
+// from. It is the seed passed to SetSeed or read from the TTN_SEED environment
+// variable, or chosen at random if neither is set.
+func Seed() (uint64, error) {
+	seedOnce.Do(func() {
+		s := seedSet
+		if s == "" {
+			s = os.Getenv(SeedEnv)
+		}
+		if s != "" {
+			seed, seedErr = strconv.ParseUint(s, 10, 64)
+			if seedErr != nil {
+				seedErr = fmt.Errorf("parsing seed %q: %w", s, seedErr)
+			}
+			return
+		}
+		var b [8]byte
+		_, seedErr = rand.Read(b[:])
+		seed = binary.BigEndian.Uint64(b[:])
+	})
+	return seed, seedErr
+}
+
+var (
+	sourcesMutex sync.Mutex
+	sources      = map[testing.TB]*lockedReader{}
+)
+
+// lockedReader is a reader that can be shared between goroutines.
+type lockedReader struct {
+	mu sync.Mutex
+	r  io.Reader
+}
+
+func (l *lockedReader) Read(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Read(p)
+}
+
+// RandReader returns the deterministic source of random bytes for the test,
+// derived from the seed and the name of the test. Each (sub)test has its own
+// source, so a test generates the same content and identities whichever other
+// tests run alongside it.
+func RandReader(t testing.TB) io.Reader {
+	sourcesMutex.Lock()
+	defer sourcesMutex.Unlock()
+	if r, ok := sources[t]; ok {
+		return r
+	}
+	s, err := Seed()
+	require.NoError(t, err)
+	t.Logf("seed: %d (set -seed or $%s to reproduce)", s, SeedEnv)
+
+	r := &lockedReader{r: mrand.NewChaCha8(sha256.Sum256(fmt.Appendf(nil, "%d/%s", s, t.Name())))}
+	sources[t] = r
+	t.Cleanup(func() {
+		sourcesMutex.Lock()
+		delete(sources, t)
+		sourcesMutex.Unlock()
+	})
+	return r
+}
+
+// Rand returns a pseudo-random number generator seeded from the deterministic
+// source of the test. It is not safe for concurrent use.
+func Rand(t testing.TB) *mrand.Rand {
+	var b [16]byte
+	_, err := io.ReadFull(RandReader(t), b[:])
+	require.NoError(t, err)
+	return mrand.New(mrand.NewPCG(binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])))
+}
+
+// newSigner creates an ed25519 signer with a key generated from the reader.
+func newSigner(r io.Reader) (principal.Signer, error) {
+	pub, priv, err := ed25519.GenerateKey(r)
+	if err != nil {
+		return nil, fmt.Errorf("generating Ed25519 key: %w", err)
+	}
+	b := varint.ToUvarint(signer.Code)
+	b = append(b, priv.Seed()...)
+	b = append(b, varint.ToUvarint(verifier.Code)...)
+	b = append(b, pub...)
+	return signer.Decode(b)
+}
@@ -0,0 +1,41 @@
+package testutil
+
+import (
+	"io"
+	mrand "math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSigner(t *testing.T) {
+	newReader := func(b byte) io.Reader { return mrand.NewChaCha8([32]byte{b}) }
+
+	a, err := newSigner(newReader(1))
+	require.NoError(t, err)
+	b, err := newSigner(newReader(1))
+	require.NoError(t, err)
+	c, err := newSigner(newReader(2))
+	require.NoError(t, err)
+
+	require.Equal(t, a.DID(), b.DID())
+	require.NotEqual(t, a.DID(), c.DID())
+
+	msg := []byte("hello")
+	require.True(t, a.Verifier().Verify(msg, a.Sign(msg)))
+}
+
+func TestRandReader(t *testing.T) {
+	var first []byte
+	t.Run("sub", func(t *testing.T) {
+		_, first = RandomBytes(t, 16)
+		// the source is shared for the duration of the test
+		_, second := RandomBytes(t, 16)
+		require.NotEqual(t, first, second)
+	})
+	t.Run("sub", func(t *testing.T) {
+		// a subtest with a different name has its own source
+		_, other := RandomBytes(t, 16)
+		require.NotEqual(t, first, other)
+	})
+}
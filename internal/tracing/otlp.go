@@ -0,0 +1,192 @@
+package tracing
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// The types below follow the JSON encoding of the OTLP trace data model:
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string         `json:"stringValue,omitempty"`
+	BoolValue   *bool           `json:"boolValue,omitempty"`
+	IntValue    *string         `json:"intValue,omitempty"`
+	DoubleValue *float64        `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpArrayValue `json:"arrayValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpValue `json:"values"`
+}
+
+// WriteOTLPJSON writes the spans in the OTLP JSON encoding, grouped by
+// resource and instrumentation scope.
+func WriteOTLPJSON(w io.Writer, spans tracetest.SpanStubs) error {
+	var doc otlpTraces
+	resources := map[string]int{}
+	scopes := map[[2]string]int{}
+	for _, s := range spans {
+		rkey := s.Resource.Encoded(attribute.DefaultEncoder())
+		ri, ok := resources[rkey]
+		if !ok {
+			ri = len(doc.ResourceSpans)
+			resources[rkey] = ri
+			doc.ResourceSpans = append(doc.ResourceSpans, otlpResourceSpans{
+				Resource: otlpResource{Attributes: otlpAttributes(s.Resource.Attributes())},
+			})
+		}
+		rs := &doc.ResourceSpans[ri]
+		skey := [2]string{rkey, scopeKey(s.InstrumentationScope)}
+		si, ok := scopes[skey]
+		if !ok {
+			si = len(rs.ScopeSpans)
+			scopes[skey] = si
+			rs.ScopeSpans = append(rs.ScopeSpans, otlpScopeSpans{
+				Scope: otlpScope{Name: s.InstrumentationScope.Name, Version: s.InstrumentationScope.Version},
+			})
+		}
+		rs.ScopeSpans[si].Spans = append(rs.ScopeSpans[si].Spans, otlpSpanFromStub(s))
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+func scopeKey(s instrumentation.Scope) string {
+	return s.Name + "@" + s.Version
+}
+
+func otlpSpanFromStub(s tracetest.SpanStub) otlpSpan {
+	span := otlpSpan{
+		TraceID:           s.SpanContext.TraceID().String(),
+		SpanID:            s.SpanContext.SpanID().String(),
+		Name:              s.Name,
+		Kind:              int(s.SpanKind),
+		StartTimeUnixNano: strconv.FormatInt(s.StartTime.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.EndTime.UnixNano(), 10),
+		Attributes:        otlpAttributes(s.Attributes),
+		Status:            otlpStatus{Code: otlpStatusCode(s.Status.Code), Message: s.Status.Description},
+	}
+	if s.Parent.IsValid() {
+		span.ParentSpanID = s.Parent.SpanID().String()
+	}
+	for _, e := range s.Events {
+		span.Events = append(span.Events, otlpEvent{
+			TimeUnixNano: strconv.FormatInt(e.Time.UnixNano(), 10),
+			Name:         e.Name,
+			Attributes:   otlpAttributes(e.Attributes),
+		})
+	}
+	return span
+}
+
+// otlpStatusCode converts a status code to its OTLP value, which orders OK and
+// error differently to the Go API.
+func otlpStatusCode(c codes.Code) int {
+	switch c {
+	case codes.Ok:
+		return 1
+	case codes.Error:
+		return 2
+	}
+	return 0
+}
+
+func otlpAttributes(attrs []attribute.KeyValue) []otlpKeyValue {
+	var kvs []otlpKeyValue
+	for _, a := range attrs {
+		kvs = append(kvs, otlpKeyValue{Key: string(a.Key), Value: otlpAttributeValue(a.Value)})
+	}
+	return kvs
+}
+
+func otlpAttributeValue(v attribute.Value) otlpValue {
+	switch v.Type() {
+	case attribute.BOOL:
+		b := v.AsBool()
+		return otlpValue{BoolValue: &b}
+	case attribute.INT64:
+		i := strconv.FormatInt(v.AsInt64(), 10)
+		return otlpValue{IntValue: &i}
+	case attribute.FLOAT64:
+		f := v.AsFloat64()
+		return otlpValue{DoubleValue: &f}
+	case attribute.BOOLSLICE:
+		return otlpArray(v.AsBoolSlice(), attribute.BoolValue)
+	case attribute.INT64SLICE:
+		return otlpArray(v.AsInt64Slice(), attribute.Int64Value)
+	case attribute.FLOAT64SLICE:
+		return otlpArray(v.AsFloat64Slice(), attribute.Float64Value)
+	case attribute.STRINGSLICE:
+		return otlpArray(v.AsStringSlice(), attribute.StringValue)
+	}
+	s := v.Emit()
+	return otlpValue{StringValue: &s}
+}
+
+func otlpArray[T any](items []T, value func(T) attribute.Value) otlpValue {
+	values := make([]otlpValue, 0, len(items))
+	for _, item := range items {
+		values = append(values, otlpAttributeValue(value(item)))
+	}
+	return otlpValue{ArrayValue: &otlpArrayValue{Values: values}}
+}
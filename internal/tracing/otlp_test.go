@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestWriteOTLPJSON(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	tracer := tp.Tracer(TracerName)
+
+	ctx, parent := tracer.Start(context.Background(), "query claims")
+	_, child := tracer.Start(ctx, "claims GET")
+	child.SetAttributes(attribute.Bool("cache.hit", false), attribute.StringSlice("keys", []string{"a", "b"}))
+	child.RecordError(errors.New("boom"))
+	child.SetStatus(codes.Error, "boom")
+	child.End()
+	parent.End()
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteOTLPJSON(&buf, exp.GetSpans()))
+
+	var doc otlpTraces
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+	require.Len(t, doc.ResourceSpans, 1)
+	require.Len(t, doc.ResourceSpans[0].ScopeSpans, 1)
+	scope := doc.ResourceSpans[0].ScopeSpans[0]
+	require.Equal(t, TracerName, scope.Scope.Name)
+	require.Len(t, scope.Spans, 2)
+
+	c, p := scope.Spans[0], scope.Spans[1]
+	require.Equal(t, "claims GET", c.Name)
+	require.Equal(t, p.SpanID, c.ParentSpanID)
+	require.Equal(t, p.TraceID, c.TraceID)
+	require.Empty(t, p.ParentSpanID)
+	require.Equal(t, 2, c.Status.Code)
+	require.Equal(t, "boom", c.Status.Message)
+	require.Len(t, c.Events, 1)
+	require.Equal(t, "exception", c.Events[0].Name)
+
+	require.Len(t, c.Attributes, 2)
+	require.Equal(t, "cache.hit", c.Attributes[0].Key)
+	require.False(t, *c.Attributes[0].Value.BoolValue)
+	require.Len(t, c.Attributes[1].Value.ArrayValue.Values, 2)
+	require.Equal(t, "b", *c.Attributes[1].Value.ArrayValue.Values[1].StringValue)
+}
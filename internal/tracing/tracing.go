@@ -0,0 +1,264 @@
+// Package tracing collects OpenTelemetry traces from the in-process network.
+//
+// A global tracer provider with an in-memory exporter is installed on first
+// use. Servers and HTTP clients are instrumented with otelhttp and trace
+// context is propagated between them, so a single request can be followed
+// from the test, through the services and out to their dependencies.
+//
+// Spans are collected per test. Tests in this module start their own network
+// and do not run in parallel, so all spans ended while a test is running are
+// attributed to it. Requests made without a span in their context, such as
+// those made by clients that do not accept a context, are parented to the
+// innermost span of the running test.
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/storacha/testthenetwork/internal/testutil"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the name of the tracer used for spans created by the harness.
+const TracerName = "github.com/storacha/testthenetwork"
+
+var (
+	installOnce sync.Once
+	exporter    *tracetest.InMemoryExporter
+	provider    *sdktrace.TracerProvider
+	// transport is the default HTTP transport before it was instrumented.
+	transport http.RoundTripper
+
+	mutex   sync.Mutex
+	current *testTrace
+)
+
+// Install installs the global tracer provider and propagator, and instruments
+// the default HTTP transport. It is safe to call more than once.
+func Install() {
+	installOnce.Do(func() {
+		exporter = tracetest.NewInMemoryExporter()
+		provider = sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+		transport = http.DefaultTransport
+		http.DefaultTransport = Transport(transport)
+	})
+}
+
+// Tracer returns the tracer used for spans created by the harness.
+func Tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
+
+type testTrace struct {
+	t     testing.TB
+	mu    sync.Mutex
+	stack []context.Context
+}
+
+// Start starts collecting spans for the test under a root span named after
+// it. When the test completes, the collected spans are printed as a tree and
+// written as an OTLP JSON artifact.
+func Start(t testing.TB) {
+	Install()
+	require.NoError(t, provider.ForceFlush(context.Background()))
+	exporter.Reset()
+
+	ctx, root := Tracer().Start(context.Background(), t.Name(), trace.WithNewRoot())
+	tt := &testTrace{t: t, stack: []context.Context{ctx}}
+	mutex.Lock()
+	current = tt
+	mutex.Unlock()
+
+	t.Cleanup(func() {
+		if t.Failed() {
+			root.SetStatus(codes.Error, "test failed")
+		}
+		root.End()
+		mutex.Lock()
+		if current == tt {
+			current = nil
+		}
+		mutex.Unlock()
+
+		require.NoError(t, provider.ForceFlush(context.Background()))
+		spans := exporter.GetSpans()
+		fmt.Println("")
+		fmt.Printf("# Trace (%s)\n", t.Name())
+		fmt.Println("")
+		writeTree(os.Stdout, spans)
+		fmt.Println("")
+
+		var buf bytes.Buffer
+		require.NoError(t, WriteOTLPJSON(&buf, spans))
+		testutil.WriteArtifact(t, "traces.json", buf.Bytes())
+	})
+}
+
+// traceFor returns the trace of the test, or nil if spans are not being
+// collected for it.
+func traceFor(t testing.TB) *testTrace {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if current == nil || current.t != t {
+		return nil
+	}
+	return current
+}
+
+// Context returns a context containing the innermost span of the test, or the
+// background context if spans are not being collected for the test.
+func Context(t testing.TB) context.Context {
+	tt := traceFor(t)
+	if tt == nil {
+		return context.Background()
+	}
+	return tt.context()
+}
+
+// AddEvent adds an event to the innermost span of the test.
+func AddEvent(t testing.TB, name string, attrs ...attribute.KeyValue) {
+	trace.SpanFromContext(Context(t)).AddEvent(name, trace.WithAttributes(attrs...))
+}
+
+func (tt *testTrace) context() context.Context {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	return tt.stack[len(tt.stack)-1]
+}
+
+// StartSpan starts a span in the test that is the parent of spans started
+// until it ends. The returned function ends the span, adding the attributes
+// and recording the error if it is not nil. It does nothing if spans are not
+// being collected for the test.
+func StartSpan(t testing.TB, name string, attrs ...attribute.KeyValue) func(err error, attrs ...attribute.KeyValue) {
+	tt := traceFor(t)
+	if tt == nil {
+		return func(error, ...attribute.KeyValue) {}
+	}
+
+	tt.mu.Lock()
+	ctx, span := Tracer().Start(tt.stack[len(tt.stack)-1], name, trace.WithAttributes(attrs...))
+	tt.stack = append(tt.stack, ctx)
+	tt.mu.Unlock()
+
+	return func(err error, attrs ...attribute.KeyValue) {
+		span.SetAttributes(attrs...)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+		tt.mu.Lock()
+		defer tt.mu.Unlock()
+		for i := len(tt.stack) - 1; i > 0; i-- {
+			if tt.stack[i] == ctx {
+				tt.stack = append(tt.stack[:i], tt.stack[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Transport instruments an HTTP transport so that it creates client spans and
+// propagates trace context. Requests without a span in their context are
+// parented to the innermost span of the running test.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return &testTransport{otelhttp.NewTransport(base)}
+}
+
+type testTransport struct {
+	base http.RoundTripper
+}
+
+func (tt *testTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !trace.SpanContextFromContext(req.Context()).IsValid() {
+		mutex.Lock()
+		cur := current
+		mutex.Unlock()
+		if cur != nil {
+			req = req.WithContext(cur.context())
+		}
+	}
+	return tt.base.RoundTrip(req)
+}
+
+// HTTPClient returns an HTTP client with an instrumented transport.
+func HTTPClient(timeout time.Duration) *http.Client {
+	Install()
+	return &http.Client{Timeout: timeout, Transport: Transport(transport)}
+}
+
+// Handler instruments an HTTP handler so that it creates server spans, using
+// the trace context propagated by the client.
+func Handler(h http.Handler, operation string) http.Handler {
+	return otelhttp.NewHandler(h, operation)
+}
+
+// writeTree writes the spans as an indented tree, with the offset of each span
+// from the start of the earliest span and its duration.
+func writeTree(w io.Writer, spans tracetest.SpanStubs) {
+	if len(spans) == 0 {
+		return
+	}
+	children := map[trace.SpanID][]tracetest.SpanStub{}
+	ids := map[trace.SpanID]struct{}{}
+	start := spans[0].StartTime
+	for _, s := range spans {
+		ids[s.SpanContext.SpanID()] = struct{}{}
+		if s.StartTime.Before(start) {
+			start = s.StartTime
+		}
+	}
+	var roots []tracetest.SpanStub
+	for _, s := range spans {
+		parent := s.Parent.SpanID()
+		if _, ok := ids[parent]; ok && s.Parent.IsValid() {
+			children[parent] = append(children[parent], s)
+		} else {
+			roots = append(roots, s)
+		}
+	}
+	byStart := func(ss []tracetest.SpanStub) {
+		sort.SliceStable(ss, func(i, j int) bool { return ss[i].StartTime.Before(ss[j].StartTime) })
+	}
+	var walk func(s tracetest.SpanStub, depth int)
+	walk = func(s tracetest.SpanStub, depth int) {
+		marker := ""
+		if s.Status.Code == codes.Error {
+			marker = " ✘"
+		}
+		fmt.Fprintf(w, "%10s %10s %s%s%s\n",
+			"+"+s.StartTime.Sub(start).Round(time.Millisecond).String(),
+			s.EndTime.Sub(s.StartTime).Round(time.Microsecond).String(),
+			strings.Repeat("  ", depth), s.Name, marker)
+		cs := children[s.SpanContext.SpanID()]
+		byStart(cs)
+		for _, c := range cs {
+			walk(c, depth+1)
+		}
+	}
+	byStart(roots)
+	for _, r := range roots {
+		walk(r, 0)
+	}
+}
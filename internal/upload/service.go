@@ -1,9 +1,9 @@
 package upload
 
 import (
-	"fmt"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/ipld/go-ipld-prime"
 	"github.com/multiformats/go-multihash"
@@ -19,7 +19,9 @@ import (
 	"github.com/storacha/go-ucanto/principal"
 	uhttp "github.com/storacha/go-ucanto/transport/http"
 	"github.com/storacha/go-ucanto/ucan"
+	"github.com/storacha/testthenetwork/internal/clock"
 	"github.com/storacha/testthenetwork/internal/digestutil"
+	"github.com/storacha/testthenetwork/internal/steps"
 	"github.com/storacha/testthenetwork/internal/testutil"
 	"github.com/stretchr/testify/require"
 )
@@ -33,8 +35,14 @@ type Config struct {
 	// StorageProof is a delegation allowing the upload service to invoke
 	// blob/allocate and blob/accept on the storage node.
 	StorageProof delegation.Proof
+	// Clock is used to calculate invocation expiry. It defaults to the system
+	// clock.
+	Clock clock.Clock
 }
 
+// invocationTTL is the time invocations are valid for after they are issued.
+const invocationTTL = 30 * time.Second
+
 // UploadService simulates actions taken by the upload service in response to
 // client invocations.
 type UploadService struct {
@@ -47,8 +55,8 @@ type UploadService struct {
 // upload address if required (i.e. it may be nil if the storage node already
 // has the blob).
 func (s *UploadService) BlobAdd(t *testing.T, space did.DID, digest multihash.Multihash, size uint64) *blob.Address {
-	fmt.Printf("→ performing blob/add with %s\n", digestutil.Format(digest))
-	defer fmt.Println("✔ blob/add success")
+	step := steps.Start(t, "blob/add", steps.A("digest", digestutil.Format(digest)), steps.A("size", size))
+	defer step.Done()
 
 	inv, err := blob.Allocate.Invoke(
 		s.cfg.ID,
@@ -63,6 +71,7 @@ func (s *UploadService) BlobAdd(t *testing.T, space did.DID, digest multihash.Mu
 			Cause: testutil.RandomCID(t),
 		},
 		delegation.WithProof(s.cfg.StorageProof),
+		delegation.WithExpiration(int(s.cfg.Clock.Now().Add(invocationTTL).Unix())),
 	)
 	require.NoError(t, err)
 
@@ -93,8 +102,8 @@ func (s *UploadService) BlobAdd(t *testing.T, space did.DID, digest multihash.Mu
 // from the client. It sends a blob/accept invocation to the storage node and
 // returns the location commitment.
 func (s *UploadService) ConcludeHTTPPut(t *testing.T, space did.DID, digest multihash.Multihash, size uint64) delegation.Delegation {
-	fmt.Println("→ performing ucan/conclude for http/put")
-	defer fmt.Println("✔ ucan/conclude success")
+	step := steps.Start(t, "ucan/conclude http/put", steps.A("digest", digestutil.Format(digest)))
+	defer step.Done()
 
 	inv, err := blob.Accept.Invoke(
 		s.cfg.ID,
@@ -114,6 +123,7 @@ func (s *UploadService) ConcludeHTTPPut(t *testing.T, space did.DID, digest mult
 			},
 		},
 		delegation.WithProof(s.cfg.StorageProof),
+		delegation.WithExpiration(int(s.cfg.Clock.Now().Add(invocationTTL).Unix())),
 	)
 	require.NoError(t, err)
 
@@ -144,6 +154,10 @@ func (s *UploadService) ConcludeHTTPPut(t *testing.T, space did.DID, digest mult
 }
 
 func NewService(t *testing.T, cfg Config) *UploadService {
+	if cfg.Clock == nil {
+		cfg.Clock = clock.NewSystemClock()
+	}
+
 	ch := uhttp.NewHTTPChannel(&cfg.StorageNodeURL)
 	conn, err := client.NewConnection(cfg.StorageNodeID, ch)
 	require.NoError(t, err)
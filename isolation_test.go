@@ -0,0 +1,140 @@
+package main
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/ipld/go-ipld-prime"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-capabilities/pkg/assert"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/testthenetwork/internal/claimassert"
+	"github.com/storacha/testthenetwork/internal/clock"
+	"github.com/storacha/testthenetwork/internal/digestutil"
+	"github.com/storacha/testthenetwork/internal/netstep"
+	"github.com/storacha/testthenetwork/internal/printer"
+	"github.com/storacha/testthenetwork/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCrossSpaceIndexClaims has mallory publish index claims for content alice
+// uploaded to her space, and asserts what queries for the content return for
+// each space filter. Whatever mallory claims, a query filtered by space must
+// never return location commitments for another space.
+func TestCrossSpaceIndexClaims(t *testing.T) {
+	logging.SetLogLevel("*", "warn")
+
+	t.Run("index claim for content never uploaded", func(t *testing.T) {
+		clk := clock.NewFakeClock(time.Now())
+		storageID, indexingID, uploadID, aliceID, malloryID := generateIdentities(t)
+		ipniFindURL, ipniAnnounceURL, storageURL, indexingURL := generateURLs(t)
+		storageIndexingProof, uploadStorageProof, aliceIndexingProof, malloryIndexingProof := generateProofs(t, storageID, indexingID, uploadID, aliceID, malloryID)
+		uploadService, indexingClient, _ := startServices(t, ipniFindURL, ipniAnnounceURL, storageID, storageURL, storageIndexingProof, indexingID, indexingURL, false, uploadID, uploadStorageProof, clk)
+
+		aliceSpace := testutil.RandomPrincipal(t).DID()
+		alice := uploadIndexedContent(t, uploadService, indexingClient, clk, aliceID, aliceIndexingProof, aliceSpace, 256, multihash.SHA2_256)
+
+		// mallory uploads nothing, but claims alice's index is hers too
+		mallorySpace := testutil.RandomPrincipal(t).DID()
+		netstep.PublishIndexClaim(t, indexingClient, clk, malloryID, malloryIndexingProof, alice.Root, alice.IndexLink)
+
+		for _, filter := range [][]did.DID{nil, {aliceSpace}} {
+			result := netstep.QueryClaims(t, indexingClient, []multihash.Multihash{alice.RootDigest}, filter...)
+			printer.PrintQueryResults(t, result)
+
+			require.Equal(t, []ipld.Link{alice.IndexLink}, result.Indexes())
+			claims := netstep.CollectClaims(t, result)
+			claimassert.Contains(t, claims, claimassert.Index(alice.Root, alice.IndexLink).Issuer(aliceID.DID()))
+			claimassert.Contains(t, claims, claimassert.Index(alice.Root, alice.IndexLink).Issuer(malloryID.DID()))
+			claimassert.Contains(t, claims, claimassert.Location(alice.Digest, aliceSpace))
+			claimassert.Contains(t, claims, claimassert.Location(alice.IndexDigest, aliceSpace))
+			claimassert.NotContains(t, claims, claimassert.Claim().OutsideSpaces(aliceSpace))
+			netstep.RequireVerifiedClaims(t, claims, indexingID, storageID, storageURL)
+		}
+
+		// nothing is stored in mallory's space, so her claim locates nothing
+		result := netstep.QueryClaims(t, indexingClient, []multihash.Multihash{alice.RootDigest}, mallorySpace)
+		printer.PrintQueryResults(t, result)
+
+		require.Empty(t, result.Indexes())
+		claims := netstep.CollectClaims(t, result)
+		claimassert.NotContains(t, claims, claimassert.Claim().Ability(assert.LocationAbility))
+	})
+
+	t.Run("index of shards owned by another space", func(t *testing.T) {
+		clk := clock.NewFakeClock(time.Now())
+		storageID, indexingID, uploadID, aliceID, malloryID := generateIdentities(t)
+		ipniFindURL, ipniAnnounceURL, storageURL, indexingURL := generateURLs(t)
+		storageIndexingProof, uploadStorageProof, aliceIndexingProof, malloryIndexingProof := generateProofs(t, storageID, indexingID, uploadID, aliceID, malloryID)
+		uploadService, indexingClient, _ := startServices(t, ipniFindURL, ipniAnnounceURL, storageID, storageURL, storageIndexingProof, indexingID, indexingURL, false, uploadID, uploadStorageProof, clk)
+
+		aliceSpace := testutil.RandomPrincipal(t).DID()
+		alice := uploadIndexedContent(t, uploadService, indexingClient, clk, aliceID, aliceIndexingProof, aliceSpace, 256, multihash.SHA2_256)
+
+		// mallory uploads a shard of her own and an index of alice's root that
+		// spans alice's shard and hers, then claims it is the index for the root
+		mallorySpace := testutil.RandomPrincipal(t).DID()
+		_, _, malloryShard, malloryData := generateContent(t, 128, multihash.SHA2_256)
+		address := uploadService.BlobAdd(t, mallorySpace, malloryShard, uint64(len(malloryData)))
+		require.NotNil(t, address)
+		netstep.PutBlob(t, address.URL, address.Headers, malloryData)
+		uploadService.ConcludeHTTPPut(t, mallorySpace, malloryShard, uint64(len(malloryData)))
+
+		index := indexShards(t, alice.Root, multihash.SHA2_256, alice.Data, malloryData)
+		indexData := testutil.Must(io.ReadAll(testutil.Must(index.Archive())(t)))(t)
+		indexDigest := testutil.Must(multihash.Sum(indexData, multihash.SHA2_256, -1))(t)
+		indexLink := digestutil.CARLink(indexDigest)
+		address = uploadService.BlobAdd(t, mallorySpace, indexDigest, uint64(len(indexData)))
+		require.NotNil(t, address)
+		netstep.PutBlob(t, address.URL, address.Headers, indexData)
+		uploadService.ConcludeHTTPPut(t, mallorySpace, indexDigest, uint64(len(indexData)))
+
+		netstep.PublishIndexClaim(t, indexingClient, clk, malloryID, malloryIndexingProof, alice.Root, indexLink)
+
+		aliceClaim := claimassert.Index(alice.Root, alice.IndexLink).Issuer(aliceID.DID())
+		malloryClaim := claimassert.Index(alice.Root, indexLink).Issuer(malloryID.DID())
+
+		// unfiltered, both indexes are found in the spaces they are stored in
+		result := netstep.QueryClaims(t, indexingClient, []multihash.Multihash{alice.RootDigest})
+		printer.PrintQueryResults(t, result)
+
+		require.ElementsMatch(t, []ipld.Link{alice.IndexLink, indexLink}, result.Indexes())
+		claims := netstep.CollectClaims(t, result)
+		claimassert.Contains(t, claims, aliceClaim)
+		claimassert.Contains(t, claims, malloryClaim)
+		claimassert.Contains(t, claims, claimassert.Location(alice.Digest, aliceSpace))
+		claimassert.Contains(t, claims, claimassert.Location(alice.IndexDigest, aliceSpace))
+		claimassert.Contains(t, claims, claimassert.Location(indexDigest, mallorySpace))
+		claimassert.NotContains(t, claims, claimassert.Claim().OutsideSpaces(aliceSpace, mallorySpace))
+		netstep.RequireVerifiedClaims(t, claims, indexingID, storageID, storageURL)
+
+		// filtered by alice's space, mallory's index is not found, though her
+		// index claim is still returned
+		result = netstep.QueryClaims(t, indexingClient, []multihash.Multihash{alice.RootDigest}, aliceSpace)
+		printer.PrintQueryResults(t, result)
+
+		require.Equal(t, []ipld.Link{alice.IndexLink}, result.Indexes())
+		claims = netstep.CollectClaims(t, result)
+		claimassert.Contains(t, claims, aliceClaim)
+		claimassert.Contains(t, claims, malloryClaim)
+		claimassert.Contains(t, claims, claimassert.Location(alice.Digest, aliceSpace))
+		claimassert.Contains(t, claims, claimassert.Location(alice.IndexDigest, aliceSpace))
+		claimassert.NotContains(t, claims, claimassert.Claim().OutsideSpaces(aliceSpace))
+
+		// filtered by mallory's space, her index is found but the location of
+		// alice's shard it points at is not
+		result = netstep.QueryClaims(t, indexingClient, []multihash.Multihash{alice.RootDigest}, mallorySpace)
+		printer.PrintQueryResults(t, result)
+
+		require.Equal(t, []ipld.Link{indexLink}, result.Indexes())
+		indexes := netstep.CollectIndexes(t, result)
+		require.True(t, indexes[0].Shards().Has(alice.Digest))
+		claims = netstep.CollectClaims(t, result)
+		claimassert.Contains(t, claims, malloryClaim)
+		claimassert.Contains(t, claims, claimassert.Location(indexDigest, mallorySpace))
+		claimassert.NotContains(t, claims, claimassert.Claim().Ability(assert.LocationAbility).Content(alice.Digest))
+		claimassert.NotContains(t, claims, claimassert.Claim().OutsideSpaces(mallorySpace))
+	})
+}
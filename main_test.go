@@ -1,168 +1,222 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
 	logging "github.com/ipfs/go-log/v2"
-	"github.com/storacha/go-ucanto/did"
-	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/codec/dagjson"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/indexing-service/pkg/redis"
+	"github.com/storacha/testthenetwork/internal/bootstrap"
+	"github.com/storacha/testthenetwork/internal/claimassert"
+	"github.com/storacha/testthenetwork/internal/clock"
+	"github.com/storacha/testthenetwork/internal/netstep"
 	"github.com/storacha/testthenetwork/internal/printer"
+	"github.com/storacha/testthenetwork/internal/report"
+	"github.com/storacha/testthenetwork/internal/snapshot"
+	"github.com/storacha/testthenetwork/internal/steps"
+	"github.com/storacha/testthenetwork/internal/suite"
 	"github.com/storacha/testthenetwork/internal/testutil"
 	"github.com/stretchr/testify/require"
 )
 
-func TestTheNetwork(t *testing.T) {
-	logging.SetLogLevel("*", "warn")
-
-	t.Run("round trip", func(t *testing.T) {
-		storageID, indexingID, uploadID, aliceID, bobID := generateIdentities(t)
-		ipniFindURL, ipniAnnounceURL, storageURL, indexingURL := generateURLs(t)
-		storageIndexingProof, uploadStorageProof, aliceIndexingProof, _ := generateProofs(t, storageID, indexingID, uploadID, aliceID, bobID)
-		uploadService, indexingClient := startServices(t, ipniFindURL, ipniAnnounceURL, storageID, storageURL, storageIndexingProof, indexingID, indexingURL, false, uploadID, uploadStorageProof)
-
-		space := testutil.RandomPrincipal(t).DID()
-		root, rootDigest, digest, data := generateContent(t, 256)
+var (
+	seedFlag    = flag.String("seed", "", "seed used to generate content and identities, random if not set (overrides $"+testutil.SeedEnv+")")
+	networkFlag = flag.String("network", "", "components the network is started from: in-process (default) or subprocess (overrides $"+bootstrap.NetworkEnv+")")
+)
 
-		address := uploadService.BlobAdd(t, space, digest, uint64(len(data)))
-		if address != nil {
-			putBlob(t, address.URL, address.Headers, data)
-		} else {
-			require.Fail(t, "http/put address was nil")
+func TestMain(m *testing.M) {
+	flag.Parse()
+	testutil.SetSeed(*seedFlag)
+	seed, err := testutil.Seed()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	fmt.Printf("seed: %d (set -seed=%d or %s=%d to reproduce)\n", seed, seed, testutil.SeedEnv, seed)
+	var network string
+	components, network, err = bootstrap.Select(*networkFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	fmt.Printf("network: %s\n", network)
+
+	code := m.Run()
+	if err := bootstrap.RemoveBuiltCommands(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to remove built commands: %s\n", err)
+	}
+	if err := report.Write("testthenetwork", steps.Completed()); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write reports: %s\n", err)
+		if code == 0 {
+			code = 1
 		}
-		claim := uploadService.ConcludeHTTPPut(t, space, digest, uint64(len(data)))
-
-		nb := decodeLocationCommitmentCaveats(t, claim)
-
-		blobBytes, blobDigest := fetchBlob(t, nb.Location[0])
-		require.Equal(t, digest, blobDigest)
-
-		_, indexDigest, indexLink, indexData := generateIndex(t, root, blobBytes)
-
-		address = uploadService.BlobAdd(t, space, indexDigest, uint64(len(indexData)))
-		require.NotNil(t, address)
-		putBlob(t, address.URL, address.Headers, indexData)
-		uploadService.ConcludeHTTPPut(t, space, indexDigest, uint64(len(indexData)))
-
-		publishIndexClaim(t, indexingClient, aliceID, aliceIndexingProof, root, indexLink)
+	} else {
+		fmt.Printf("reports written to %s\n", report.Dir())
+	}
+	os.Exit(code)
+}
 
-		result := QueryClaims(t, indexingClient, rootDigest, did.Undef)
-		printer.PrintQueryResults(t, result)
+func TestTheNetwork(t *testing.T) {
+	logging.SetLogLevel("*", "warn")
 
-		indexes := CollectIndexes(t, result)
-		require.Len(t, indexes, 1)
-		require.Equal(t, indexLink, result.Indexes()[0]) // should be the index we generated
+	// The round trip and space filter scenarios are those of the conformance
+	// suites, checked further here with what the tests can see of the network.
 
-		claims := CollectClaims(t, result)
-		require.True(t, ContainsIndexClaim(t, claims, root, indexLink))            // find an index claim for our root
-		require.True(t, ContainsLocationCommitment(t, claims, indexDigest, space)) // find a location commitment for the index
-		require.True(t, ContainsLocationCommitment(t, claims, blobDigest, space))  // find a location commitment for the shard
+	t.Run("round trip", func(t *testing.T) {
+		clk := clock.NewFakeClock(time.Now())
+		n, services := startSuiteNetwork(t, clk, false)
+		caches := services.Caches
+
+		out := suite.RoundTrip(t, n)
+		result, content := out.Result, out.Content
+		writeGraphArtifacts(t, result)
+		claims := netstep.CollectClaims(t, result)
+
+		// nothing else should have been returned
+		names := snapshotNames(n.StorageID, n.IndexingID, n.StorageURL, out.Space, content.Root, content.Digest, content.IndexLink)
+		matchQueryResultsSnapshot(t, result, clk, append(names, snapshot.WithName(n.Alice.DID(), "alice"))...)
+
+		// machine readable output should describe the same claims and indexes
+		var buf bytes.Buffer
+		printer.WriteQueryResults(t, &buf, printer.FormatDAGJSON, result)
+		doc, err := ipld.Decode(buf.Bytes(), dagjson.Decode)
+		require.NoError(t, err)
+		docClaims := testutil.Must(doc.LookupByString("claims"))(t)
+		require.Equal(t, int64(len(result.Claims())), docClaims.Length())
+		docIndex := testutil.Must(testutil.Must(doc.LookupByString("indexes"))(t).LookupByIndex(0))(t)
+		require.Equal(t, content.IndexLink, testutil.Must(testutil.Must(docIndex.LookupByString("link"))(t).AsLink())(t))
+
+		buf.Reset()
+		printer.WriteQueryResults(t, &buf, printer.FormatJSON, result)
+		var jsonDoc struct {
+			Claims []struct {
+				Link         string `json:"link"`
+				Capabilities []struct {
+					Can string `json:"can"`
+				} `json:"capabilities"`
+			} `json:"claims"`
+			Indexes []struct {
+				Content string `json:"content"`
+			} `json:"indexes"`
+		}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &jsonDoc))
+		require.Len(t, jsonDoc.Claims, len(claims))
+		require.Len(t, jsonDoc.Indexes, 1)
+		require.Equal(t, content.Root.String(), jsonDoc.Indexes[0].Content)
+
+		// the graph should link the root to the index via the index claim, and
+		// the index to the shard
+		graph := string(printer.QueryResultsGraph(t, printer.GraphMermaid, result))
+		require.True(t, strings.HasPrefix(graph, "flowchart LR\n"))
+		require.Contains(t, graph, "-->|index|")
+		require.Contains(t, graph, "-->|shard|")
+		require.Contains(t, graph, "-->|location|")
+
+		// everything returned should now be cached by the indexing service
+		for _, claim := range claims {
+			cached, err := caches.Claims.Get(claim.Link())
+			require.NoError(t, err)
+			require.Equal(t, claim.Link(), cached.Link())
+			ttl, ok := caches.Claims.ExpiresIn(claim.Link())
+			require.True(t, ok)
+			require.Equal(t, redis.DefaultExpire, ttl) // clock is frozen
+		}
+		cachedIndexes, err := caches.Indexes.All()
+		require.NoError(t, err)
+		require.Len(t, cachedIndexes, 1)
+		require.Equal(t, content.Root, cachedIndexes[0].Content())
+		providers, err := caches.Providers.Get(content.RootDigest)
+		require.NoError(t, err)
+		require.NotEmpty(t, providers)
+
+		// a repeated query should be served entirely from the cache
+		caches.ResetRecorders()
+		repeat := netstep.QueryClaims(t, n.IndexingClient, []multihash.Multihash{content.RootDigest})
+		require.ElementsMatch(t, result.Claims(), repeat.Claims())
+		require.ElementsMatch(t, result.Indexes(), repeat.Indexes())
+		stats := caches.Stats()
+		printer.PrintCacheStats(stats...)
+		for _, s := range stats {
+			require.Zero(t, s.Misses, "%s cache misses", s.Name)
+			require.Zero(t, s.Errors, "%s cache errors", s.Name)
+		}
+		require.NotZero(t, caches.ProvidersRecorder.Stats().Hits)
+		require.NotZero(t, caches.ClaimsRecorder.Stats().Hits)
+		require.NotZero(t, caches.IndexesRecorder.Stats().Hits)
 	})
 
 	t.Run("round trip (no cache)", func(t *testing.T) {
-		storageID, indexingID, uploadID, aliceID, bobID := generateIdentities(t)
-		ipniFindURL, ipniAnnounceURL, storageURL, indexingURL := generateURLs(t)
-		storageIndexingProof, uploadStorageProof, aliceIndexingProof, _ := generateProofs(t, storageID, indexingID, uploadID, aliceID, bobID)
-		uploadService, indexingClient := startServices(t, ipniFindURL, ipniAnnounceURL, storageID, storageURL, storageIndexingProof, indexingID, indexingURL, true, uploadID, uploadStorageProof)
+		clk := clock.NewFakeClock(time.Now())
+		n, _ := startSuiteNetwork(t, clk, true)
 
-		space := testutil.RandomPrincipal(t).DID()
-		root, rootDigest, digest, data := generateContent(t, 256)
-
-		address := uploadService.BlobAdd(t, space, digest, uint64(len(data)))
-		require.NotNil(t, address)
-		putBlob(t, address.URL, address.Headers, data)
-		claim := uploadService.ConcludeHTTPPut(t, space, digest, uint64(len(data)))
-
-		nb := decodeLocationCommitmentCaveats(t, claim)
-
-		blobBytes, blobDigest := fetchBlob(t, nb.Location[0])
-		require.Equal(t, digest, blobDigest)
+		out := suite.RoundTrip(t, n)
+		writeGraphArtifacts(t, out.Result)
 
-		_, indexDigest, indexLink, indexData := generateIndex(t, root, blobBytes)
+		names := snapshotNames(n.StorageID, n.IndexingID, n.StorageURL, out.Space, out.Content.Root, out.Content.Digest, out.Content.IndexLink)
+		matchQueryResultsSnapshot(t, out.Result, clk, append(names, snapshot.WithName(n.Alice.DID(), "alice"))...)
+	})
 
-		address = uploadService.BlobAdd(t, space, indexDigest, uint64(len(indexData)))
-		if address != nil {
-			putBlob(t, address.URL, address.Headers, indexData)
-		} else {
-			require.Fail(t, "http/put address was nil")
-		}
-		uploadService.ConcludeHTTPPut(t, space, indexDigest, uint64(len(indexData)))
-
-		publishIndexClaim(t, indexingClient, aliceID, aliceIndexingProof, root, indexLink)
-
-		var result types.QueryResult
-		for i := 0; i < 5; i++ {
-			result = QueryClaims(t, indexingClient, rootDigest, did.Undef)
-			if len(result.Claims()) > 0 || len(result.Indexes()) > 0 {
-				break
-			}
-			// no local cache so we have to wait for IPNI to crawl to the head
-			fmt.Printf("→ waiting for IPNI sync %d/5\n", i+1)
-			time.Sleep(time.Second)
-		}
-		printer.PrintQueryResults(t, result)
+	t.Run("filter by space", func(t *testing.T) {
+		clk := clock.NewFakeClock(time.Now())
+		n, _ := startSuiteNetwork(t, clk, false)
 
-		indexes := CollectIndexes(t, result)
-		require.Len(t, indexes, 1)
-		require.Equal(t, indexLink, result.Indexes()[0]) // should be the index we generated
+		out := suite.FilterBySpace(t, n)
+		writeGraphArtifacts(t, out.Result)
 
-		claims := CollectClaims(t, result)
-		require.True(t, ContainsIndexClaim(t, claims, root, indexLink))            // find an index claim for our root
-		require.True(t, ContainsLocationCommitment(t, claims, indexDigest, space)) // find a location commitment for the index
-		require.True(t, ContainsLocationCommitment(t, claims, blobDigest, space))  // find a location commitment for the shard
+		names := snapshotNames(n.StorageID, n.IndexingID, n.StorageURL, out.Space, out.Content.Root, out.Content.Digest, out.Content.IndexLink)
+		names = append(names, snapshot.WithName(n.Alice.DID(), "alice"), snapshot.WithName(n.Bob.DID(), "bob"), snapshot.WithName(out.OtherSpace, "alice-space"))
+		matchQueryResultsSnapshot(t, out.Result, clk, names...)
 	})
 
-	t.Run("filter by space", func(t *testing.T) {
+	t.Run("round trip (expired cache)", func(t *testing.T) {
+		clk := clock.NewFakeClock(time.Now())
 		storageID, indexingID, uploadID, aliceID, bobID := generateIdentities(t)
 		ipniFindURL, ipniAnnounceURL, storageURL, indexingURL := generateURLs(t)
-		storageIndexingProof, uploadStorageProof, aliceIndexingProof, bobIndexingProof := generateProofs(t, storageID, indexingID, uploadID, aliceID, bobID)
-		uploadService, indexingClient := startServices(t, ipniFindURL, ipniAnnounceURL, storageID, storageURL, storageIndexingProof, indexingID, indexingURL, false, uploadID, uploadStorageProof)
-
-		aliceSpace := testutil.RandomPrincipal(t).DID()
-		root, rootDigest, digest, data := generateContent(t, 256)
-
-		address := uploadService.BlobAdd(t, aliceSpace, digest, uint64(len(data)))
-		if address != nil {
-			putBlob(t, address.URL, address.Headers, data)
-		}
-		uploadService.ConcludeHTTPPut(t, aliceSpace, digest, uint64(len(data)))
-
-		_, indexDigest, indexLink, indexData := generateIndex(t, root, data)
-
-		address = uploadService.BlobAdd(t, aliceSpace, indexDigest, uint64(len(indexData)))
-		if address != nil {
-			putBlob(t, address.URL, address.Headers, indexData)
-		}
-		uploadService.ConcludeHTTPPut(t, aliceSpace, indexDigest, uint64(len(indexData)))
-
-		publishIndexClaim(t, indexingClient, aliceID, aliceIndexingProof, root, indexLink)
-
-		// bob will attempt to upload the same blob
-		bobSpace := testutil.RandomPrincipal(t).DID()
-
-		address = uploadService.BlobAdd(t, bobSpace, digest, uint64(len(data)))
-		require.Nil(t, address) // address should be nil since it is already uploaded
-		uploadService.ConcludeHTTPPut(t, bobSpace, digest, uint64(len(data)))
-
-		address = uploadService.BlobAdd(t, bobSpace, indexDigest, uint64(len(indexData)))
-		require.Nil(t, address) // address should be nil since it is already uploaded
-		uploadService.ConcludeHTTPPut(t, bobSpace, indexDigest, uint64(len(indexData)))
-
-		publishIndexClaim(t, indexingClient, bobID, bobIndexingProof, root, indexLink)
+		storageIndexingProof, uploadStorageProof, aliceIndexingProof, _ := generateProofs(t, storageID, indexingID, uploadID, aliceID, bobID)
+		uploadService, indexingClient, caches := startServices(t, ipniFindURL, ipniAnnounceURL, storageID, storageURL, storageIndexingProof, indexingID, indexingURL, false, uploadID, uploadStorageProof, clk)
 
-		result := QueryClaims(t, indexingClient, rootDigest, bobSpace)
+		space := testutil.RandomPrincipal(t).DID()
+		content := uploadIndexedContent(t, uploadService, indexingClient, clk, aliceID, aliceIndexingProof, space, 256, multihash.SHA2_256)
+
+		result := netstep.QueryClaims(t, indexingClient, []multihash.Multihash{content.RootDigest})
+		require.Len(t, result.Indexes(), 1)
+		_, ok := caches.Providers.ExpiresIn(content.IndexDigest)
+		require.True(t, ok)
+
+		require.NotEmpty(t, testutil.Must(caches.Claims.All())(t))
+		require.NotEmpty(t, caches.Indexes.ContextIDs())
+
+		// move past the expiry of everything cached by the indexing service
+		steps.Note(t, "advance clock", steps.A("by", 2*redis.DefaultExpire))
+		clk.Advance(2 * redis.DefaultExpire)
+		_, ok = caches.Providers.ExpiresIn(content.IndexDigest)
+		require.False(t, ok)
+		require.Empty(t, testutil.Must(caches.Claims.All())(t))
+		require.Empty(t, caches.Indexes.ContextIDs())
+		caches.ResetRecorders()
+
+		// Known upstream bug in the indexing service: like Redis, the cache
+		// returns an empty set for the expired providers of the index, which the
+		// service's provider index takes to mean there are none rather than a
+		// cache miss (it only treats goredis.Nil as a miss), so it does not go
+		// back to IPNI to find the index. If this starts failing the service has
+		// been fixed and the result should contain the index again.
+		result = netstep.QueryClaims(t, indexingClient, []multihash.Multihash{content.RootDigest})
 		printer.PrintQueryResults(t, result)
-
-		indexes := CollectIndexes(t, result)
-		require.Len(t, indexes, 1)
-		require.Equal(t, indexLink, result.Indexes()[0]) // should be the index we generated
-
-		claims := CollectClaims(t, result)
-		require.True(t, ContainsIndexClaim(t, claims, root, indexLink))               // find an index claim for our root
-		require.True(t, ContainsLocationCommitment(t, claims, indexDigest, bobSpace)) // find a location commitment for the index
-		require.True(t, ContainsLocationCommitment(t, claims, digest, bobSpace))      // find a location commitment for the shard
-		require.False(t, ContainsLocationCommitment(t, claims, indexDigest, aliceSpace))
-		require.False(t, ContainsLocationCommitment(t, claims, digest, aliceSpace))
+		claims := netstep.CollectClaims(t, result)
+		claimassert.Contains(t, claims, claimassert.Index(content.Root, content.IndexLink))
+		require.Empty(t, result.Indexes())
+		// the expired providers were read as an empty set, which is recorded as
+		// a miss
+		require.Positive(t, caches.ProvidersRecorder.Stats().Misses)
 	})
 }
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/ipld/go-ipld-prime"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-capabilities/pkg/assert"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/indexing-service/pkg/client"
+	"github.com/storacha/testthenetwork/internal/claimassert"
+	"github.com/storacha/testthenetwork/internal/clock"
+	"github.com/storacha/testthenetwork/internal/netstep"
+	"github.com/storacha/testthenetwork/internal/printer"
+	"github.com/storacha/testthenetwork/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// TestQueries queries for content by each of the digests clients query by in
+// production, asserting which claims and indexes each returns.
+func TestQueries(t *testing.T) {
+	logging.SetLogLevel("*", "warn")
+
+	type uploaded struct {
+		indexingClient *client.Client
+		// alice uploads content of several blocks to her space
+		alice      indexedContent
+		aliceSpace did.DID
+		slices     []multihash.Multihash
+		// bob uploads content of a single block to his space
+		bob      indexedContent
+		bobSpace did.DID
+	}
+
+	setup := func(t *testing.T) uploaded {
+		clk := clock.NewFakeClock(time.Now())
+		storageID, indexingID, uploadID, aliceID, bobID := generateIdentities(t)
+		ipniFindURL, ipniAnnounceURL, storageURL, indexingURL := generateURLs(t)
+		storageIndexingProof, uploadStorageProof, aliceIndexingProof, bobIndexingProof := generateProofs(t, storageID, indexingID, uploadID, aliceID, bobID)
+		uploadService, indexingClient, _ := startServices(t, ipniFindURL, ipniAnnounceURL, storageID, storageURL, storageIndexingProof, indexingID, indexingURL, false, uploadID, uploadStorageProof, clk)
+
+		aliceSpace := testutil.RandomPrincipal(t).DID()
+		root, slices, _, data := testutil.RandomMultiBlockCAR(t, 3, 128, multihash.SHA2_256)
+		alice := uploadIndexedCAR(t, uploadService, indexingClient, clk, aliceID, aliceIndexingProof, aliceSpace, root, slices[0], data, multihash.SHA2_256)
+
+		bobSpace := testutil.RandomPrincipal(t).DID()
+		bob := uploadIndexedContent(t, uploadService, indexingClient, clk, bobID, bobIndexingProof, bobSpace, 256, multihash.SHA2_256)
+
+		return uploaded{indexingClient, alice, aliceSpace, slices, bob, bobSpace}
+	}
+
+	t.Run("by slice", func(t *testing.T) {
+		u := setup(t)
+
+		// a block other than the root finds the index for the DAG it is in
+		result := netstep.QueryClaims(t, u.indexingClient, []multihash.Multihash{u.slices[1]})
+		printer.PrintQueryResults(t, result)
+
+		indexes := netstep.CollectIndexes(t, result)
+		require.Len(t, indexes, 1)
+		require.Equal(t, u.alice.IndexLink, result.Indexes()[0])
+		require.True(t, indexes[0].Shards().Has(u.alice.Digest))
+		require.True(t, indexes[0].Shards().Get(u.alice.Digest).Has(u.slices[1]))
+
+		claims := netstep.CollectClaims(t, result)
+		require.Len(t, claims, 3)
+		claimassert.Contains(t, claims, claimassert.Index(u.alice.Root, u.alice.IndexLink))
+		claimassert.Contains(t, claims, claimassert.Location(u.alice.IndexDigest, u.aliceSpace))
+		claimassert.Contains(t, claims, claimassert.Location(u.alice.Digest, u.aliceSpace))
+	})
+
+	t.Run("by shard", func(t *testing.T) {
+		u := setup(t)
+
+		// the CAR is a blob, so only its location is known
+		result := netstep.QueryClaims(t, u.indexingClient, []multihash.Multihash{u.alice.Digest})
+		printer.PrintQueryResults(t, result)
+
+		require.Empty(t, result.Indexes())
+		claims := netstep.CollectClaims(t, result)
+		require.Len(t, claims, 1)
+		claimassert.Contains(t, claims, claimassert.Location(u.alice.Digest, u.aliceSpace))
+	})
+
+	t.Run("by index", func(t *testing.T) {
+		u := setup(t)
+
+		// the index is a blob too, and is not itself indexed
+		result := netstep.QueryClaims(t, u.indexingClient, []multihash.Multihash{u.alice.IndexDigest})
+		printer.PrintQueryResults(t, result)
+
+		require.Empty(t, result.Indexes())
+		claims := netstep.CollectClaims(t, result)
+		require.Len(t, claims, 1)
+		claimassert.Contains(t, claims, claimassert.Location(u.alice.IndexDigest, u.aliceSpace))
+		claimassert.NotContains(t, claims, claimassert.Claim().Ability(assert.IndexAbility))
+	})
+
+	t.Run("multiple hashes", func(t *testing.T) {
+		u := setup(t)
+
+		result := netstep.QueryClaims(t, u.indexingClient, []multihash.Multihash{u.alice.RootDigest, u.bob.RootDigest})
+		printer.PrintQueryResults(t, result)
+
+		require.ElementsMatch(t, []ipld.Link{u.alice.IndexLink, u.bob.IndexLink}, result.Indexes())
+		claims := netstep.CollectClaims(t, result)
+		require.Len(t, claims, 6)
+		for _, c := range []struct {
+			content indexedContent
+			space   did.DID
+		}{{u.alice, u.aliceSpace}, {u.bob, u.bobSpace}} {
+			claimassert.Contains(t, claims, claimassert.Index(c.content.Root, c.content.IndexLink))
+			claimassert.Contains(t, claims, claimassert.Location(c.content.IndexDigest, c.space))
+			claimassert.Contains(t, claims, claimassert.Location(c.content.Digest, c.space))
+		}
+	})
+
+	t.Run("multiple spaces", func(t *testing.T) {
+		u := setup(t)
+		hashes := []multihash.Multihash{u.alice.RootDigest, u.bob.RootDigest}
+
+		// location commitments in any of the spaces match
+		result := netstep.QueryClaims(t, u.indexingClient, hashes, u.aliceSpace, u.bobSpace)
+		printer.PrintQueryResults(t, result)
+		require.Len(t, result.Indexes(), 2)
+		claims := netstep.CollectClaims(t, result)
+		claimassert.Contains(t, claims, claimassert.Location(u.alice.Digest, u.aliceSpace))
+		claimassert.Contains(t, claims, claimassert.Location(u.bob.Digest, u.bobSpace))
+
+		// and those in other spaces are filtered out
+		otherSpace := testutil.RandomPrincipal(t).DID()
+		result = netstep.QueryClaims(t, u.indexingClient, hashes, u.aliceSpace, otherSpace)
+		printer.PrintQueryResults(t, result)
+		claims = netstep.CollectClaims(t, result)
+		claimassert.Contains(t, claims, claimassert.Location(u.alice.Digest, u.aliceSpace))
+		claimassert.NotContains(t, claims, claimassert.Claim().Space(u.bobSpace))
+	})
+}
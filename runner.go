@@ -0,0 +1,259 @@
+package main
+
+import (
+	"io"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-capabilities/pkg/blob"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/principal"
+	"github.com/storacha/indexing-service/pkg/client"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/storacha/testthenetwork/internal/claimassert"
+	"github.com/storacha/testthenetwork/internal/clock"
+	"github.com/storacha/testthenetwork/internal/digestutil"
+	"github.com/storacha/testthenetwork/internal/netstep"
+	"github.com/storacha/testthenetwork/internal/printer"
+	"github.com/storacha/testthenetwork/internal/scenario"
+	"github.com/storacha/testthenetwork/internal/steps"
+	"github.com/storacha/testthenetwork/internal/testutil"
+	"github.com/storacha/testthenetwork/internal/upload"
+	"github.com/stretchr/testify/require"
+)
+
+// scenarioRunner runs the steps of a scenario against a network of its own,
+// keeping what each step creates by the name the scenario gives it.
+type scenarioRunner struct {
+	t              *testing.T
+	clk            clock.Clock
+	storageID      principal.Signer
+	storageURL     url.URL
+	indexingID     principal.Signer
+	uploadService  *upload.UploadService
+	indexingClient *client.Client
+
+	spaces    map[string]did.DID
+	issuers   map[string]scenarioIssuer
+	contents  map[string]scenarioContent
+	blobs     map[string]scenarioBlob
+	addresses map[[2]string]*blob.Address
+	results   map[string]types.QueryResult
+}
+
+type scenarioIssuer struct {
+	id    principal.Signer
+	proof delegation.Proof
+}
+
+type scenarioBlob struct {
+	data []byte
+	hash uint64
+}
+
+type scenarioContent struct {
+	root       ipld.Link
+	rootDigest multihash.Multihash
+	hash       uint64
+}
+
+// runScenario starts a network and runs the steps of the scenario against it.
+func runScenario(t *testing.T, s *scenario.Scenario) {
+	clk := clock.NewFakeClock(time.Now())
+	storageID, indexingID, uploadID, _, _ := generateIdentities(t)
+	ipniFindURL, ipniAnnounceURL, storageURL, indexingURL := generateURLs(t)
+	storageIndexingProof, uploadStorageProof, _, _ := generateProofs(t, storageID, indexingID, uploadID, testutil.RandomPrincipal(t), testutil.RandomPrincipal(t))
+	uploadService, indexingClient, _ := startServices(t, ipniFindURL, ipniAnnounceURL, storageID, storageURL, storageIndexingProof, indexingID, indexingURL, s.NoCache, uploadID, uploadStorageProof, clk)
+
+	r := &scenarioRunner{
+		t:              t,
+		clk:            clk,
+		storageID:      storageID,
+		storageURL:     storageURL,
+		indexingID:     indexingID,
+		uploadService:  uploadService,
+		indexingClient: indexingClient,
+		spaces:         map[string]did.DID{},
+		issuers:        map[string]scenarioIssuer{},
+		contents:       map[string]scenarioContent{},
+		blobs:          map[string]scenarioBlob{},
+		addresses:      map[[2]string]*blob.Address{},
+		results:        map[string]types.QueryResult{},
+	}
+	for i, step := range s.Steps {
+		steps.Note(t, "scenario step", steps.A("step", i+1), steps.A("kind", step.Kind()))
+		r.run(step)
+	}
+}
+
+func (r *scenarioRunner) run(s scenario.Step) {
+	t := r.t
+	switch {
+	case s.Generate != nil:
+		hash := testutil.Must(scenario.HashCode(s.Generate.Hash))(t)
+		root, rootDigest, _, data := generateContent(t, s.Generate.Size, hash)
+		r.contents[s.Generate.Name] = scenarioContent{root, rootDigest, hash}
+		r.blobs[s.Generate.Name] = scenarioBlob{data, hash}
+	case s.Index != nil:
+		content := r.contents[s.Index.Content]
+		var shards [][]byte
+		for _, shard := range s.Index.Shards {
+			shards = append(shards, r.blobs[shard].data)
+		}
+		index := indexShards(t, content.root, content.hash, shards...)
+		data := testutil.Must(io.ReadAll(testutil.Must(index.Archive())(t)))(t)
+		r.blobs[s.Index.Name] = scenarioBlob{data, content.hash}
+	case s.BlobAdd != nil:
+		r.blobAdd(s.BlobAdd)
+	case s.Put != nil:
+		r.put(s.Put)
+	case s.Conclude != nil:
+		r.conclude(s.Conclude)
+	case s.Upload != nil:
+		r.blobAdd(s.Upload)
+		if r.addresses[[2]string{s.Upload.Space, s.Upload.Blob}] != nil {
+			r.put(s.Upload)
+		} else {
+			steps.Note(t, "blob already stored", steps.A("blob", s.Upload.Blob))
+		}
+		r.conclude(s.Upload)
+	case s.PublishIndex != nil:
+		issuer := r.issuer(s.PublishIndex.Issuer)
+		root := r.contents[s.PublishIndex.Content].root
+		netstep.PublishIndexClaim(t, r.indexingClient, r.clk, issuer.id, issuer.proof, root, r.blobLink(s.PublishIndex.Index))
+	case s.Query != nil:
+		var digests []multihash.Multihash
+		for _, ref := range s.Query.Digests {
+			digests = append(digests, r.digest(ref))
+		}
+		var spaces []did.DID
+		for _, name := range s.Query.Spaces {
+			spaces = append(spaces, r.space(name))
+		}
+		var result types.QueryResult
+		if s.Query.Await {
+			result = netstep.AwaitQueryClaims(t, r.indexingClient, digests, spaces...)
+		} else {
+			result = netstep.QueryClaims(t, r.indexingClient, digests, spaces...)
+		}
+		printer.PrintQueryResults(t, result)
+		r.results[s.Query.Name] = result
+	case s.Expect != nil:
+		r.expect(s.Expect)
+	}
+}
+
+func (r *scenarioRunner) blobAdd(b *scenario.Blob) {
+	data := r.blobs[b.Blob].data
+	address := r.uploadService.BlobAdd(r.t, r.space(b.Space), r.digest(b.Blob), uint64(len(data)))
+	r.addresses[[2]string{b.Space, b.Blob}] = address
+}
+
+func (r *scenarioRunner) put(b *scenario.Blob) {
+	address, ok := r.addresses[[2]string{b.Space, b.Blob}]
+	require.True(r.t, ok, "blob %q was not added to space %q", b.Blob, b.Space)
+	require.NotNil(r.t, address, "no address was allocated for blob %q in space %q, it may already be stored", b.Blob, b.Space)
+	netstep.PutBlob(r.t, address.URL, address.Headers, r.blobs[b.Blob].data)
+}
+
+func (r *scenarioRunner) conclude(b *scenario.Blob) {
+	r.uploadService.ConcludeHTTPPut(r.t, r.space(b.Space), r.digest(b.Blob), uint64(len(r.blobs[b.Blob].data)))
+}
+
+func (r *scenarioRunner) expect(e *scenario.Expect) {
+	t := r.t
+	step := steps.Start(t, "expect", steps.A("query", e.Query))
+	defer step.Done()
+	result := r.results[e.Query]
+
+	if e.Indexes != nil {
+		want := []ipld.Link{}
+		for _, index := range e.Indexes {
+			want = append(want, r.blobLink(index))
+		}
+		got := append([]ipld.Link{}, result.Indexes()...)
+		require.ElementsMatch(t, want, got, "indexes of %s", e.Query)
+	}
+	claims := netstep.CollectClaims(t, result)
+	if e.Claims != nil {
+		require.Len(t, claims, *e.Claims, "claims of %s", e.Query)
+	}
+	for _, c := range e.Contains {
+		claimassert.Contains(t, claims, r.matcher(c))
+	}
+	for _, c := range e.NotContains {
+		claimassert.NotContains(t, claims, r.matcher(c))
+	}
+	netstep.RequireVerifiedClaims(t, claims, r.indexingID, r.storageID, r.storageURL)
+}
+
+func (r *scenarioRunner) matcher(c scenario.Claim) *claimassert.Matcher {
+	m := claimassert.Claim()
+	if c.Ability != "" {
+		m = m.Ability(c.Ability)
+	}
+	if c.Issuer != "" {
+		m = m.Issuer(r.issuer(c.Issuer).id.DID())
+	}
+	if c.Content != "" {
+		m = m.Content(r.digest(c.Content))
+	}
+	if c.Space != "" {
+		m = m.Space(r.space(c.Space))
+	}
+	if c.OutsideSpaces != nil {
+		var spaces []did.DID
+		for _, name := range c.OutsideSpaces {
+			spaces = append(spaces, r.space(name))
+		}
+		m = m.OutsideSpaces(spaces...)
+	}
+	if c.Index != "" {
+		m = m.Index(r.blobLink(c.Index))
+	}
+	return m
+}
+
+// space returns the DID of the named space, creating it if it is new.
+func (r *scenarioRunner) space(name string) did.DID {
+	space, ok := r.spaces[name]
+	if !ok {
+		space = testutil.RandomPrincipal(r.t).DID()
+		r.spaces[name] = space
+		steps.Note(r.t, "new space", steps.A("name", name), steps.A("did", space))
+	}
+	return space
+}
+
+// issuer returns the named issuer, creating it with a proof it can publish
+// claims to the indexing service with if it is new.
+func (r *scenarioRunner) issuer(name string) scenarioIssuer {
+	issuer, ok := r.issuers[name]
+	if !ok {
+		id := testutil.RandomSigner(r.t)
+		issuer = scenarioIssuer{id, indexingProof(r.t, r.indexingID, id)}
+		r.issuers[name] = issuer
+		steps.Note(r.t, "new issuer", steps.A("name", name), steps.A("did", id.DID()))
+	}
+	return issuer
+}
+
+// digest returns the digest a reference refers to: the digest of a blob, or
+// the root digest of content.
+func (r *scenarioRunner) digest(ref string) multihash.Multihash {
+	name, part := scenario.SplitRef(ref)
+	if part == "root" {
+		return r.contents[name].rootDigest
+	}
+	b := r.blobs[name]
+	return testutil.Must(multihash.Sum(b.data, b.hash, -1))(r.t)
+}
+
+// blobLink returns a CAR link to the named blob, such as an index.
+func (r *scenarioRunner) blobLink(name string) ipld.Link {
+	return digestutil.CARLink(r.digest(name))
+}
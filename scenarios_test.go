@@ -0,0 +1,28 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/storacha/testthenetwork/internal/scenario"
+	"github.com/stretchr/testify/require"
+)
+
+// TestScenarios runs each scenario in testdata/scenarios against a network of
+// its own.
+func TestScenarios(t *testing.T) {
+	logging.SetLogLevel("*", "warn")
+
+	paths, err := filepath.Glob(filepath.Join("testdata", "scenarios", "*.yaml"))
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+
+	for _, path := range paths {
+		s, err := scenario.Load(path)
+		require.NoError(t, err)
+		t.Run(s.Name, func(t *testing.T) {
+			runScenario(t, s)
+		})
+	}
+}
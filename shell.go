@@ -0,0 +1,394 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-capabilities/pkg/assert"
+	"github.com/storacha/go-ucanto/core/car"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/core/ipld/block"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/principal"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/storacha/testthenetwork/internal/bootstrap"
+	"github.com/storacha/testthenetwork/internal/claimassert"
+	"github.com/storacha/testthenetwork/internal/clock"
+	"github.com/storacha/testthenetwork/internal/digestutil"
+	"github.com/storacha/testthenetwork/internal/netstep"
+	"github.com/storacha/testthenetwork/internal/printer"
+	"github.com/storacha/testthenetwork/internal/testutil"
+)
+
+const shellHelp = `commands:
+  space new [name]              create a space and use it
+  space use <name>              use a space created earlier
+  space                         list spaces
+  upload <file>                 upload a file, or a CAR as is, and its index to the space
+  publish-index [root]          publish an index claim for the last upload, or the upload of root
+  query <cid> [--space <name>]  query claims for a CID or digest, filtered by the spaces
+  fetch <cid> [file]            fetch a blob from the location it is committed to
+  restart <storage|indexing|ipni>
+                                stop and start a service, keeping its data
+  print [format]                print the last query results as text, json, dag-json, mermaid or dot
+  help                          print this help
+  exit                          stop the network and exit
+`
+
+// shellUpload is a file uploaded to a space along with its index.
+type shellUpload struct {
+	root      ipld.Link
+	indexLink ipld.Link
+}
+
+// shell runs commands against a network, such as uploading a file to a space
+// and querying for its claims, so the network can be explored without writing
+// a test. Each command runs as a subtest, so a command that fails does not
+// stop the shell.
+type shell struct {
+	out io.Writer
+	clk clock.Clock
+	net *network
+	// agent publishes index claims.
+	agent      principal.Signer
+	agentProof delegation.Proof
+
+	spaces  map[string]did.DID
+	space   string
+	uploads []shellUpload
+	result  *types.QueryResult
+}
+
+// runShell starts a network and runs the commands read from in against it
+// until in is exhausted or the exit command is read.
+func runShell(t *testing.T, in io.Reader, out io.Writer) {
+	clk := clock.NewSystemClock()
+	storageID, indexingID, uploadID, agentID, _ := generateIdentities(t)
+	ipniFindURL, ipniAnnounceURL, storageURL, indexingURL := generateURLs(t)
+	storageIndexingProof, uploadStorageProof, agentIndexingProof, _ := generateProofs(t, storageID, indexingID, uploadID, agentID, testutil.RandomPrincipal(t))
+	n := startNetwork(t, ipniFindURL, ipniAnnounceURL, storageID, storageURL, storageIndexingProof, indexingID, indexingURL, false, uploadID, uploadStorageProof, clk)
+
+	s := &shell{
+		out:        out,
+		clk:        clk,
+		net:        n,
+		agent:      agentID,
+		agentProof: agentIndexingProof,
+		spaces:     map[string]did.DID{},
+	}
+	fmt.Fprintf(out, "storage node %s at %s\n", storageID.DID(), storageURL.String())
+	fmt.Fprintf(out, "indexing service %s at %s\n", indexingID.DID(), indexingURL.String())
+	fmt.Fprintf(out, "IPNI at %s\n", ipniFindURL.String())
+	fmt.Fprintln(out, `type "help" for commands`)
+
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return
+		}
+		args := strings.Fields(scanner.Text())
+		if len(args) == 0 || strings.HasPrefix(args[0], "#") {
+			continue
+		}
+		if args[0] == "exit" || args[0] == "quit" {
+			return
+		}
+		s.run(t, args)
+	}
+}
+
+// run runs the command in a subtest, printing the error it returns or that it
+// failed.
+func (s *shell) run(t *testing.T, args []string) {
+	var err error
+	ok := t.Run(strings.Join(args, " "), func(t *testing.T) {
+		err = s.command(t, args[0], args[1:])
+	})
+	if err != nil {
+		fmt.Fprintf(s.out, "error: %s\n", err)
+	} else if !ok {
+		fmt.Fprintf(s.out, "error: %s failed\n", args[0])
+	}
+}
+
+func (s *shell) command(t *testing.T, name string, args []string) error {
+	switch name {
+	case "help":
+		fmt.Fprint(s.out, shellHelp)
+		return nil
+	case "space":
+		return s.spaceCommand(t, args)
+	case "upload":
+		return s.upload(t, args)
+	case "publish-index":
+		return s.publishIndex(t, args)
+	case "query":
+		return s.query(t, args)
+	case "fetch":
+		return s.fetch(t, args)
+	case "restart":
+		return s.restart(t, args)
+	case "print":
+		return s.print(t, args)
+	}
+	return fmt.Errorf("unknown command %q, type \"help\" for commands", name)
+}
+
+func (s *shell) spaceCommand(t *testing.T, args []string) error {
+	if len(args) == 0 {
+		names := make([]string, 0, len(s.spaces))
+		for name := range s.spaces {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			marker := " "
+			if name == s.space {
+				marker = "*"
+			}
+			fmt.Fprintf(s.out, "%s %s %s\n", marker, name, s.spaces[name])
+		}
+		return nil
+	}
+	switch args[0] {
+	case "new":
+		name := fmt.Sprintf("space%d", len(s.spaces)+1)
+		if len(args) > 1 {
+			name = args[1]
+		}
+		if _, ok := s.spaces[name]; ok {
+			return fmt.Errorf("space %q already exists", name)
+		}
+		s.spaces[name] = testutil.RandomPrincipal(t).DID()
+		s.space = name
+		fmt.Fprintf(s.out, "using space %s %s\n", name, s.spaces[name])
+		return nil
+	case "use":
+		if len(args) != 2 {
+			return errors.New("usage: space use <name>")
+		}
+		if _, ok := s.spaces[args[1]]; !ok {
+			return fmt.Errorf("unknown space %q", args[1])
+		}
+		s.space = args[1]
+		fmt.Fprintf(s.out, "using space %s %s\n", s.space, s.spaces[s.space])
+		return nil
+	}
+	return errors.New("usage: space [new [name] | use <name>]")
+}
+
+// spaceDID returns the DID of a space given its name or DID.
+func (s *shell) spaceDID(name string) (did.DID, error) {
+	if space, ok := s.spaces[name]; ok {
+		return space, nil
+	}
+	space, err := did.Parse(name)
+	if err != nil {
+		return did.DID{}, fmt.Errorf("unknown space %q", name)
+	}
+	return space, nil
+}
+
+func (s *shell) upload(t *testing.T, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: upload <file>")
+	}
+	if s.space == "" {
+		return errors.New("no space, create one with space new")
+	}
+	space := s.spaces[s.space]
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	root, data, err := toCAR(data)
+	if err != nil {
+		return err
+	}
+
+	shard := testutil.Must(multihash.Sum(data, multihash.SHA2_256, -1))(t)
+	s.addBlob(t, space, shard, data)
+	_, indexDigest, indexLink, indexData := generateIndex(t, root, data, multihash.SHA2_256)
+	s.addBlob(t, space, indexDigest, indexData)
+
+	s.uploads = append(s.uploads, shellUpload{root, indexLink})
+	fmt.Fprintf(s.out, "root  %s\n", root)
+	fmt.Fprintf(s.out, "shard %s (%s)\n", digestutil.CARLink(shard), digestutil.Format(shard))
+	fmt.Fprintf(s.out, "index %s\n", indexLink)
+	return nil
+}
+
+// addBlob adds the blob to the space, putting it unless the storage node
+// already has it, and concludes the put.
+func (s *shell) addBlob(t *testing.T, space did.DID, digest multihash.Multihash, data []byte) {
+	address := s.net.UploadService.BlobAdd(t, space, digest, uint64(len(data)))
+	if address != nil {
+		netstep.PutBlob(t, address.URL, address.Headers, data)
+	}
+	s.net.UploadService.ConcludeHTTPPut(t, space, digest, uint64(len(data)))
+}
+
+// toCAR returns the CAR and its first root if the data is a CAR, and otherwise
+// a CAR of a single raw block of the data.
+func toCAR(data []byte) (ipld.Link, []byte, error) {
+	if roots, _, err := car.Decode(bytes.NewReader(data)); err == nil {
+		if len(roots) == 0 {
+			return nil, nil, errors.New("CAR has no roots")
+		}
+		return roots[0], data, nil
+	}
+	digest, err := multihash.Sum(data, multihash.SHA2_256, -1)
+	if err != nil {
+		return nil, nil, err
+	}
+	root := digestutil.RawLink(digest)
+	carBytes, err := io.ReadAll(car.Encode([]ipld.Link{root}, func(yield func(block.Block, error) bool) {
+		yield(block.NewBlock(root, data), nil)
+	}))
+	if err != nil {
+		return nil, nil, err
+	}
+	return root, carBytes, nil
+}
+
+func (s *shell) publishIndex(t *testing.T, args []string) error {
+	if len(s.uploads) == 0 {
+		return errors.New("nothing uploaded")
+	}
+	u := s.uploads[len(s.uploads)-1]
+	if len(args) > 0 {
+		digest, err := digestutil.ParseDigestOrCID(args[0])
+		if err != nil {
+			return err
+		}
+		i := slices.IndexFunc(s.uploads, func(u shellUpload) bool {
+			return bytes.Equal(digestutil.ExtractDigest(u.root), digest)
+		})
+		if i < 0 {
+			return fmt.Errorf("no upload of %s", args[0])
+		}
+		u = s.uploads[i]
+	}
+	netstep.PublishIndexClaim(t, s.net.IndexingClient, s.clk, s.agent, s.agentProof, u.root, u.indexLink)
+	fmt.Fprintf(s.out, "published index %s for %s\n", u.indexLink, u.root)
+	return nil
+}
+
+func (s *shell) query(t *testing.T, args []string) error {
+	var hashes []multihash.Multihash
+	var spaces []did.DID
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--space", "-space":
+			if i+1 == len(args) {
+				return errors.New("usage: query <cid> [--space <name>]")
+			}
+			i++
+			space, err := s.spaceDID(args[i])
+			if err != nil {
+				return err
+			}
+			spaces = append(spaces, space)
+		default:
+			digest, err := digestutil.ParseDigestOrCID(args[i])
+			if err != nil {
+				return err
+			}
+			hashes = append(hashes, digest)
+		}
+	}
+	if len(hashes) == 0 {
+		return errors.New("usage: query <cid> [--space <name>]")
+	}
+	result := netstep.QueryClaims(t, s.net.IndexingClient, hashes, spaces...)
+	s.result = &result
+	printer.WriteQueryResults(t, s.out, printer.FormatText, result)
+	return nil
+}
+
+func (s *shell) fetch(t *testing.T, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errors.New("usage: fetch <cid> [file]")
+	}
+	digest, err := digestutil.ParseDigestOrCID(args[0])
+	if err != nil {
+		return err
+	}
+	result := netstep.QueryClaims(t, s.net.IndexingClient, []multihash.Multihash{digest})
+	claim, ok := claimassert.Find(netstep.CollectClaims(t, result), claimassert.Claim().Ability(assert.LocationAbility).Content(digest))
+	if !ok {
+		return fmt.Errorf("no location commitment for %s, fetch a shard or index", digestutil.Format(digest))
+	}
+	nb, err := assert.LocationCaveatsReader.Read(claim.Capabilities()[0].Nb())
+	if err != nil {
+		return fmt.Errorf("decoding location commitment %s: %w", claim.Link(), err)
+	}
+	if len(nb.Location) == 0 {
+		return fmt.Errorf("location commitment %s has no locations", claim.Link())
+	}
+	location := nb.Location[0]
+	data, got := netstep.FetchBlob(t, location, multihash.SHA2_256)
+	if !bytes.Equal(got, digest) {
+		return fmt.Errorf("fetched %d bytes from %s with digest %s", len(data), location.String(), digestutil.Format(got))
+	}
+	fmt.Fprintf(s.out, "fetched %d bytes from %s\n", len(data), location.String())
+	if len(args) == 2 {
+		return os.WriteFile(args[1], data, 0o644)
+	}
+	return nil
+}
+
+func (s *shell) restart(t *testing.T, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: restart <storage|indexing|ipni>")
+	}
+	var svc bootstrap.Service
+	switch args[0] {
+	case "storage":
+		svc = s.net.Storage
+	case "indexing":
+		svc = s.net.Indexing
+	case "ipni":
+		svc = s.net.IPNI
+	default:
+		return fmt.Errorf("unknown service %q, expected storage, indexing or ipni", args[0])
+	}
+	start := time.Now()
+	svc.Stop()
+	svc.Start(t)
+	bootstrap.WaitHealthy(t, svc, 5*time.Second)
+	fmt.Fprintf(s.out, "restarted %s in %s\n", args[0], time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+func (s *shell) print(t *testing.T, args []string) error {
+	if s.result == nil {
+		return errors.New("no query results, run query first")
+	}
+	format := "text"
+	if len(args) > 0 {
+		format = args[0]
+	}
+	if f, err := printer.ParseFormat(format); err == nil {
+		printer.WriteQueryResults(t, s.out, f, *s.result)
+		return nil
+	}
+	f, err := printer.ParseGraphFormat(format)
+	if err != nil {
+		return fmt.Errorf("unknown format %q, expected text, json, dag-json, mermaid or dot", format)
+	}
+	printer.WriteQueryResultsGraph(t, s.out, f, *s.result)
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/testthenetwork/internal/digestutil"
+	"github.com/storacha/testthenetwork/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+var shellFlag = flag.Bool("shell", false, "run an interactive shell against the network in TestShell")
+
+// TestShell runs the shell on stdin when the -shell flag is set, and a script
+// of its commands otherwise.
+func TestShell(t *testing.T) {
+	logging.SetLogLevel("*", "warn")
+
+	if *shellFlag {
+		runShell(t, os.Stdin, os.Stdout)
+		return
+	}
+
+	dir := t.TempDir()
+	_, data := testutil.RandomBytes(t, 1024)
+	file := filepath.Join(dir, "data.bin")
+	require.NoError(t, os.WriteFile(file, data, 0o644))
+	root, carData, err := toCAR(data)
+	require.NoError(t, err)
+	shard := digestutil.CARLink(testutil.Must(multihash.Sum(carData, multihash.SHA2_256, -1))(t))
+	fetched := filepath.Join(dir, "fetched.car")
+
+	script := strings.Join([]string{
+		"help",
+		"space new alice",
+		"upload " + file,
+		"publish-index",
+		"query " + root.String() + " --space alice",
+		"print json",
+		"space new bob",
+		"query " + root.String() + " --space bob",
+		"restart storage",
+		"fetch " + shard.String() + " " + fetched,
+		"space",
+		"launch",
+		"exit",
+	}, "\n")
+	var out bytes.Buffer
+	runShell(t, strings.NewReader(script), &out)
+	t.Log(out.String())
+
+	require.Contains(t, out.String(), "using space alice did:key:")
+	require.Contains(t, out.String(), "root  "+root.String())
+	require.Contains(t, out.String(), "published index ")
+	require.Contains(t, out.String(), "restarted storage in ")
+	require.Contains(t, out.String(), "* bob did:key:")
+	require.Contains(t, out.String(), `error: unknown command "launch"`)
+	require.Equal(t, carData, testutil.Must(os.ReadFile(fetched))(t))
+}